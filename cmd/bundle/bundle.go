@@ -0,0 +1,133 @@
+package bundle
+
+import (
+	"dbin/db"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Move databases to machines without registry access",
+	}
+	cmd.AddCommand(newCreateCommand(), newLoadCommand())
+	return cmd
+}
+
+func newCreateCommand() *cobra.Command {
+	var output, version, file string
+
+	cmd := &cobra.Command{
+		Use:   "create [database...]",
+		Short: "Write the images of databases to a single archive",
+		Long: `Write every image the given databases run, companions and client sidecars
+included, to a tar archive with a manifest of their IDs and the sha256 digest
+of each entry. Missing images are pulled first.
+
+Without arguments, the databases of dbin.yaml are bundled at their configured
+version, along with dbin.yaml itself and the init scripts it references.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if version != "" && len(args) != 1 {
+				return fmt.Errorf("--version applies to a single database")
+			}
+
+			var databases []db.StackDatabase
+			var stack *db.Stack
+			if len(args) > 0 {
+				for _, name := range args {
+					if _, err := db.GetDatabaseInfo(name); err != nil {
+						return err
+					}
+					databases = append(databases, db.StackDatabase{Name: name, Version: version})
+				}
+			} else {
+				var err error
+				if stack, err = db.LoadStack(file); err != nil {
+					return fmt.Errorf("%v, or name the databases to bundle", err)
+				}
+				databases = stack.Databases
+			}
+
+			manifest, err := db.CreateBundle(output, databases, stack)
+			if err != nil {
+				return err
+			}
+			var size int64
+			for _, f := range manifest.Files {
+				size += f.Size
+			}
+			log.Printf("Wrote %s with %d images and %d files, %s", output, len(manifest.Images), len(manifest.Files)-1, db.FormatSize(size))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "bundle.tar", "Archive to write")
+	cmd.Flags().StringVar(&version, "version", "", "Tag of the main image to bundle, like dbin <database> --version")
+	cmd.Flags().StringVarP(&file, "file", "f", db.StackFile, "Stack file to read when no database is named")
+	return cmd
+}
+
+func newLoadCommand() *cobra.Command {
+	var dir string
+	var verifyOnly, force bool
+
+	cmd := &cobra.Command{
+		Use:   "load <bundle>",
+		Short: "Load the images of an archive made by dbin bundle create",
+		Long: `Check every entry of a bundle against the digests of its manifest, load its
+images into Docker and check they have the IDs the manifest records. dbin
+then starts the bundled databases without pulling anything.
+
+Project files, such as dbin.yaml and init scripts, are written to --dir,
+the current directory by default. Existing files are kept unless --force.`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if verifyOnly {
+				manifest, err := db.VerifyBundle(args[0])
+				if err != nil {
+					return err
+				}
+				log.Printf("%s is intact: %s", args[0], describe(manifest))
+				return nil
+			}
+
+			if dir != "" {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					return fmt.Errorf("failed to create directory: %v", err)
+				}
+			}
+			manifest, err := db.LoadBundle(args[0], dir, force)
+			if err != nil {
+				return err
+			}
+			log.Printf("Loaded %s", describe(manifest))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", ".", "Directory to write the project files to, empty to skip them")
+	cmd.Flags().BoolVar(&verifyOnly, "verify", false, "Only check the bundle against its manifest")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace existing project files")
+	return cmd
+}
+
+func describe(manifest *db.BundleManifest) string {
+	var names []string
+	for _, d := range manifest.Databases {
+		name := d.Name
+		if d.Version != "" {
+			name += " " + d.Version
+		}
+		names = append(names, name)
+	}
+	return fmt.Sprintf("%s (%d images, created %s)", strings.Join(names, ", "), len(manifest.Images), manifest.Created.Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,154 @@
+package chaos
+
+import (
+	"bufio"
+	"dbin/db"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const actions = `Actions:
+  pause             Freeze all processes of the node
+  unpause           Resume a paused node
+  kill              Stop the node with SIGKILL
+  start             Start a killed node again
+  restart           Stop and start the node
+  partition         Disconnect the node from the dbin-<instance>-net network
+  heal              Reconnect a partitioned node
+  latency <delay>   Delay its packets, e.g. 200ms (see --jitter and --loss)
+  loss <percent>    Drop a percentage of its packets
+  clear             Remove latency and packet loss
+  run <scenario>    Run a scenario file
+
+Scenario files list one action per line, prefixed by the time it runs at
+relative to the start of the scenario. Blank lines and lines starting with
+# are ignored:
+
+  0s    latency 200ms --node 2
+  30s   partition --node 3
+  1m    heal --node 3
+  1m    clear --node 2`
+
+func NewCommand() *cobra.Command {
+	var node, jitter, loss string
+
+	cmd := &cobra.Command{
+		Use:   "chaos <instance> <action> [args]",
+		Short: "Inject failures into a running database",
+		Long: `Pause, kill or partition the nodes of a database started by dbin, or degrade
+their network with latency and packet loss.
+
+` + actions,
+		Args:          cobra.MinimumNArgs(2),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instance, action, rest := args[0], args[1], args[2:]
+			if action == "run" {
+				if len(rest) != 1 {
+					return fmt.Errorf("run needs a scenario file")
+				}
+				return runScenario(instance, rest[0])
+			}
+
+			c, err := db.NewChaos(instance)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			switch action {
+			case "pause":
+				return c.Pause(node)
+			case "unpause":
+				return c.Unpause(node)
+			case "kill":
+				return c.Kill(node)
+			case "start":
+				return c.Start(node)
+			case "restart":
+				return c.Restart(node)
+			case "partition":
+				return c.Partition(node)
+			case "heal":
+				return c.Heal(node)
+			case "latency":
+				if len(rest) != 1 {
+					return fmt.Errorf("latency needs a delay, e.g. 200ms")
+				}
+				return c.Degrade(node, db.Netem{Delay: rest[0], Jitter: jitter, Loss: loss})
+			case "loss":
+				if len(rest) != 1 {
+					return fmt.Errorf("loss needs a percentage, e.g. 5%%")
+				}
+				return c.Degrade(node, db.Netem{Loss: rest[0]})
+			case "clear":
+				return c.Clear(node)
+			}
+			return fmt.Errorf("unknown action %q\n\n%s", action, actions)
+		},
+	}
+
+	cmd.Flags().StringVar(&node, "node", "", "Node number or container name, the main container by default")
+	cmd.Flags().StringVar(&jitter, "jitter", "", "Random variation of the latency, e.g. 50ms")
+	cmd.Flags().StringVar(&loss, "loss", "", "Also drop a percentage of packets along with the latency")
+	return cmd
+}
+
+type step struct {
+	at   time.Duration
+	args []string
+}
+
+// runScenario runs the actions of a scenario file at their scheduled times.
+// Each step runs like the equivalent dbin chaos command line.
+func runScenario(instance string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open scenario: %v", err)
+	}
+	defer file.Close()
+
+	var steps []step
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("%s:%d: expected a time and an action", path, lineNo)
+		}
+		at, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid time %q: %v", path, lineNo, fields[0], err)
+		}
+		if fields[1] == "run" {
+			return fmt.Errorf("%s:%d: scenarios cannot run other scenarios", path, lineNo)
+		}
+		steps = append(steps, step{at: at, args: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read scenario: %v", err)
+	}
+
+	start := time.Now()
+	for _, s := range steps {
+		if wait := time.Until(start.Add(s.at)); wait > 0 {
+			time.Sleep(wait)
+		}
+		log.Printf("[%s] %s", s.at, strings.Join(s.args, " "))
+
+		cmd := NewCommand()
+		cmd.SetArgs(append([]string{instance}, s.args...))
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("step at %s failed: %v", s.at, err)
+		}
+	}
+	return nil
+}
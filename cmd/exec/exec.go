@@ -0,0 +1,56 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"dbin/db"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	var file string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "exec <instance> [-- <query>]",
+		Short: "Run a query against a running database",
+		Long: `Run a query or script against a database started by dbin, without an interactive session.
+The query is read from the arguments after --, from a file with --file, or from stdin.
+The exit code of the database client is returned.`,
+		Example: `  dbin exec postgres -- "SELECT version()"
+  dbin exec mysql --file schema.sql
+  echo "INFO server" | dbin exec redis
+  dbin exec clickhouse --output json -- "SELECT 1 AS one"`,
+		Args:          cobra.MinimumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var script io.Reader
+			switch {
+			case len(args) > 1 && file != "":
+				return fmt.Errorf("pass the query either as arguments or with --file, not both")
+			case len(args) > 1:
+				script = strings.NewReader(strings.Join(args[1:], " ") + "\n")
+			case file != "" && file != "-":
+				f, err := os.Open(file)
+				if err != nil {
+					return fmt.Errorf("failed to open script: %v", err)
+				}
+				defer f.Close()
+				script = f
+			default:
+				script = os.Stdin
+			}
+
+			return db.ExecInstance(args[0], script, output, os.Stdout, os.Stderr)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Read the query from a file (- for stdin)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output format: table, csv or json, when the client supports it")
+	return cmd
+}
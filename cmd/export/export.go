@@ -0,0 +1,83 @@
+package export
+
+import (
+	"dbin/db"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	var format, output, file string
+
+	cmd := &cobra.Command{
+		Use:   "export [database...]",
+		Short: "Export databases as a Docker Compose file or Kubernetes manifests",
+		Long: `Render the containers dbin runs for a database, with their images,
+environment, commands, ports, volumes, network and start order, as a
+docker-compose.yml or as Kubernetes Deployments and Services.
+
+Running instances are exported as they are. Other databases are started for
+the time of the export with their settings from dbin.yaml, if they are listed
+there. Without arguments, every database of dbin.yaml is exported.
+
+The output contains the credentials of the databases in plain text.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != db.ExportCompose && format != db.ExportKubernetes {
+				return fmt.Errorf("invalid --format %q, expected %s or %s", format, db.ExportCompose, db.ExportKubernetes)
+			}
+
+			// dbin.yaml is optional when databases are named
+			var stack *db.Stack
+			if _, err := os.Stat(file); err == nil || len(args) == 0 || cmd.Flags().Changed("file") {
+				var err error
+				if stack, err = db.LoadStack(file); err != nil {
+					return err
+				}
+			}
+
+			names := args
+			if len(names) == 0 {
+				for _, d := range stack.Databases {
+					names = append(names, d.Name)
+				}
+			}
+
+			var services []db.ServiceSpec
+			for _, name := range names {
+				if _, err := db.GetDatabaseInfo(name); err != nil {
+					return err
+				}
+				opts := db.Options{Name: name, NoBrowser: true, ClientMode: db.ClientModeContainer}
+				if stack != nil {
+					if d, ok := stack.Database(name); ok {
+						var err error
+						if opts, err = stack.Options(d); err != nil {
+							return err
+						}
+					}
+				}
+
+				specs, err := db.CaptureDatabase(opts)
+				if err != nil {
+					return err
+				}
+				services = append(services, specs...)
+			}
+
+			data, err := db.RenderExport(format, services)
+			if err != nil {
+				return err
+			}
+			return db.WriteExport(output, data)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", db.ExportCompose, "Output format: compose or k8s")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write instead of stdout")
+	cmd.Flags().StringVarP(&file, "file", "f", db.StackFile, "Stack file to read settings from")
+	return cmd
+}
@@ -0,0 +1,202 @@
+package images
+
+import (
+	"dbin/db"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "images",
+		Short: "Manage the images of the databases",
+	}
+	cmd.AddCommand(newPullCommand(), newListCommand(), newPruneCommand())
+	return cmd
+}
+
+func newPullCommand() *cobra.Command {
+	var all, refresh bool
+	var version, platform, file string
+
+	cmd := &cobra.Command{
+		Use:   "pull [database...]",
+		Short: "Pull the images of databases ahead of time",
+		Long: `Pull every image the given databases run, companions such as kibana,
+zookeeper or dgraph/ratel included, so starting them later doesn't download
+anything. Without arguments, the databases of dbin.yaml are pulled at their
+configured version.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if version != "" && (all || len(args) != 1) {
+				return fmt.Errorf("--version applies to a single database")
+			}
+			if platform != "" {
+				if _, err := db.ParsePlatform(platform); err != nil {
+					return err
+				}
+			}
+			if all {
+				if len(args) > 0 {
+					return fmt.Errorf("--all takes no databases")
+				}
+				for _, info := range db.GetAllDatabases() {
+					args = append(args, info.Name)
+				}
+			}
+			if len(args) > 0 {
+				var databases []db.StackDatabase
+				for _, name := range args {
+					if _, err := db.GetDatabaseInfo(name); err != nil {
+						return err
+					}
+					databases = append(databases, db.StackDatabase{Name: name, Version: version, Platform: platform})
+				}
+				return db.PullDatabaseImages(databases, refresh)
+			}
+
+			stack, err := db.LoadStack(file)
+			if err != nil {
+				return fmt.Errorf("%v, or name the databases to pull", err)
+			}
+			databases := stack.Databases
+			if platform != "" {
+				for i := range databases {
+					databases[i].Platform = platform
+				}
+			}
+			return db.PullDatabaseImages(databases, refresh)
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Pull the images of every supported database")
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "Pull images again even when present, to update tags like latest")
+	cmd.Flags().StringVar(&version, "version", "", "Tag of the main image to pull, like dbin <database> --version")
+	cmd.Flags().StringVar(&platform, "platform", "", "Platform of the images to pull, e.g. linux/amd64, like dbin <database> --platform")
+	cmd.Flags().StringVarP(&file, "file", "f", db.StackFile, "Stack file to read when no database is named")
+	return cmd
+}
+
+func newListCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:           "ls",
+		Short:         "List the local images of the databases",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("invalid --output %q, expected table or json", output)
+			}
+			images, err := db.ListDatabaseImages()
+			if err != nil {
+				return err
+			}
+
+			if output == "json" {
+				if images == nil {
+					images = []db.LocalImage{}
+				}
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(images)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "IMAGE\tDATABASES\tSIZE\tCREATED\tDEFAULT\tPULLED BY DBIN")
+			var total int64
+			for _, img := range images {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s ago\t%s\t%s\n", img.Reference, strings.Join(img.Databases, ", "),
+					db.FormatSize(img.Size), units.HumanDuration(time.Since(img.Created)), yesNo(img.Default), yesNo(img.ByDbin))
+				total += img.Size
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Printf("\n%d images, %s\n", len(images), db.FormatSize(total))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+	return cmd
+}
+
+func newPruneCommand() *cobra.Command {
+	var dryRun bool
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove images pulled by dbin that are no longer configured",
+		Long: `Remove the images dbin pulled that are not the version a database runs
+anymore: tags pulled with --version that are neither the default nor the
+version set in dbin.yaml, and older builds of tags pulled again, such as a
+previous latest. Images pulled outside dbin, images of existing containers
+and images pinned by dbin.lock are kept.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var keep []string
+			if _, err := os.Stat(file); err == nil || cmd.Flags().Changed("file") {
+				stack, err := db.LoadStack(file)
+				if err != nil {
+					return err
+				}
+				for _, d := range stack.Databases {
+					info, err := db.GetDatabaseInfo(d.Name)
+					if err != nil {
+						return err
+					}
+					keep = append(keep, db.DatabaseImages(info, d.Version, "")...)
+				}
+			}
+			lock, err := db.LoadLock(db.LockFor(file))
+			if err != nil {
+				return err
+			}
+			if lock != nil {
+				keep = append(keep, lock.Pinned()...)
+			}
+
+			removed, err := db.PruneImages(keep, dryRun)
+			for _, ref := range removed {
+				if dryRun {
+					fmt.Printf("Would remove %s\n", ref)
+				} else {
+					fmt.Printf("Removed %s\n", ref)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			if len(removed) == 0 {
+				log.Println("Nothing to prune")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be removed")
+	cmd.Flags().StringVarP(&file, "file", "f", db.StackFile, "Stack file whose versions, and lockfile next to it, are kept")
+	return cmd
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
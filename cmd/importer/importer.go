@@ -0,0 +1,74 @@
+package importer
+
+import (
+	"dbin/db"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	var output string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "import [compose-file]",
+		Short: "Generate dbin.yaml from the database services of a compose file",
+		Long: `Recognise the database services of a docker-compose file by their image and
+write a dbin.yaml running them with dbin instead, with their version,
+published port, credentials, init scripts, environment and volumes. Named
+volumes keep the name docker compose gives them, so the data is kept.
+
+Without an argument, compose.yaml, compose.yml, docker-compose.yaml or
+docker-compose.yml is read from the current directory.`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var composeFile string
+			if len(args) > 0 {
+				composeFile = args[0]
+			} else {
+				for _, name := range db.ComposeFiles {
+					if _, err := os.Stat(name); err == nil {
+						composeFile = name
+						break
+					}
+				}
+				if composeFile == "" {
+					return fmt.Errorf("no compose file found (looked for %s)", strings.Join(db.ComposeFiles, ", "))
+				}
+			}
+
+			if _, err := os.Stat(output); err == nil && !force {
+				return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+			}
+
+			stack, notes, err := db.ImportCompose(composeFile, output)
+			for _, note := range notes {
+				log.Println(note)
+			}
+			if err != nil {
+				return err
+			}
+			if err := stack.Save("Generated by dbin import from " + filepath.Base(composeFile)); err != nil {
+				return err
+			}
+
+			var names []string
+			for _, d := range stack.Databases {
+				names = append(names, d.Name)
+			}
+			log.Printf("Wrote %s with %s, start them with dbin up", output, strings.Join(names, ", "))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", db.StackFile, "Stack file to write")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing stack file")
+	return cmd
+}
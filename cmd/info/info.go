@@ -0,0 +1,114 @@
+package info
+
+import (
+	"dbin/db"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "info <database>",
+		Short: "Show what starting a database involves",
+		Long: `Show the containers, images, ports, credentials, data paths, memory needs,
+readiness checks and clients of a database, without starting it. Image sizes
+are shown for the images already pulled.`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid --output %q, expected text or json", output)
+			}
+
+			details, err := db.DescribeDatabase(args[0])
+			if err != nil {
+				return err
+			}
+
+			if output == "json" {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(details)
+			}
+			printDetails(details)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	return cmd
+}
+
+func printDetails(d db.DatabaseDetails) {
+	fmt.Printf("%s - %s\n", d.Name, d.Description)
+	fmt.Printf("Category:    %s\n", db.CategoryTitle(d.Category))
+	fmt.Printf("Tags:        %s\n", strings.Join(d.Tags, ", "))
+	if d.Docs != "" {
+		fmt.Printf("Docs:        %s\n", d.Docs)
+	}
+	if d.Tutorial != "" {
+		fmt.Printf("Tutorial:    %s\n", d.Tutorial)
+	}
+
+	fmt.Println("\nContainers, in start order:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tIMAGE\tLOCAL IMAGE\tPORTS\tDATA\tROLE")
+	for _, c := range d.Containers {
+		local := "?"
+		if c.Pulled != nil {
+			local = "not pulled"
+			if *c.Pulled {
+				local = db.FormatSize(c.ImageSize)
+			}
+		}
+		data := c.DataPath
+		if data == "" {
+			data = "-"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", c.Name, c.Image, local, strings.Join(c.Ports, ", "), data, c.Role)
+	}
+	w.Flush()
+	for arch, image := range d.ArchImages {
+		fmt.Printf("  On %s, the main image is %s\n", arch, image)
+	}
+
+	fmt.Println()
+	if d.Credentials != nil {
+		fmt.Printf("Credentials: %s / %s\n", d.Credentials.User, d.Credentials.Password)
+	} else {
+		fmt.Println("Credentials: none")
+	}
+	fmt.Printf("Memory:      %s\n", d.Memory)
+	fmt.Printf("Readiness:   %s\n", d.Readiness)
+	fmt.Printf("Flags:       %s\n", strings.Join(d.Flags, " "))
+
+	if len(d.Clients) > 0 {
+		fmt.Println("\nClients (--client):")
+		for i, c := range d.Clients {
+			name := c.Name
+			if i == 0 {
+				name += " (default)"
+			}
+			fmt.Printf("  %s: %s\n", name, c.Description)
+			if c.Sidecar != "" {
+				fmt.Printf("    from a %s sidecar: %s\n", c.Sidecar, c.Command)
+			} else {
+				fmt.Printf("    %s\n", c.Command)
+			}
+		}
+	}
+	if len(d.WebUIs) > 0 {
+		fmt.Println("\nWeb interfaces (--ui, dbin open):")
+		for _, ui := range d.WebUIs {
+			fmt.Printf("  %s: %s, port %s of %s%s\n", ui.Name, ui.Description, ui.Port, ui.Container, ui.Path)
+		}
+	}
+}
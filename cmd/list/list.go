@@ -1,8 +1,12 @@
 package list
 
 import (
+	"encoding/json"
 	"fmt"
-	"sort"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
 
 	"dbin/db"
 
@@ -10,23 +14,72 @@ import (
 )
 
 func NewCommand() *cobra.Command {
-	return &cobra.Command{
+	var category, search, output string
+
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List supported databases",
-		Long:  `Display a list of all databases supported by dbin`,
+		Long: `Display the databases supported by dbin by category, with their
+image, ports, clients and whether the image is already pulled.
+
+Categories: ` + strings.Join(db.Categories, ", "),
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			databases := db.GetAllDatabases()
-			
-			// Sort databases by name for consistent output
-			sort.Slice(databases, func(i, j int) bool {
-				return databases[i].Name < databases[j].Name
-			})
-
-			fmt.Println("Supported databases:")
-			for _, info := range databases {
-				fmt.Printf("- %s (%s)\n", info.Description, info.Name)
+			if output != "table" && output != "json" {
+				return fmt.Errorf("invalid --output %q, expected table or json", output)
+			}
+
+			entries, err := db.Catalog(category, search)
+			if err != nil {
+				return err
+			}
+
+			// Listing works without Docker, only the pulled images are unknown
+			pulledKnown := true
+			if err := db.FillPulled(entries); err != nil {
+				pulledKnown = false
+				if output == "table" {
+					log.Printf("Warning: cannot tell which images are pulled: %v", err)
+				}
 			}
-			return nil
+
+			if output == "json" {
+				if entries == nil {
+					entries = []db.CatalogEntry{}
+				}
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(entries)
+			}
+
+			if len(entries) == 0 {
+				fmt.Println("No databases match")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tCATEGORY\tDESCRIPTION\tIMAGE\tPORTS\tCLIENTS\tPULLED")
+			for _, e := range entries {
+				var ports []string
+				for _, p := range e.Ports {
+					ports = append(ports, strings.TrimSuffix(p, "/tcp"))
+				}
+				pulled := "-"
+				if !pulledKnown {
+					pulled = "?"
+				} else if len(e.Pulled) > 0 {
+					pulled = strings.Join(e.Pulled, ", ")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Name, e.Category, e.Description, e.Image,
+					strings.Join(ports, ", "), strings.Join(e.ClientTypes, ", "), pulled)
+			}
+			return w.Flush()
 		},
 	}
+
+	cmd.Flags().StringVar(&category, "category", "", "Only list databases of this category")
+	cmd.Flags().StringVarP(&search, "search", "s", "", "Only list databases whose name, description or tags contain this text")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+	return cmd
 }
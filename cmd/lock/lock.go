@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"dbin/db"
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	var version, file, output string
+
+	cmd := &cobra.Command{
+		Use:   "lock [database...]",
+		Short: "Pin the images of databases to their current digests",
+		Long: `Resolve every image the databases run to its digest in the registry and
+write them to dbin.lock. While a lockfile exists, dbin pulls and runs the
+pinned digests instead of moving tags such as latest, and warns when a
+local image differs from the lock. Commit dbin.lock so teammates run the
+same server versions, and run dbin lock again to update it.
+
+Without arguments, the databases of dbin.yaml are locked at their
+configured version and dbin.lock is written next to it, replacing it.
+Named databases are added to the existing lockfile.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if version != "" && len(args) != 1 {
+				return fmt.Errorf("--version applies to a single database")
+			}
+
+			var databases []db.StackDatabase
+			path := output
+			if len(args) > 0 {
+				for _, name := range args {
+					if _, err := db.GetDatabaseInfo(name); err != nil {
+						return err
+					}
+					databases = append(databases, db.StackDatabase{Name: name, Version: version})
+				}
+				if path == "" {
+					path = db.LockFile
+				}
+			} else {
+				stack, err := db.LoadStack(file)
+				if err != nil {
+					return fmt.Errorf("%v, or name the databases to lock", err)
+				}
+				databases = stack.Databases
+				if path == "" {
+					path = db.LockFor(file)
+				}
+			}
+
+			lock, err := db.LockImages(path, databases, len(args) > 0)
+			if err != nil {
+				return err
+			}
+			log.Printf("Wrote %s with %d images", lock.Path, len(lock.Images))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&version, "version", "", "Tag of the main image to lock, like dbin <database> --version")
+	cmd.Flags().StringVarP(&file, "file", "f", db.StackFile, "Stack file to read when no database is named")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Lockfile to write (default dbin.lock)")
+	return cmd
+}
@@ -0,0 +1,28 @@
+package open
+
+import (
+	"dbin/db"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	var noBrowser bool
+
+	cmd := &cobra.Command{
+		Use:   "open <instance> [ui]",
+		Short: "Open a web interface of a running database",
+		Long:  `Open one of the web interfaces of a database started by dbin, such as Kibana for elasticsearch or the Neo4j Browser`,
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var ui string
+			if len(args) > 1 {
+				ui = args[1]
+			}
+			return db.OpenInstanceUI(args[0], ui, noBrowser)
+		},
+	}
+
+	cmd.Flags().BoolVar(&noBrowser, "no-browser", false, "Print the URL instead of opening a browser")
+	return cmd
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"dbin/db"
+	"dbin/internal/proxy"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "proxy <instance> [set <key>=<value>... | clear]",
+		Short: "Control the proxy of a database started with --proxy",
+		Long: `Show or change the faults injected by the proxy of a database started with --proxy.
+
+Settings:
+  latency=<duration>   Delay added to every chunk of data in both directions, e.g. 200ms
+  bandwidth=<rate>     Bytes per second in each direction, e.g. 64KB, 0 for unlimited
+  reset=true|false     Reset open connections, and every new one while enabled
+  drop=true|false      Accept new connections but never forward them`,
+		Example: `  dbin proxy postgres
+  dbin proxy postgres set latency=200ms bandwidth=64KB
+  dbin proxy redis set reset=true
+  dbin proxy postgres clear`,
+		Args:          cobra.MinimumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state, err := db.LoadProxyState(args[0])
+			if err != nil {
+				return err
+			}
+			control := proxy.Control{URL: state.Control}
+
+			var settings map[string]string
+			switch {
+			case len(args) == 1:
+				settings, err = control.Get()
+			case args[1] == "set":
+				values := url.Values{}
+				for _, arg := range args[2:] {
+					key, value, ok := strings.Cut(arg, "=")
+					if !ok {
+						return fmt.Errorf("invalid setting %q, expected key=value", arg)
+					}
+					values.Set(key, value)
+				}
+				if len(values) == 0 {
+					return fmt.Errorf("nothing to set (available: %s)", strings.Join(proxy.Keys, ", "))
+				}
+				settings, err = control.Set(values)
+			case args[1] == "clear" && len(args) == 2:
+				settings, err = control.Clear()
+			default:
+				return fmt.Errorf("unknown proxy command %q, expected set or clear", strings.Join(args[1:], " "))
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Proxy %s -> %s\n", state.Listen, state.Target)
+			keys := make([]string, 0, len(settings))
+			for key := range settings {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("  %-10s %s\n", key, settings[key])
+			}
+			return nil
+		},
+	}
+}
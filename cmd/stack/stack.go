@@ -0,0 +1,92 @@
+package stack
+
+import (
+	"dbin/db"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func NewUpCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "up",
+		Short: "Start the databases listed in dbin.yaml",
+		Long: `Start every database listed in dbin.yaml in the background, skipping those
+already running, and write their addresses and credentials to a single .env
+file. Each database accepts the settings of its dbin <database> flags:
+
+  databases:
+    postgres:
+      version: "16"
+      port: 5432
+      user: app
+      password: secret
+      init: ./sql
+      data: ./data/postgres
+    redis: {}
+    opensearch:
+      auth: true`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stack, err := db.LoadStack(file)
+			if err != nil {
+				return err
+			}
+			if err := stack.Up(); err != nil {
+				return err
+			}
+			return stack.Status(os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", db.StackFile, "Stack file to read")
+	return cmd
+}
+
+func NewDownCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:           "down",
+		Short:         "Stop and remove the databases listed in dbin.yaml",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stack, err := db.LoadStack(file)
+			if err != nil {
+				return err
+			}
+			return stack.Down()
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", db.StackFile, "Stack file to read")
+	return cmd
+}
+
+func NewStatusCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:           "status",
+		Short:         "Show the state of the databases listed in dbin.yaml",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stack, err := db.LoadStack(file)
+			if err != nil {
+				return err
+			}
+			return stack.Status(os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", db.StackFile, "Stack file to read")
+	return cmd
+}
@@ -0,0 +1,121 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dbin/db"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func NewCommand() *cobra.Command {
+	var once bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "stats <instance>",
+		Short: "Show the resources a running database uses",
+		Long: `Stream the CPU, memory, network I/O and block I/O of every container of a
+database started by dbin, companions such as web interfaces included, as
+docker stats does. Network and block I/O are totals since each container
+started.
+
+Some databases also show engine metrics: the client connections of Postgres,
+the clients, memory, throughput and hit rate of Redis, and the cluster
+health of Elasticsearch.`,
+		Example: `  dbin stats elasticsearch
+  dbin stats postgres --once --output json`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("invalid --output %q, expected table or json", output)
+			}
+			if _, err := db.GetDatabaseInfo(args[0]); err != nil {
+				return err
+			}
+			cli, err := db.NewDockerClient()
+			if err != nil {
+				return err
+			}
+			defer cli.Close()
+
+			// Redraw in place on a terminal, like docker stats
+			redraw := !once && output == "table" && term.IsTerminal(int(os.Stdout.Fd()))
+			for first := true; ; first = false {
+				sample, err := db.SampleInstance(context.Background(), cli, args[0])
+				if err != nil {
+					return err
+				}
+
+				switch {
+				case output == "json" && once:
+					encoder := json.NewEncoder(os.Stdout)
+					encoder.SetIndent("", "  ")
+					return encoder.Encode(sample)
+				case output == "json":
+					// One object per line, for tools reading the stream
+					if err := json.NewEncoder(os.Stdout).Encode(sample); err != nil {
+						return err
+					}
+				default:
+					var buf strings.Builder
+					if redraw {
+						buf.WriteString("\033[H\033[2J")
+					} else if !first {
+						buf.WriteString("\n")
+					}
+					if err := printSample(&buf, sample); err != nil {
+						return err
+					}
+					fmt.Print(buf.String())
+					if once {
+						return nil
+					}
+				}
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&once, "once", false, "Print one sample and exit instead of streaming")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+	return cmd
+}
+
+func printSample(out io.Writer, sample db.InstanceSample) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tBLOCK I/O")
+	for _, c := range sample.Containers {
+		memPercent := 0.0
+		if c.MemoryLimit > 0 {
+			memPercent = float64(c.MemoryUsage) / float64(c.MemoryLimit) * 100
+		}
+		fmt.Fprintf(w, "%s\t%.2f%%\t%s / %s\t%.2f%%\t%s / %s\t%s / %s\n", c.Name, c.CPUPercent,
+			db.FormatSize(int64(c.MemoryUsage)), db.FormatSize(int64(c.MemoryLimit)), memPercent,
+			db.FormatSize(int64(c.NetRx)), db.FormatSize(int64(c.NetTx)),
+			db.FormatSize(int64(c.BlockRead)), db.FormatSize(int64(c.BlockWrite)))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case sample.MetricsErr != "":
+		fmt.Fprintf(out, "\n%s\n", sample.MetricsErr)
+	case len(sample.Metrics) > 0:
+		var metrics []string
+		for _, m := range sample.Metrics {
+			metrics = append(metrics, m.Name+": "+m.Value)
+		}
+		fmt.Fprintf(out, "\n%s  %s\n", sample.Instance, strings.Join(metrics, "   "))
+	}
+	return nil
+}
@@ -0,0 +1,673 @@
+package ui
+
+import (
+	"context"
+	"dbin/db"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"golang.org/x/term"
+)
+
+const (
+	paneRunning = iota
+	paneCatalog
+	paneLogs
+)
+
+// snapshotDir is where snapshots are written, relative to the current
+// directory
+const snapshotDir = "dbin-snapshots"
+
+// instanceRow is a running instance with its last resource sample
+type instanceRow struct {
+	db.Instance
+	stats []db.ContainerStats
+}
+
+type dashboard struct {
+	cli     *client.Client
+	catalog []db.CatalogEntry
+	redraw  chan struct{}
+
+	mu         sync.Mutex
+	oldState   *term.State // Terminal state to restore, nil when not drawing
+	pane       int
+	cursor     [2]int // Selected row of the running and catalog panes
+	instances  []instanceRow
+	stats      map[string][]db.ContainerStats
+	refreshing bool
+	starting   map[string]bool
+	managers   map[string]db.DatabaseManager // Started from the dashboard
+	logsOf     string
+	logLines   []string
+	message    string
+}
+
+func newDashboard() (*dashboard, error) {
+	cli, err := db.NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cli.Ping(context.Background()); err != nil {
+		return nil, fmt.Errorf("cannot reach Docker: %v", err)
+	}
+	catalog, err := db.Catalog("", "")
+	if err != nil {
+		return nil, err
+	}
+	return &dashboard{
+		cli:      cli,
+		catalog:  catalog,
+		redraw:   make(chan struct{}, 1),
+		stats:    make(map[string][]db.ContainerStats),
+		starting: make(map[string]bool),
+		managers: make(map[string]db.DatabaseManager),
+		message:  "Select a database and press enter to start it",
+	}, nil
+}
+
+// Write shows log lines of dbin in the message line, as they would
+// otherwise be drawn over the dashboard
+func (d *dashboard) Write(p []byte) (int, error) {
+	lines := strings.Split(strings.TrimSpace(string(p)), "\n")
+	d.setMessage(lines[len(lines)-1])
+	return len(p), nil
+}
+
+func (d *dashboard) setMessage(format string, args ...interface{}) {
+	d.mu.Lock()
+	d.message = fmt.Sprintf(format, args...)
+	d.mu.Unlock()
+	d.requestRedraw()
+}
+
+func (d *dashboard) requestRedraw() {
+	select {
+	case d.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// run draws the dashboard and handles keys until the user quits
+func (d *dashboard) run() error {
+	if err := d.enterScreen(); err != nil {
+		return err
+	}
+	log.SetOutput(d)
+	log.SetFlags(log.Ltime)
+	db.PlainProgress = true
+
+	// Stdin is only read when asked, so nothing competes with a client
+	// started from the dashboard for its input
+	keys := make(chan []byte)
+	next := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 64)
+		for range next {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+	next <- struct{}{}
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	go d.refresh()
+
+	for {
+		d.render()
+		select {
+		case key, ok := <-keys:
+			if !ok || d.handleKey(string(key)) {
+				return nil
+			}
+			next <- struct{}{}
+		case <-ticker.C:
+			go d.refresh()
+		case <-d.redraw:
+		}
+	}
+}
+
+// cleanup restores the terminal and removes the databases started from the
+// dashboard
+func (d *dashboard) cleanup() error {
+	d.leaveScreen()
+	log.SetOutput(os.Stderr)
+	log.SetFlags(log.LstdFlags)
+	db.PlainProgress = false
+
+	d.mu.Lock()
+	managers := d.managers
+	d.managers = make(map[string]db.DatabaseManager)
+	d.mu.Unlock()
+	for name, manager := range managers {
+		log.Printf("Removing %s...", name)
+		if err := manager.Cleanup(); err != nil {
+			log.Printf("Cleanup error: %v", err)
+		}
+	}
+	return d.cli.Close()
+}
+
+// enterScreen switches to the alternate screen in raw mode
+func (d *dashboard) enterScreen() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return fmt.Errorf("failed to set terminal to raw mode: %v", err)
+	}
+	d.oldState = state
+	fmt.Print("\033[?1049h\033[?25l") // Alternate screen, hide cursor
+	return nil
+}
+
+func (d *dashboard) leaveScreen() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.oldState == nil {
+		return
+	}
+	fmt.Print("\033[?25h\033[?1049l")
+	term.Restore(int(os.Stdin.Fd()), d.oldState)
+	d.oldState = nil
+}
+
+// suspend gives the terminal back while fn runs, e.g. for a client
+func (d *dashboard) suspend(fn func() error) error {
+	d.leaveScreen()
+	err := fn()
+	if err != nil {
+		fmt.Printf("\n%v\nPress enter to return to the dashboard", err)
+		fmt.Scanln()
+	}
+	if err := d.enterScreen(); err != nil {
+		return err
+	}
+	return err
+}
+
+// refresh lists the instances and samples their resources
+func (d *dashboard) refresh() {
+	d.mu.Lock()
+	if d.refreshing {
+		d.mu.Unlock()
+		return
+	}
+	d.refreshing = true
+	logsOf := ""
+	if d.pane == paneLogs {
+		logsOf = d.logsOf
+	}
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.refreshing = false
+		d.mu.Unlock()
+		d.requestRedraw()
+	}()
+
+	ctx := context.Background()
+	instances, err := db.ListInstances(ctx, d.cli)
+	if err != nil {
+		d.setMessage("%v", err)
+		return
+	}
+	d.mu.Lock()
+	d.instances = d.instances[:0]
+	for _, instance := range instances {
+		d.instances = append(d.instances, instanceRow{Instance: instance, stats: d.stats[instance.Name]})
+	}
+	if d.cursor[paneRunning] >= len(d.instances) {
+		d.cursor[paneRunning] = max(len(d.instances)-1, 0)
+	}
+	d.mu.Unlock()
+	d.requestRedraw()
+
+	if logsOf != "" {
+		lines, err := db.InstanceLogs(ctx, d.cli, logsOf, 500)
+		d.mu.Lock()
+		if err != nil {
+			lines = []string{err.Error()}
+		}
+		d.logLines = lines
+		d.mu.Unlock()
+		d.requestRedraw()
+	}
+
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			stats, err := db.InstanceStats(ctx, d.cli, name)
+			if err != nil {
+				return
+			}
+			d.mu.Lock()
+			d.stats[name] = stats
+			for i := range d.instances {
+				if d.instances[i].Name == name {
+					d.instances[i].stats = stats
+				}
+			}
+			d.mu.Unlock()
+		}(instance.Name)
+	}
+	wg.Wait()
+}
+
+// handleKey runs the action of a key and returns whether to quit
+func (d *dashboard) handleKey(key string) bool {
+	d.mu.Lock()
+	pane := d.pane
+	selected := d.selectedInstance()
+	d.mu.Unlock()
+
+	switch key {
+	case "q", "\x03": // Raw mode swallows Ctrl+C, so treat it like 'q'
+		return true
+	case "\t":
+		d.mu.Lock()
+		if d.pane == paneCatalog {
+			d.pane = paneRunning
+		} else {
+			d.pane = paneCatalog
+		}
+		d.mu.Unlock()
+	case "\x1b[A", "k":
+		d.move(-1)
+	case "\x1b[B", "j":
+		d.move(1)
+	case "\x1b[5~":
+		d.move(-10)
+	case "\x1b[6~":
+		d.move(10)
+	case "\x1b":
+		d.mu.Lock()
+		if d.pane == paneLogs {
+			d.pane = paneRunning
+		}
+		d.mu.Unlock()
+	case "\r":
+		if pane == paneCatalog {
+			d.start()
+		} else if selected != "" {
+			d.openClient(selected)
+		}
+	case "c":
+		if selected != "" {
+			d.openClient(selected)
+		}
+	case "w":
+		if selected != "" {
+			d.background("Opening the web interface of "+selected, func() error {
+				return db.OpenInstanceUI(selected, "", false)
+			})
+		}
+	case "l":
+		if selected != "" {
+			d.mu.Lock()
+			d.pane, d.logsOf, d.logLines = paneLogs, selected, nil
+			d.mu.Unlock()
+			go d.refresh()
+		}
+	case "s":
+		if selected != "" {
+			d.background("Snapshotting "+selected, func() error {
+				path, err := db.SnapshotInstance(context.Background(), d.cli, selected, snapshotDir)
+				if err == nil {
+					d.setMessage("Snapshot of %s written to %s", selected, path)
+				}
+				return err
+			})
+		}
+	case "r":
+		if selected != "" {
+			d.background("Restarting "+selected, func() error {
+				if err := db.RestartInstance(context.Background(), d.cli, selected); err != nil {
+					return err
+				}
+				d.setMessage("Restarted %s", selected)
+				return nil
+			})
+		}
+	case "x":
+		if selected != "" {
+			d.background("Stopping "+selected, func() error {
+				return d.stop(selected)
+			})
+		}
+	}
+	return false
+}
+
+// selectedInstance returns the running instance the actions apply to
+func (d *dashboard) selectedInstance() string {
+	switch d.pane {
+	case paneLogs:
+		return d.logsOf
+	case paneRunning:
+		if d.cursor[paneRunning] < len(d.instances) {
+			return d.instances[d.cursor[paneRunning]].Name
+		}
+	}
+	return ""
+}
+
+func (d *dashboard) move(delta int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var count int
+	switch d.pane {
+	case paneRunning:
+		count = len(d.instances)
+	case paneCatalog:
+		count = len(d.catalog)
+	default:
+		return
+	}
+	d.cursor[d.pane] = min(max(d.cursor[d.pane]+delta, 0), max(count-1, 0))
+}
+
+// background runs an action without blocking the dashboard
+func (d *dashboard) background(what string, action func() error) {
+	d.setMessage("%s...", what)
+	go func() {
+		if err := action(); err != nil {
+			d.setMessage("%s failed: %v", what, err)
+		}
+		go d.refresh()
+	}()
+}
+
+// start starts the selected database with its default settings
+func (d *dashboard) start() {
+	d.mu.Lock()
+	entry := d.catalog[d.cursor[paneCatalog]]
+	busy := d.starting[entry.Name]
+	for _, instance := range d.instances {
+		busy = busy || instance.Name == entry.Name
+	}
+	if !busy {
+		d.starting[entry.Name] = true
+	}
+	d.mu.Unlock()
+	if busy {
+		d.setMessage("%s is already running", entry.Name)
+		return
+	}
+
+	d.background("Starting "+entry.Name, func() (err error) {
+		defer func() {
+			d.mu.Lock()
+			delete(d.starting, entry.Name)
+			d.mu.Unlock()
+		}()
+		// Managers panic when they cannot be created
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+
+		info, err := db.GetDatabaseInfo(entry.Name)
+		if err != nil {
+			return err
+		}
+		manager := info.Manager(db.Options{
+			Name:       entry.Name,
+			NoBrowser:  true,
+			ClientMode: db.ClientModeContainer,
+		})
+		if err := manager.StartDatabase(); err != nil {
+			manager.Cleanup()
+			return err
+		}
+		d.mu.Lock()
+		d.managers[entry.Name] = manager
+		d.mu.Unlock()
+		d.setMessage("%s is ready, press tab then c to open its client", entry.Name)
+		return nil
+	})
+}
+
+// stop removes an instance, through its manager when started here
+func (d *dashboard) stop(name string) error {
+	d.mu.Lock()
+	manager, ok := d.managers[name]
+	delete(d.managers, name)
+	if d.logsOf == name && d.pane == paneLogs {
+		d.pane = paneRunning
+	}
+	d.mu.Unlock()
+
+	if ok {
+		if err := manager.Cleanup(); err != nil {
+			return err
+		}
+	} else if err := db.RemoveInstance(context.Background(), d.cli, name); err != nil {
+		return err
+	}
+	d.setMessage("Removed %s", name)
+	return nil
+}
+
+func (d *dashboard) openClient(name string) {
+	if err := d.suspend(func() error {
+		fmt.Printf("Connecting to %s, exit the client to return to the dashboard\n", name)
+		return db.ConnectInstance(name)
+	}); err != nil {
+		d.setMessage("Client of %s failed: %v", name, err)
+	}
+}
+
+// line is a row of the screen, drawn with an optional SGR style
+type line struct {
+	text  string
+	style string
+}
+
+const (
+	styleBold     = "1"
+	styleReverse  = "7"
+	styleDim      = "2"
+	styleSelected = "1;7"
+)
+
+func (d *dashboard) render() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.oldState == nil {
+		return
+	}
+
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 20 || height < 10 {
+		width, height = 80, 24
+	}
+
+	header := fmt.Sprintf("dbin ui  %d running, %d available", len(d.instances), len(d.catalog))
+	lines := []line{{text: padBetween(header, time.Now().Format("15:04:05"), width), style: styleReverse}}
+
+	// The running pane takes up to a third of the screen, the rest goes to
+	// the catalog or the logs
+	body := height - 4
+	runningHeight := min(max(len(d.instances), 1)+2, body/3)
+	lines = append(lines, d.renderRunning(runningHeight)...)
+	if d.pane == paneLogs {
+		lines = append(lines, d.renderLogs(body-runningHeight)...)
+	} else {
+		lines = append(lines, d.renderCatalog(body-runningHeight)...)
+	}
+
+	lines = append(lines, line{text: d.message})
+	keys := "tab databases  enter/c client  w web  l logs  s snapshot  r restart  x stop  q quit"
+	switch d.pane {
+	case paneCatalog:
+		keys = "tab running  up/down select  enter start  q quit"
+	case paneLogs:
+		keys = "esc back  c client  w web  s snapshot  r restart  x stop  q quit"
+	}
+	lines = append(lines, line{text: keys, style: styleDim})
+
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for i, l := range lines {
+		if i >= height {
+			break
+		}
+		text := truncate(l.text, width)
+		if l.style != "" {
+			text = "\033[" + l.style + "m" + text + "\033[0m"
+		}
+		b.WriteString(text + "\033[K")
+		if i < height-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\033[J")
+	fmt.Print(b.String())
+}
+
+func (d *dashboard) renderRunning(height int) []line {
+	title := line{text: "RUNNING", style: styleBold}
+	if d.pane == paneRunning || d.pane == paneLogs {
+		title.style = styleSelected
+	}
+	lines := []line{title, {text: fmt.Sprintf("  %-16s %-11s %7s  %-20s %s", "NAME", "CONTAINERS", "CPU", "MEMORY", "PORT"), style: styleDim}}
+	if len(d.instances) == 0 {
+		return append(lines, line{text: "  No databases running, start one from the list below"})
+	}
+
+	rows := height - 2
+	first := scrollOffset(d.cursor[paneRunning], rows, len(d.instances))
+	for i := first; i < len(d.instances) && i < first+rows; i++ {
+		instance := d.instances[i]
+		cpu, memory := "-", "-"
+		if len(instance.stats) > 0 {
+			var cpuTotal float64
+			var used, limit uint64
+			for _, s := range instance.stats {
+				cpuTotal += s.CPUPercent
+				used += s.MemoryUsage
+				limit = max(limit, s.MemoryLimit)
+			}
+			cpu = fmt.Sprintf("%.1f%%", cpuTotal)
+			memory = fmt.Sprintf("%s / %s", db.FormatSize(int64(used)), db.FormatSize(int64(limit)))
+		}
+		port := instance.Port
+		if port == "" {
+			port = "-"
+		}
+		if _, ok := d.managers[instance.Name]; ok {
+			port += "  (started here)"
+		}
+		l := line{text: fmt.Sprintf("  %-16s %-11s %7s  %-20s %s", instance.Name,
+			fmt.Sprintf("%d/%d", instance.Running, instance.Containers), cpu, memory, port)}
+		if i == d.cursor[paneRunning] && d.pane != paneCatalog {
+			l.text = ">" + l.text[1:]
+			l.style = styleReverse
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func (d *dashboard) renderCatalog(height int) []line {
+	title := line{text: "DATABASES", style: styleBold}
+	if d.pane == paneCatalog {
+		title.style = styleSelected
+	}
+
+	running := make(map[string]bool)
+	for _, instance := range d.instances {
+		running[instance.Name] = true
+	}
+
+	// Category headings are not selectable, so map entries to their rows
+	var rows []line
+	selectedRow := 0
+	category := ""
+	for i, entry := range d.catalog {
+		if entry.Category != category {
+			category = entry.Category
+			rows = append(rows, line{text: "  " + db.CategoryTitle(category), style: styleDim})
+		}
+		state := ""
+		switch {
+		case d.starting[entry.Name]:
+			state = "starting..."
+		case running[entry.Name]:
+			state = "running"
+		}
+		l := line{text: fmt.Sprintf("    %-16s %-12s %s", entry.Name, state, entry.Description)}
+		if i == d.cursor[paneCatalog] {
+			selectedRow = len(rows)
+			if d.pane == paneCatalog {
+				l.text = "  >" + l.text[3:]
+				l.style = styleReverse
+			}
+		}
+		rows = append(rows, l)
+	}
+
+	visible := height - 1
+	first := scrollOffset(selectedRow, visible, len(rows))
+	lines := []line{title}
+	for i := first; i < len(rows) && i < first+visible; i++ {
+		lines = append(lines, rows[i])
+	}
+	return lines
+}
+
+func (d *dashboard) renderLogs(height int) []line {
+	lines := []line{{text: "LOGS " + d.logsOf, style: styleSelected}}
+	logs := d.logLines
+	if len(logs) == 0 {
+		logs = []string{"Loading..."}
+	}
+	if len(logs) > height-1 {
+		logs = logs[len(logs)-(height-1):]
+	}
+	for _, l := range logs {
+		lines = append(lines, line{text: strings.ReplaceAll(l, "\t", "    ")})
+	}
+	return lines
+}
+
+// scrollOffset returns the first row to show so selected is visible
+func scrollOffset(selected, visible, total int) int {
+	if visible <= 0 || total <= visible {
+		return 0
+	}
+	first := selected - visible/2
+	return min(max(first, 0), total-visible)
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return s
+}
+
+func padBetween(left, right string, width int) string {
+	gap := width - len([]rune(left)) - len([]rune(right))
+	if gap < 1 {
+		return left
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"dbin/internal/commands"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ui",
+		Short: "Manage databases from a terminal dashboard",
+		Long: `Open a dashboard listing the running databases with their live CPU and
+memory use, and every supported database by category.
+
+Keys:
+  up/down, j/k  Select
+  tab           Switch between running and available databases
+  enter         Start the selected database, or open the client of a running one
+  c             Open the client of the selected running database
+  w             Open its web interface
+  l             Tail its logs, esc to go back
+  s             Snapshot its data directory to dbin-snapshots/
+  r             Restart it
+  x             Stop and remove it
+  q             Quit
+
+Databases started from the dashboard run with their default settings and
+are removed when it exits, like with dbin <database>. Databases started
+elsewhere, e.g. by dbin up, keep running.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+				return fmt.Errorf("dbin ui needs a terminal")
+			}
+			d, err := newDashboard()
+			if err != nil {
+				return err
+			}
+			done := make(chan error, 1)
+			go func() {
+				done <- d.run()
+			}()
+			return commands.AwaitCleanup("Dashboard", done, d.cleanup)
+		},
+	}
+	return cmd
+}
@@ -8,11 +8,41 @@ import (
 	"time"
 )
 
+var arangoUIs = []WebUI{
+	{
+		Name:        "web",
+		Description: "ArangoDB web interface",
+		Container:   "dbin-arango",
+		Port:        "8529/tcp",
+	},
+}
+
+var arangoCredentials = Credentials{
+	User:     "root",
+	Password: "root",
+}
+
+const arangoImage = "arangodb:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "arango",
-		Description: "ArangoDB multi-model database",
-		Manager:     NewArangoManager,
+		Name:           "arango",
+		Description:    "ArangoDB multi-model database",
+		Category:       CategoryMultiModel,
+		Tags:           []string{"document", "graph", "aql"},
+		Manager:        NewArangoManager,
+		UIs:            arangoUIs,
+		Credentials:    arangoCredentials,
+		Auth:           true,
+		Image:          arangoImage,
+		Port:           "8529/tcp",
+		DataPath:       "/var/lib/arangodb3",
+		Memory:         "1 GB",
+		Readiness:      readinessWebUI,
+		CredentialsEnv: []CredentialsEnv{{Password: "ARANGO_ROOT_PASSWORD"}},
+		Scheme:         "http",
+		Docs:           "https://docs.arangodb.com/",
+		Tutorial:       "https://docs.arangodb.com/stable/get-started/",
 	})
 }
 
@@ -20,8 +50,8 @@ type ArangoManager struct {
 	*BaseManager
 }
 
-func NewArangoManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewArangoManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,7 +64,7 @@ func NewArangoManager(dataDir string, debug bool) DatabaseManager {
 func (am *ArangoManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := am.PullImageIfNeeded(ctx, "arangodb:latest"); err != nil {
+	if err := am.PullImageIfNeeded(ctx, arangoImage); err != nil {
 		return err
 	}
 
@@ -42,8 +72,18 @@ func (am *ArangoManager) StartDatabase() error {
 		"ARANGO_ROOT_PASSWORD=root",
 		"ARANGO_NO_AUTH=1",
 	}
+	if am.opts.Auth {
+		if am.opts.User != "" && am.opts.User != arangoCredentials.User {
+			return fmt.Errorf("arango only supports the %s user", arangoCredentials.User)
+		}
+		creds, err := am.ResolveCredentials(arangoCredentials)
+		if err != nil {
+			return err
+		}
+		env = []string{"ARANGO_ROOT_PASSWORD=" + creds.Password}
+	}
 
-	containerId, port, err := am.CreateContainer(ctx, "arangodb:latest", "dbin-arango", "8529/tcp", env, "/var/lib/arangodb3", nil)
+	containerId, port, err := am.CreateContainer(ctx, arangoImage, "dbin-arango", "8529/tcp", env, "/var/lib/arangodb3", nil)
 	if err != nil {
 		return err
 	}
@@ -51,11 +91,15 @@ func (am *ArangoManager) StartDatabase() error {
 	am.dbPort = port
 
 	log.Printf("ArangoDB is ready and listening on port %s\n", am.dbPort)
+	if am.opts.Auth {
+		log.Printf("Username: %s", am.creds.User)
+		log.Printf("Password: %s", am.creds.Password)
+	}
 	return nil
 }
 
 func (am *ArangoManager) StartClient() error {
-	return StartWebInterface(am.dbPort)
+	return am.OpenWebUI(arangoUIs)
 }
 
 func (am *ArangoManager) Cleanup() error {
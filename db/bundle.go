@@ -0,0 +1,465 @@
+package db
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// Entries of a bundle archive besides the project files
+const (
+	bundleManifest = "manifest.json"
+	bundleImages   = "images.tar"
+	bundleProject  = "project/"
+)
+
+// BundleManifest describes the content of a bundle, with the digests to
+// verify it against when loading
+type BundleManifest struct {
+	Version   int              `json:"version"`
+	Created   time.Time        `json:"created"`
+	Databases []BundleDatabase `json:"databases"`
+	Images    []BundleImage    `json:"images"`
+	Files     []BundleFile     `json:"files"`
+}
+
+// BundleDatabase is a database a bundle can start and the images it needs
+type BundleDatabase struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version,omitempty"`
+	Images  []string `json:"images"`
+}
+
+// BundleImage is an image saved in a bundle. ID is the digest of the image
+// configuration, which docker load keeps.
+type BundleImage struct {
+	Reference   string   `json:"reference"`
+	ID          string   `json:"id"`
+	RepoDigests []string `json:"repo_digests,omitempty"`
+	Size        int64    `json:"size"`
+}
+
+// BundleFile is an entry of a bundle with its sha256 digest
+type BundleFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// projectFile is a file of the project to add to a bundle
+type projectFile struct {
+	source string
+	BundleFile
+}
+
+// CreateBundle writes a bundle with the images of the given databases,
+// pulling those missing, so they can be started without a registry. With a
+// stack, its file and the init scripts it references are included too.
+func CreateBundle(output string, databases []StackDatabase, stack *Stack) (*BundleManifest, error) {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	manifest := &BundleManifest{Version: 1, Created: time.Now().UTC()}
+	var refs []string
+	for _, d := range databases {
+		info, err := GetDatabaseInfo(d.Name)
+		if err != nil {
+			return nil, err
+		}
+		bm := imageManager(cli, d)
+		images, err := bm.platformImages(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range info.Clients {
+			if c.Image != "" {
+				images = append(images, platformImage{ref: c.Image, platform: bm.choosePlatform(ctx, c.Image)})
+			}
+		}
+
+		var names []string
+		for _, img := range images {
+			if contains(names, img.ref) {
+				continue
+			}
+			names = append(names, img.ref)
+			if contains(refs, img.ref) {
+				continue
+			}
+			refs = append(refs, img.ref)
+
+			local, _, err := cli.ImageInspectWithRaw(ctx, img.ref)
+			if err != nil || !bm.checkLocalPlatform(ctx, img.ref, local) {
+				log.Printf("Pulling %s...", img.ref)
+				if err := PullImage(ctx, cli, img.ref, img.platform); err != nil {
+					return nil, fmt.Errorf("%s: %v", img.ref, err)
+				}
+				if local, _, err = cli.ImageInspectWithRaw(ctx, img.ref); err != nil {
+					return nil, fmt.Errorf("failed to inspect image: %v", err)
+				}
+			}
+			manifest.Images = append(manifest.Images, BundleImage{
+				Reference:   img.ref,
+				ID:          local.ID,
+				RepoDigests: local.RepoDigests,
+				Size:        local.Size,
+			})
+		}
+		manifest.Databases = append(manifest.Databases, BundleDatabase{Name: d.Name, Version: d.Version, Images: names})
+	}
+
+	var files []projectFile
+	if stack != nil {
+		if files, err = stackFiles(stack, databases); err != nil {
+			return nil, err
+		}
+	}
+
+	// The archive needs the size of the images up front, so save them to a
+	// temporary file first, next to the output
+	log.Printf("Saving %d images...", len(refs))
+	saved, err := os.CreateTemp(filepath.Dir(output), ".dbin-images-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(saved.Name())
+	defer saved.Close()
+
+	reader, err := cli.ImageSave(ctx, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save images: %v", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(saved, hash), reader)
+	reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to save images: %v", err)
+	}
+	if _, err := saved.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read saved images: %v", err)
+	}
+	manifest.Files = append(manifest.Files, BundleFile{Path: bundleImages, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	for _, f := range files {
+		manifest.Files = append(manifest.Files, f.BundleFile)
+	}
+
+	if err := writeBundle(output, manifest, saved, files); err != nil {
+		os.Remove(output)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// stackFiles returns the stack file and the init scripts of the databases,
+// which must be inside the directory of the stack file to be bundled
+func stackFiles(stack *Stack, databases []StackDatabase) ([]projectFile, error) {
+	root, err := stack.resolve(".")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []projectFile
+	add := func(source string) error {
+		rel, err := filepath.Rel(root, source)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			log.Printf("Warning: %s is outside %s, not bundled", source, root)
+			return nil
+		}
+		return filepath.Walk(source, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %v", p, err)
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, p)
+			f := projectFile{source: p, BundleFile: BundleFile{Path: bundleProject + filepath.ToSlash(rel)}}
+			if f.Size, f.SHA256, err = fileDigest(p); err != nil {
+				return err
+			}
+			files = append(files, f)
+			return nil
+		})
+	}
+
+	stackPath, err := stack.resolve(filepath.Base(stack.Path))
+	if err != nil {
+		return nil, err
+	}
+	if err := add(stackPath); err != nil {
+		return nil, err
+	}
+	for _, d := range databases {
+		if d.Init == "" {
+			continue
+		}
+		init, err := stack.resolve(d.Init)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(init); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func fileDigest(p string) (int64, string, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open %s: %v", p, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read %s: %v", p, err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeBundle writes the manifest first, so loading can read it before the
+// entries it verifies
+func writeBundle(output string, manifest *BundleManifest, images *os.File, files []projectFile) error {
+	out, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %v", err)
+	}
+	defer out.Close()
+	tw := tar.NewWriter(out)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	writeEntry := func(name string, size int64, r io.Reader) error {
+		header := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: manifest.Created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write bundle: %v", err)
+		}
+		if _, err := io.CopyN(tw, r, size); err != nil {
+			return fmt.Errorf("failed to write %s to bundle: %v", name, err)
+		}
+		return nil
+	}
+
+	if err := writeEntry(bundleManifest, int64(len(data)), strings.NewReader(string(data))); err != nil {
+		return err
+	}
+	if err := writeEntry(bundleImages, manifest.Files[0].Size, images); err != nil {
+		return err
+	}
+	for _, f := range files {
+		file, err := os.Open(f.source)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", f.source, err)
+		}
+		err = writeEntry(f.Path, f.Size, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %v", err)
+	}
+	return nil
+}
+
+// LoadBundle verifies a bundle against the digests of its manifest, loads
+// its images into Docker and, with dir, writes its project files there.
+// Existing files are only replaced with force.
+func LoadBundle(bundle, dir string, force bool) (*BundleManifest, error) {
+	manifest, err := VerifyBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	err = readBundle(bundle, func(name string, r io.Reader) error {
+		switch {
+		case name == bundleImages:
+			log.Printf("Loading %d images...", len(manifest.Images))
+			return loadImages(ctx, cli, r)
+		case strings.HasPrefix(name, bundleProject) && dir != "":
+			return extractFile(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, bundleProject))), r, force)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Loaded images must be the ones the manifest lists, under their tags
+	for _, img := range manifest.Images {
+		inspect, _, err := cli.ImageInspectWithRaw(ctx, img.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("%s was not loaded: %v", img.Reference, err)
+		}
+		if inspect.ID != img.ID {
+			return nil, fmt.Errorf("%s has ID %s, the manifest expects %s", img.Reference, inspect.ID, img.ID)
+		}
+		if err := recordPull(img.Reference, inspect); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return manifest, nil
+}
+
+// VerifyBundle reads a bundle and checks its entries against the sizes and
+// digests of its manifest
+func VerifyBundle(bundle string) (*BundleManifest, error) {
+	var manifest *BundleManifest
+	expected := make(map[string]BundleFile)
+	err := readBundle(bundle, func(name string, r io.Reader) error {
+		if manifest == nil {
+			if name != bundleManifest {
+				return fmt.Errorf("%s is not a dbin bundle: it does not start with %s", bundle, bundleManifest)
+			}
+			manifest = &BundleManifest{}
+			if err := json.NewDecoder(r).Decode(manifest); err != nil {
+				return fmt.Errorf("failed to parse manifest: %v", err)
+			}
+			if manifest.Version != 1 {
+				return fmt.Errorf("unsupported bundle version %d", manifest.Version)
+			}
+			for _, f := range manifest.Files {
+				expected[f.Path] = f
+			}
+			return nil
+		}
+
+		f, ok := expected[name]
+		if !ok {
+			return fmt.Errorf("%s is not listed in the manifest", name)
+		}
+		delete(expected, name)
+		hash := sha256.New()
+		size, err := io.Copy(hash, r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		if size != f.Size || hex.EncodeToString(hash.Sum(nil)) != f.SHA256 {
+			return fmt.Errorf("%s does not match its digest in the manifest, the bundle is corrupted", name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("%s is empty", bundle)
+	}
+	for name := range expected {
+		return nil, fmt.Errorf("%s is missing from the bundle", name)
+	}
+	return manifest, nil
+}
+
+// readBundle calls fn with each regular entry of a bundle, refusing paths
+// that would escape the directory they are extracted to
+func readBundle(bundle string, fn func(name string, r io.Reader) error) error {
+	file, err := os.Open(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %v", err)
+	}
+	defer file.Close()
+
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid path %q in bundle", header.Name)
+		}
+		if err := fn(name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func loadImages(ctx context.Context, cli *client.Client, r io.Reader) error {
+	resp, err := cli.ImageLoad(ctx, r, true)
+	if err != nil {
+		return fmt.Errorf("failed to load images: %v", err)
+	}
+	defer resp.Body.Close()
+	if !resp.JSON {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	// Errors are reported in the body of the response
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Stream string `json:"stream"`
+			Error  string `json:"error"`
+		}
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error decoding docker message: %v", err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("failed to load images: %s", msg.Error)
+		}
+		if msg.Stream != "" {
+			log.Print(strings.TrimSpace(msg.Stream))
+		}
+	}
+}
+
+func extractFile(dest string, r io.Reader, force bool) error {
+	if _, err := os.Stat(dest); err == nil && !force {
+		log.Printf("Keeping existing %s, use --force to replace it", dest)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	file, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", dest, err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, r); err != nil {
+		return fmt.Errorf("failed to write %s: %v", dest, err)
+	}
+	return file.Close()
+}
@@ -6,14 +6,42 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
+	"strings"
 	"time"
 )
 
+var cassandraClients = []Client{
+	{
+		Name:        "cqlsh",
+		Description: "Cassandra CQL shell",
+		Command:     "cqlsh",
+		Args: func(conn Connection) []string {
+			if conn.Host != "" {
+				return []string{conn.Host, conn.Port}
+			}
+			return nil
+		},
+	},
+}
+
+const cassandraImage = "cassandra:latest"
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "cassandra",
 		Description: "Cassandra database",
+		Category:    CategoryWideColumn,
+		Tags:        []string{"cql", "nosql", "distributed"},
 		Manager:     NewCassandraManager,
+		Clients:     cassandraClients,
+		Nodes:       true,
+		Image:       cassandraImage,
+		Port:        "9042/tcp",
+		DataPath:    "/var/lib/cassandra",
+		Memory:      "2 GB per node",
+		Readiness:   "Runs nodetool status in the container, up to 30 attempts 2 seconds apart. With --nodes, each node joins the ring in turn",
+		Docs:        "https://cassandra.apache.org/doc/latest/",
+		Tutorial:    "https://cassandra.apache.org/_/quickstart.html",
 	})
 }
 
@@ -21,8 +49,8 @@ type CassandraManager struct {
 	*BaseManager
 }
 
-func NewCassandraManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewCassandraManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -35,11 +63,15 @@ func NewCassandraManager(dataDir string, debug bool) DatabaseManager {
 func (cm *CassandraManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := cm.PullImageIfNeeded(ctx, "cassandra:latest"); err != nil {
+	if err := cm.PullImageIfNeeded(ctx, cassandraImage); err != nil {
 		return err
 	}
 
-	containerId, port, err := cm.CreateContainer(ctx, "cassandra:latest", "dbin-cassandra", "9042/tcp", nil, "/var/lib/cassandra", nil)
+	if cm.Clustered() {
+		return cm.startRing(ctx)
+	}
+
+	containerId, port, err := cm.CreateContainer(ctx, cassandraImage, "dbin-cassandra", "9042/tcp", nil, "/var/lib/cassandra", nil)
 	if err != nil {
 		return err
 	}
@@ -69,8 +101,58 @@ func (cm *CassandraManager) waitForDatabase() error {
 	return fmt.Errorf("timeout waiting for Cassandra to be ready")
 }
 
+// startRing starts a Cassandra ring seeded by the first node. Nodes join one
+// at a time, as Cassandra does not support concurrent bootstraps.
+func (cm *CassandraManager) startRing(ctx context.Context) error {
+	networkName, err := cm.CreateStackNetwork(ctx)
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image: cassandraImage,
+		Port:  "9042/tcp",
+		Env: []string{
+			"CASSANDRA_CLUSTER_NAME=dbin",
+			"CASSANDRA_SEEDS=" + NodeContainer(cm.opts.Name, 1),
+			"CASSANDRA_ENDPOINT_SNITCH=GossipingPropertyFileSnitch",
+			"MAX_HEAP_SIZE=512M",
+			"HEAP_NEWSIZE=128M",
+		},
+		VolumePath: "/var/lib/cassandra",
+	}
+
+	for i := 1; i <= cm.Nodes(); i++ {
+		if _, _, err := cm.StartNode(ctx, spec, networkName, i); err != nil {
+			return err
+		}
+
+		joined := i
+		err := WaitForNode(cm.dbContainerId, fmt.Sprintf("%d nodes to join the ring", joined), 90, func(output string) bool {
+			return countUpNormal(output) >= joined
+		}, "nodetool", "status")
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Cassandra ring is ready with %d nodes, the seed listens on port %s\n", cm.Nodes(), cm.dbPort)
+	return nil
+}
+
+// countUpNormal counts the nodes reported as Up/Normal by nodetool status
+func countUpNormal(status string) int {
+	count := 0
+	for _, line := range strings.Split(status, "\n") {
+		if strings.HasPrefix(line, "UN ") {
+			count++
+		}
+	}
+	return count
+}
+
 func (cm *CassandraManager) StartClient() error {
-	return cm.StartContainerClient("cqlsh")
+	return cm.StartClientFrom(cassandraClients)
 }
 
 func (cm *CassandraManager) Cleanup() error {
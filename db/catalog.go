@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+)
+
+// CatalogEntry describes a supported database, as shown by dbin list
+type CatalogEntry struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+	Image       string   `json:"image"`
+	Ports       []string `json:"ports"`
+	ClientTypes []string `json:"client_types"` // cli and/or web
+	Clients     []string `json:"clients,omitempty"`
+	WebUIs      []string `json:"web_uis,omitempty"`
+	Docs        string   `json:"docs,omitempty"`
+	Tutorial    string   `json:"tutorial,omitempty"`
+	Pulled      []string `json:"pulled"` // Tags of the image available locally, nil when unknown
+}
+
+// Catalog returns the supported databases in the given category, or all of
+// them when empty, whose name, description, category or tags contain search
+func Catalog(category, search string) ([]CatalogEntry, error) {
+	if category != "" && !contains(Categories, category) {
+		return nil, fmt.Errorf("unknown category %q (available: %s)", category, strings.Join(Categories, ", "))
+	}
+	search = strings.ToLower(search)
+
+	var entries []CatalogEntry
+	for _, info := range GetAllDatabases() {
+		if category != "" && info.Category != category {
+			continue
+		}
+		if search != "" && !matchesSearch(info, search) {
+			continue
+		}
+		entries = append(entries, catalogEntry(info))
+	}
+
+	order := make(map[string]int)
+	for i, c := range Categories {
+		order[c] = i
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Category != entries[j].Category {
+			return order[entries[i].Category] < order[entries[j].Category]
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+func matchesSearch(info DatabaseInfo, search string) bool {
+	fields := append([]string{info.Name, info.Description, info.Category, CategoryTitle(info.Category), info.Image}, info.Tags...)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), search) {
+			return true
+		}
+	}
+	return false
+}
+
+func catalogEntry(info DatabaseInfo) CatalogEntry {
+	entry := CatalogEntry{
+		Name:        info.Name,
+		Description: info.Description,
+		Category:    info.Category,
+		Tags:        info.Tags,
+		Image:       info.Image,
+		Docs:        info.Docs,
+		Tutorial:    info.Tutorial,
+	}
+	if info.Port != "" {
+		entry.Ports = append(entry.Ports, info.Port)
+	}
+	for _, ui := range info.UIs {
+		if !contains(entry.Ports, ui.Port) {
+			entry.Ports = append(entry.Ports, ui.Port)
+		}
+		entry.WebUIs = append(entry.WebUIs, ui.Name)
+	}
+	for _, c := range info.Clients {
+		entry.Clients = append(entry.Clients, c.Name)
+	}
+	if len(info.Clients) > 0 {
+		entry.ClientTypes = append(entry.ClientTypes, "cli")
+	}
+	if len(info.UIs) > 0 {
+		entry.ClientTypes = append(entry.ClientTypes, "web")
+	}
+	return entry
+}
+
+// FillPulled sets the tags of the images of entries that are pulled locally
+func FillPulled(entries []CatalogEntry) error {
+	local, err := LocalImageTags()
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		repository, _ := normalizeImage(entries[i].Image)
+		entries[i].Pulled = append([]string{}, local[repository]...)
+	}
+	return nil
+}
+
+// LocalImageTags returns the tags of the local images by repository
+func LocalImageTags() (map[string][]string, error) {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	images, err := cli.ImageList(context.Background(), image.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %v", err)
+	}
+	tags := make(map[string][]string)
+	for _, img := range images {
+		for _, ref := range img.RepoTags {
+			repository, tag := normalizeImage(ref)
+			tags[repository] = append(tags[repository], tag)
+		}
+	}
+	for _, t := range tags {
+		sort.Strings(t)
+	}
+	return tags, nil
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+// netemImage runs tc in the network namespace of a target container
+const netemImage = "nicolaka/netshoot"
+
+// Chaos injects failures into the containers of a running instance
+type Chaos struct {
+	cli      *client.Client
+	instance string
+}
+
+// NewChaos returns a Chaos for a running instance
+func NewChaos(instance string) (*Chaos, error) {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	return &Chaos{cli: cli, instance: instance}, nil
+}
+
+// Close releases the Docker client
+func (c *Chaos) Close() error {
+	return c.cli.Close()
+}
+
+// Target resolves a node of the instance: empty for the main container, a
+// node number, or a container name with or without the dbin-<instance>-
+// prefix (e.g. "kibana" for elasticsearch)
+func (c *Chaos) Target(node string) (string, error) {
+	containers, err := c.cli.ContainerList(context.Background(), container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", labelInstance+"="+c.instance)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	var names []string
+	for _, ctr := range containers {
+		for _, name := range ctr.Names {
+			names = append(names, strings.TrimPrefix(name, "/"))
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("%s is not running, start it with 'dbin %s'", c.instance, c.instance)
+	}
+	sort.Strings(names)
+
+	candidates := []string{InstanceContainer(c.instance)}
+	if node != "" {
+		candidates = []string{node, InstanceContainer(c.instance) + "-" + node}
+		if i, err := strconv.Atoi(node); err == nil {
+			candidates = []string{NodeContainer(c.instance, i)}
+		}
+	}
+	for _, candidate := range candidates {
+		for _, name := range names {
+			if name == candidate {
+				return name, nil
+			}
+		}
+	}
+
+	if node == "" {
+		// Stacks without a main container, like dgraph
+		return names[0], nil
+	}
+	return "", fmt.Errorf("no node %q in %s (available: %s)", node, c.instance, strings.Join(names, ", "))
+}
+
+// Pause freezes all processes of the node
+func (c *Chaos) Pause(node string) error {
+	return c.apply(node, "Pausing", func(ctx context.Context, name string) error {
+		return c.cli.ContainerPause(ctx, name)
+	})
+}
+
+// Unpause resumes a paused node
+func (c *Chaos) Unpause(node string) error {
+	return c.apply(node, "Unpausing", func(ctx context.Context, name string) error {
+		return c.cli.ContainerUnpause(ctx, name)
+	})
+}
+
+// Kill stops the node abruptly with SIGKILL, leaving the container in place
+func (c *Chaos) Kill(node string) error {
+	return c.apply(node, "Killing", func(ctx context.Context, name string) error {
+		return c.cli.ContainerKill(ctx, name, "SIGKILL")
+	})
+}
+
+// Start starts a killed node again
+func (c *Chaos) Start(node string) error {
+	return c.apply(node, "Starting", func(ctx context.Context, name string) error {
+		return c.cli.ContainerStart(ctx, name, container.StartOptions{})
+	})
+}
+
+// Restart stops and starts the node
+func (c *Chaos) Restart(node string) error {
+	return c.apply(node, "Restarting", func(ctx context.Context, name string) error {
+		return c.cli.ContainerRestart(ctx, name, container.StopOptions{})
+	})
+}
+
+// Partition disconnects the node from the stack network, isolating it from
+// the other containers of the instance
+func (c *Chaos) Partition(node string) error {
+	return c.apply(node, "Partitioning", func(ctx context.Context, name string) error {
+		return c.cli.NetworkDisconnect(ctx, StackNetwork(c.instance), name, false)
+	})
+}
+
+// Heal reconnects a partitioned node to the stack network
+func (c *Chaos) Heal(node string) error {
+	return c.apply(node, "Healing", func(ctx context.Context, name string) error {
+		return c.cli.NetworkConnect(ctx, StackNetwork(c.instance), name, &network.EndpointSettings{
+			Aliases: []string{name},
+		})
+	})
+}
+
+// Netem describes the network degradation applied with tc netem
+type Netem struct {
+	Delay  string // e.g. 200ms
+	Jitter string // Requires Delay
+	Loss   string // Percentage of dropped packets, e.g. 5 or 5%
+}
+
+func (n Netem) args() []string {
+	var args []string
+	if n.Delay != "" {
+		args = append(args, "delay", n.Delay)
+		if n.Jitter != "" {
+			args = append(args, n.Jitter)
+		}
+	}
+	if n.Loss != "" {
+		args = append(args, "loss", strings.TrimSuffix(n.Loss, "%")+"%")
+	}
+	return args
+}
+
+// Degrade adds latency or packet loss to every interface of the node,
+// replacing any previous degradation
+func (c *Chaos) Degrade(node string, netem Netem) error {
+	args := netem.args()
+	if len(args) == 0 {
+		return fmt.Errorf("no delay or loss given")
+	}
+	script := fmt.Sprintf("for dev in $(ls /sys/class/net); do [ \"$dev\" = lo ] || tc qdisc replace dev \"$dev\" root netem %s || exit 1; done", strings.Join(args, " "))
+	return c.apply(node, "Degrading network of", func(ctx context.Context, name string) error {
+		return c.runNetem(name, script)
+	})
+}
+
+// Clear removes the latency and packet loss added by Degrade
+func (c *Chaos) Clear(node string) error {
+	script := "for dev in $(ls /sys/class/net); do [ \"$dev\" = lo ] || tc qdisc del dev \"$dev\" root 2>/dev/null; done; true"
+	return c.apply(node, "Clearing network degradation of", func(ctx context.Context, name string) error {
+		return c.runNetem(name, script)
+	})
+}
+
+// runNetem runs script in a sidecar sharing the network namespace of the
+// container, so the database image doesn't need tc
+func (c *Chaos) runNetem(name string, script string) error {
+	cmd := exec.Command("docker", "run", "--rm",
+		"--network", "container:"+name,
+		"--cap-add", "NET_ADMIN",
+		netemImage, "sh", "-c", script)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("tc failed: %v", err)
+	}
+	return nil
+}
+
+func (c *Chaos) apply(node string, verb string, action func(ctx context.Context, name string) error) error {
+	name, err := c.Target(node)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s %s", verb, name)
+	if err := action(context.Background(), name); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
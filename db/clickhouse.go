@@ -5,16 +5,55 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
-	"os/exec"
 	"time"
 )
 
+var clickhouseCredentials = Credentials{
+	User:     "default",
+	Password: "clickhouse",
+}
+
+var clickhouseClients = []Client{
+	{
+		Name:        "clickhouse-client",
+		Description: "ClickHouse native client",
+		Command:     "clickhouse-client",
+		Args: func(conn Connection) []string {
+			args := []string{"--user", conn.User, "--password", conn.Password}
+			if conn.Host != "" {
+				args = append(args, "--host", conn.Host, "--port", conn.Port)
+			}
+			return args
+		},
+		Exec: []string{"--multiquery"},
+		Formats: map[string][]string{
+			"table": {"--format", "PrettyCompact"},
+			"csv":   {"--format", "CSVWithNames"},
+			"json":  {"--format", "JSONEachRow"},
+		},
+	},
+}
+
+const clickhouseImage = "clickhouse/clickhouse-server:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "clickhouse",
-		Description: "ClickHouse database",
-		Manager:     NewClickHouseManager,
+		Name:           "clickhouse",
+		Description:    "ClickHouse database",
+		Category:       CategoryRelational,
+		Tags:           []string{"sql", "olap", "columnar"},
+		Manager:        NewClickHouseManager,
+		Clients:        clickhouseClients,
+		Credentials:    clickhouseCredentials,
+		Image:          clickhouseImage,
+		Port:           "9000/tcp",
+		DataPath:       "/var/lib/clickhouse",
+		Memory:         "1 GB",
+		Readiness:      readinessClient,
+		CredentialsEnv: []CredentialsEnv{{User: "CLICKHOUSE_USER", Password: "CLICKHOUSE_PASSWORD"}},
+		InitDir:        "/docker-entrypoint-initdb.d",
+		Docs:           "https://clickhouse.com/docs",
+		Tutorial:       "https://clickhouse.com/docs/en/tutorial",
 	})
 }
 
@@ -22,8 +61,8 @@ type ClickHouseManager struct {
 	*BaseManager
 }
 
-func NewClickHouseManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewClickHouseManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -36,18 +75,23 @@ func NewClickHouseManager(dataDir string, debug bool) DatabaseManager {
 func (chm *ClickHouseManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := chm.PullImageIfNeeded(ctx, "clickhouse/clickhouse-server:latest"); err != nil {
+	if err := chm.PullImageIfNeeded(ctx, clickhouseImage); err != nil {
+		return err
+	}
+
+	creds, err := chm.ResolveCredentials(clickhouseCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
 		"CLICKHOUSE_DB=default",
-		"CLICKHOUSE_USER=default",
+		"CLICKHOUSE_USER=" + creds.User,
 		"CLICKHOUSE_DEFAULT_ACCESS_MANAGEMENT=1",
-		"CLICKHOUSE_PASSWORD=clickhouse",
+		"CLICKHOUSE_PASSWORD=" + creds.Password,
 	}
 
-	containerId, port, err := chm.CreateContainer(ctx, "clickhouse/clickhouse-server:latest", "dbin-clickhouse", "9000/tcp", env, "/var/lib/clickhouse", nil)
+	containerId, port, err := chm.CreateContainer(ctx, clickhouseImage, "dbin-clickhouse", "9000/tcp", env, "/var/lib/clickhouse", nil)
 	if err != nil {
 		return err
 	}
@@ -59,22 +103,7 @@ func (chm *ClickHouseManager) StartDatabase() error {
 }
 
 func (chm *ClickHouseManager) StartClient() error {
-	for i := 0; i < 5; i++ {
-		cmd := exec.Command("docker", "exec", "-it", chm.dbContainerId, "clickhouse-client", "--password", "clickhouse")
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		
-		if err := cmd.Run(); err == nil {
-			return nil
-		}
-		
-		if i < 4 { // Don't sleep after last attempt
-			log.Printf("Failed to connect, retrying in 5 seconds (attempt %d/5)...", i+1)
-			time.Sleep(5 * time.Second)
-		}
-	}
-	return fmt.Errorf("failed to connect after 5 attempts")
+	return chm.StartClientFrom(clickhouseClients)
 }
 
 func (chm *ClickHouseManager) Cleanup() error {
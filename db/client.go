@@ -0,0 +1,236 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Client modes accepted by --client
+const (
+	ClientModeContainer = "container"
+	ClientModeHost      = "host"
+	ClientModeAuto      = "auto"
+)
+
+// Connection holds what a client needs to reach the database. Host and Port
+// are empty when the client runs inside the database container.
+type Connection struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	TLS      *TLSFiles // Set when the instance was started with --tls
+	Cluster  bool      // Set when the instance runs several nodes
+}
+
+// sidecarPythonImage runs Python-based REPLs that are installed on start
+const sidecarPythonImage = "python:3.12-slim"
+
+// Client describes a command-line client of a database. The first client in
+// a database's list is its native client and the default.
+type Client struct {
+	Name        string
+	Description string
+	Command     string
+	// HostCommand is the binary looked up on the host when it differs from Command
+	HostCommand string
+	Args        func(conn Connection) []string
+	Env         func(conn Connection) []string
+	// Image runs the client from a sidecar container sharing the network of
+	// the database container, so it doesn't need to exist in the server image
+	Image string
+	// Install is a shell command run once in Image, which is then saved as
+	// the dbin-client-<name> image later sidecars start from
+	Install string
+	// NoTLS is set for clients that can't connect to a --tls instance
+	NoTLS bool
+	// Port is the container port a sidecar client connects to
+	Port string
+	// Exec holds extra arguments used when running a script read from stdin
+	Exec []string
+	// Formats maps the output formats supported by dbin exec to client arguments
+	Formats map[string][]string
+}
+
+func (c Client) hostCommand() string {
+	if c.HostCommand != "" {
+		return c.HostCommand
+	}
+	return c.Command
+}
+
+func (c Client) args(conn Connection) []string {
+	if c.Args == nil {
+		return nil
+	}
+	return c.Args(conn)
+}
+
+func (c Client) env(conn Connection) []string {
+	if c.Env == nil {
+		return nil
+	}
+	return c.Env(conn)
+}
+
+// ParseClientFlag splits a --client value into a client name and a mode. The
+// value is either a mode (host, container or auto) or the name of a client.
+func ParseClientFlag(value string) (name string, mode string) {
+	switch value {
+	case "", ClientModeContainer, ClientModeHost, ClientModeAuto:
+		return "", value
+	}
+	return value, ClientModeContainer
+}
+
+// SelectClient returns the client with the given name, or the default client
+// when name is empty
+func SelectClient(clients []Client, name string) (Client, error) {
+	if len(clients) == 0 {
+		return Client{}, fmt.Errorf("no clients available")
+	}
+	if name == "" {
+		return clients[0], nil
+	}
+
+	var names []string
+	for _, c := range clients {
+		if c.Name == name {
+			return c, nil
+		}
+		names = append(names, c.Name)
+	}
+	return Client{}, fmt.Errorf("unknown client %q (available: %s, or a mode: host, container, auto)", name, strings.Join(names, ", "))
+}
+
+// StartClientFrom starts the client selected with --client from the
+// database's client registry
+func (bm *BaseManager) StartClientFrom(clients []Client) error {
+	c, err := SelectClient(clients, bm.opts.Client)
+	if err != nil {
+		return err
+	}
+	if c.Image != "" {
+		return bm.startSidecarClient(c)
+	}
+	return bm.StartClientFor(c)
+}
+
+// StartClientFor starts the given client either inside the database
+// container or on the host, depending on the selected client mode
+func (bm *BaseManager) StartClientFor(c Client) error {
+	switch bm.opts.ClientMode {
+	case ClientModeHost:
+		path, err := exec.LookPath(c.hostCommand())
+		if err != nil {
+			return fmt.Errorf("%s not found on PATH, install it or use --client container", c.hostCommand())
+		}
+		return bm.startHostClient(path, c)
+	case ClientModeAuto:
+		if path, err := exec.LookPath(c.hostCommand()); err == nil {
+			return bm.startHostClient(path, c)
+		}
+		log.Printf("%s not found on PATH, using the client inside the container", c.hostCommand())
+	}
+
+	conn := Connection{
+		User:     bm.creds.User,
+		Password: bm.creds.Password,
+		TLS:      bm.containerTLSFiles(),
+		Cluster:  bm.Clustered(),
+	}
+	if bm.Proxying() {
+		conn.Host, conn.Port = proxyHost, bm.dbPort
+	}
+	return bm.startContainerClient(c.env(conn), c.Command, c.args(conn)...)
+}
+
+func (bm *BaseManager) startHostClient(path string, c Client) error {
+	conn := Connection{
+		Host:     "127.0.0.1",
+		Port:     bm.dbPort,
+		User:     bm.creds.User,
+		Password: bm.creds.Password,
+		TLS:      bm.hostTLSFiles(),
+		Cluster:  bm.Clustered(),
+	}
+	log.Printf("Starting %s on the host", path)
+	return runClientWithRetry(func() *exec.Cmd {
+		cmd := exec.Command(path, c.args(conn)...)
+		cmd.Env = append(os.Environ(), c.env(conn)...)
+		return cmd
+	})
+}
+
+func (bm *BaseManager) startSidecarClient(c Client) error {
+	sidecarImage, err := bm.sidecarImage(context.Background(), c)
+	if err != nil {
+		return err
+	}
+
+	conn := Connection{
+		Host:     "127.0.0.1",
+		Port:     c.Port,
+		User:     bm.creds.User,
+		Password: bm.creds.Password,
+		Cluster:  bm.Clustered(),
+	}
+	if bm.Proxying() {
+		conn.Host, conn.Port = proxyHost, bm.dbPort
+	}
+
+	args := []string{
+		"run", "--rm", "-it",
+		"--name", fmt.Sprintf("dbin-client-%s-%d", c.Name, os.Getpid()),
+		"--network", "container:" + bm.dbContainerId,
+	}
+	if bm.tlsDir != "" {
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", bm.tlsDir, tlsMountPath))
+		conn.TLS = bm.containerTLSFiles()
+	}
+	for _, e := range c.env(conn) {
+		args = append(args, "-e", e)
+	}
+	args = append(args, sidecarImage, c.Command)
+	args = append(args, c.args(conn)...)
+
+	log.Printf("Starting %s from a %s sidecar container", c.Name, sidecarImage)
+	return runClientWithRetry(func() *exec.Cmd {
+		return exec.Command("docker", args...)
+	})
+}
+
+// sidecarImage returns the image a sidecar client runs from. A client with an
+// Install command is installed the first time only, into an image reused by
+// later runs and retries.
+func (bm *BaseManager) sidecarImage(ctx context.Context, c Client) (string, error) {
+	if c.Install == "" {
+		if err := bm.PullImageIfNeeded(ctx, c.Image); err != nil {
+			return "", err
+		}
+		return bm.image(c.Image), nil
+	}
+
+	installed := "dbin-client-" + c.Name
+	if _, _, err := bm.dockerCli.ImageInspectWithRaw(ctx, installed); err == nil {
+		return installed, nil
+	}
+	if err := bm.PullImageIfNeeded(ctx, c.Image); err != nil {
+		return "", err
+	}
+
+	log.Printf("Installing %s into the %s image", c.Name, installed)
+	builder := fmt.Sprintf("dbin-client-%s-install-%d", c.Name, os.Getpid())
+	defer exec.Command("docker", "rm", "-f", builder).Run()
+	if out, err := exec.Command("docker", "run", "--name", builder, bm.image(c.Image), "sh", "-c", c.Install).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to install %s: %v: %s", c.Name, err, out)
+	}
+	if out, err := exec.Command("docker", "commit", builder, installed).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to save %s: %v: %s", installed, err, out)
+	}
+	return installed, nil
+}
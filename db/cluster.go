@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// Labels set on the containers and networks dbin creates
+const (
+	labelInstance = "dbin.instance" // Database name in the registry
+	labelNode     = "dbin.node"     // Node number, starting at 1
+	labelNodes    = "dbin.nodes"    // Number of nodes of the instance
+	labelPort     = "dbin.port"     // Container port of the main container
+	labelAuth     = "dbin.auth"     // Set when started with --auth
+)
+
+// NodeContainer returns the container name of node i of an instance. The
+// first node keeps the single-node name, so clients and exec reach it.
+func NodeContainer(name string, i int) string {
+	if i <= 1 {
+		return InstanceContainer(name)
+	}
+	return fmt.Sprintf("%s-%d", InstanceContainer(name), i)
+}
+
+// StackNetwork returns the name of the network shared by the containers of
+// an instance
+func StackNetwork(name string) string {
+	return InstanceContainer(name) + "-net"
+}
+
+// Nodes returns the number of nodes requested with --nodes
+func (bm *BaseManager) Nodes() int {
+	if bm.opts.Nodes < 1 {
+		return 1
+	}
+	return bm.opts.Nodes
+}
+
+// Clustered reports whether the instance runs several nodes
+func (bm *BaseManager) Clustered() bool {
+	return bm.Nodes() > 1
+}
+
+// CreateStackNetwork creates the network shared by the nodes of the
+// instance. It is removed by Cleanup.
+func (bm *BaseManager) CreateStackNetwork(ctx context.Context) (string, error) {
+	name := StackNetwork(bm.opts.Name)
+	resp, err := bm.dockerCli.NetworkCreate(ctx, name, network.CreateOptions{
+		Labels: map[string]string{labelInstance: bm.opts.Name},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create network: %v", err)
+	}
+	bm.networkId = resp.ID
+	return name, nil
+}
+
+// StartNode creates node i of the instance on network. Node 1 becomes the
+// main container, the others are removed along with it by Cleanup.
+func (bm *BaseManager) StartNode(ctx context.Context, spec ContainerSpec, networkName string, i int) (string, string, error) {
+	spec.Name = NodeContainer(bm.opts.Name, i)
+	spec.Network = networkName
+	if bm.Clustered() {
+		spec.DataSubdir = fmt.Sprintf("node%d", i)
+	}
+
+	labels := map[string]string{
+		labelNode:  strconv.Itoa(i),
+		labelNodes: strconv.Itoa(bm.Nodes()),
+	}
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	spec.Labels = labels
+
+	log.Printf("Starting node %d/%d (%s)...", i, bm.Nodes(), spec.Name)
+	containerId, port, err := bm.CreateContainerWithSpec(ctx, spec)
+	if err != nil {
+		return "", "", err
+	}
+	if i <= 1 {
+		bm.dbContainerId = containerId
+		bm.dbPort = port
+	} else {
+		bm.nodeIds = append(bm.nodeIds, containerId)
+	}
+	return containerId, port, nil
+}
+
+// WaitForNode runs cmd in a container until it succeeds and check, when
+// given, accepts its output
+func WaitForNode(containerId string, what string, attempts int, check func(output string) bool, cmd ...string) error {
+	for i := 0; i < attempts; i++ {
+		output, err := exec.Command("docker", append([]string{"exec", containerId}, cmd...)...).CombinedOutput()
+		if err == nil && (check == nil || check(string(output))) {
+			return nil
+		}
+		log.Printf("Waiting for %s (attempt %d/%d)...", what, i+1, attempts)
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("timeout waiting for %s", what)
+}
+
+// labelledNodes returns the number of nodes recorded on a container
+func labelledNodes(labels map[string]string) int {
+	nodes, err := strconv.Atoi(labels[labelNodes])
+	if err != nil {
+		return 1
+	}
+	return nodes
+}
+
+// nodeAddress returns the IP address of a container on network
+func (bm *BaseManager) nodeAddress(ctx context.Context, containerId string, networkName string) (string, error) {
+	inspect, err := bm.dockerCli.ContainerInspect(ctx, containerId)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %v", err)
+	}
+	endpoint, ok := inspect.NetworkSettings.Networks[networkName]
+	if !ok || endpoint.IPAddress == "" {
+		return "", fmt.Errorf("container %s is not connected to %s", containerId, networkName)
+	}
+	return endpoint.IPAddress, nil
+}
+
+// nodeNames returns the container names of all the nodes of the instance
+func (bm *BaseManager) nodeNames() []string {
+	var names []string
+	for i := 1; i <= bm.Nodes(); i++ {
+		names = append(names, NodeContainer(bm.opts.Name, i))
+	}
+	return names
+}
+
+// cleanupNodes removes the nodes started after the first one
+func (bm *BaseManager) cleanupNodes(ctx context.Context) {
+	for _, id := range bm.nodeIds {
+		log.Printf("Removing node %s...", id)
+		if err := bm.dockerCli.ContainerRemove(ctx, id, container.RemoveOptions{Force: true}); err != nil {
+			log.Printf("Warning: Failed to remove node: %v", err)
+		}
+	}
+	bm.nodeIds = nil
+}
+
+// cleanupNetwork removes the network created by CreateStackNetwork
+func (bm *BaseManager) cleanupNetwork(ctx context.Context) {
+	if bm.networkId == "" {
+		return
+	}
+	if err := bm.dockerCli.NetworkRemove(ctx, bm.networkId); err != nil {
+		log.Printf("Warning: Failed to remove network: %v", err)
+	}
+	bm.networkId = ""
+}
+
+// joinHosts returns the nodes as host:port pairs
+func joinHosts(names []string, port string) string {
+	hosts := make([]string, len(names))
+	for i, name := range names {
+		hosts[i] = name + ":" + port
+	}
+	return strings.Join(hosts, ",")
+}
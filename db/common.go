@@ -2,35 +2,26 @@ package db
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"dbin/internal/proxy"
+	"dbin/internal/trace"
+
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
-type dockerMessage struct {
-	Status         string `json:"status"`
-	ID            string `json:"id"`
-	Progress      string `json:"progress"`
-	ProgressDetail struct {
-		Current int64 `json:"current"`
-		Total   int64 `json:"total"`
-	} `json:"progressDetail"`
-}
-
-type progressInfo struct {
-	current int64
-	total   int64
-}
-
 // Common interface for database managers
 type DatabaseManager interface {
 	StartDatabase() error
@@ -38,17 +29,61 @@ type DatabaseManager interface {
 	Cleanup() error
 }
 
+// Options holds the per-run settings passed to database managers
+type Options struct {
+	Name       string // Name of the database in the registry
+	DataDir    string
+	Debug      bool
+	NoBrowser  bool
+	UI         string
+	ClientMode string
+	Client     string
+
+	User              string
+	Password          string
+	RandomCredentials bool
+
+	TLS  bool
+	MTLS bool // Also issue client certificates
+	Auth bool // Enable security on databases that run without it by default
+
+	Nodes int // Number of nodes for databases supporting --nodes
+
+	Proxy bool // Put a fault-injecting proxy in front of the database port
+
+	TraceQueries string // Log queries seen by the proxy, "-" for the terminal or a JSONL file
+
+	Version string // Tag of the main image, instead of the one dbin defaults to
+	Port    string // Host port of the main container, random when empty
+	Init    string // Init script, or directory of scripts, run on first start
+
+	Env     []string // Extra KEY=value variables of the main container, overriding dbin's
+	Volumes []string // Extra source:target[:mode] mounts of the main container
+
+	LockFile string // Lockfile pinning images, dbin.lock of the current directory when empty
+	Platform string // Platform to run images for, e.g. linux/amd64, instead of the daemon's
+}
+
 // Base structure for all database managers
 type BaseManager struct {
-	dataDir       string
+	opts          Options
 	dockerCli     *client.Client
 	dbContainerId string
 	dbPort        string
-	debug         bool
+	creds         Credentials
+	tlsDir        string
+	networkId     string   // Set by CreateStackNetwork
+	nodeIds       []string // Nodes other than the main container
+	proxy         *proxy.Proxy
+	queryLog      *trace.Logger
+	lock          *Lock // Nil without a lockfile
+	hostPlatform  *ocispec.Platform
+	platforms     map[string]*ocispec.Platform // Platforms of images that are not the daemon's
+	archNoted     bool
 }
 
-// NewBaseManager creates a new base manager with Docker client
-func NewBaseManager(dataDir string, debug bool) (*BaseManager, error) {
+// NewDockerClient creates a Docker client configured from the environment
+func NewDockerClient() (*client.Client, error) {
 	cli, err := client.NewClientWithOpts(
 		client.FromEnv,
 		client.WithVersion("1.46"),
@@ -56,85 +91,115 @@ func NewBaseManager(dataDir string, debug bool) (*BaseManager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %v", err)
 	}
+	return cli, nil
+}
+
+// NewBaseManager creates a new base manager with Docker client
+func NewBaseManager(opts Options) (*BaseManager, error) {
+	lockFile := opts.LockFile
+	if lockFile == "" {
+		lockFile = LockFile
+	}
+	lock, err := LoadLock(lockFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
 
 	return &BaseManager{
-		dataDir:   dataDir,
+		opts:      opts,
 		dockerCli: cli,
-		debug:     debug,
+		lock:      lock,
 	}, nil
 }
 
-// PullImageIfNeeded pulls the Docker image if it's not present locally
-func (bm *BaseManager) PullImageIfNeeded(ctx context.Context, imageName string) error {
-	_, _, err := bm.dockerCli.ImageInspectWithRaw(ctx, imageName)
-	if err != nil {
-		log.Printf("%s image not found locally, pulling...\n", imageName)
-		reader, err := bm.dockerCli.ImagePull(ctx, imageName, image.PullOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to pull image: %v", err)
-		}
-		defer reader.Close()
-		
-		// Process and display pull progress
-		decoder := json.NewDecoder(reader)
-		type layerProgress struct {
-			id   string
-			info *progressInfo
-		}
-		var layers []layerProgress
-		layerMap := make(map[string]int) // Map ID to index in layers slice
-
-		for decoder.More() {
-			var msg dockerMessage
-			if err := decoder.Decode(&msg); err != nil {
-				if err == io.EOF {
-					break
-				}
-				return fmt.Errorf("error decoding docker message: %v", err)
-			}
+// image returns the reference to run for imageName, applying --version to
+// the main image of the database and its companions, and the digest the
+// lockfile pins it to
+func (bm *BaseManager) image(imageName string) string {
+	ref := bm.taggedImage(imageName)
+	if pinned, ok := bm.lock.pinned(ref); ok {
+		return pinned
+	}
+	return ref
+}
 
-			if msg.Status == "Downloading" && msg.Progress != "" {
-				idx, exists := layerMap[msg.ID]
-				if !exists {
-					// New layer, add to slice
-					layers = append(layers, layerProgress{
-						id:   msg.ID,
-						info: &progressInfo{},
-					})
-					idx = len(layers) - 1
-					layerMap[msg.ID] = idx
-				}
+// taggedImage returns imageName with --version and the image the database
+// declares for the architecture applied, before pinning
+func (bm *BaseManager) taggedImage(imageName string) string {
+	info, err := GetDatabaseInfo(bm.opts.Name)
+	if err != nil {
+		return imageName
+	}
+	return bm.archImage(info.versionedImage(imageName, bm.opts.Version))
+}
 
-				// Update progress
-				current := msg.ProgressDetail.Current
-				total := msg.ProgressDetail.Total
-				layers[idx].info.current = current
-				layers[idx].info.total = total
+// versionedImage returns imageName with the tag replaced by version when it
+// is the main image of the database or one of its companions
+func (info DatabaseInfo) versionedImage(imageName, version string) string {
+	if version == "" {
+		return imageName
+	}
+	repository, _ := splitImage(imageName)
+	mainRepository, _ := splitImage(info.Image)
+	for _, r := range append([]string{mainRepository}, info.Companions...) {
+		if r == repository {
+			return repository + ":" + version
+		}
+	}
+	return imageName
+}
 
-				// Clear previous lines
-				for range layers {
-					fmt.Print("\033[1A\033[K") // Move up and clear line
-				}
+// splitImage splits an image reference into its repository and tag
+func splitImage(ref string) (string, string) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return ref, "latest"
+	}
+	return ref[:i], ref[i+1:]
+}
 
-				// Print progress for all layers in order
-				for _, layer := range layers {
-					if layer.info.total > 0 {
-						percentage := float64(layer.info.current) / float64(layer.info.total) * 100
-						fmt.Printf("Downloading %s: %.1f%% of %.2f MB\n",
-							layer.id[:12],
-							percentage,
-							float64(layer.info.total)/(1024*1024))
-					}
-				}
-			}
-		}
-		fmt.Println() // Add final newline
-	} else {
-		log.Printf("Using existing %s image\n", imageName)
+// PullImageIfNeeded pulls the Docker image if it's not present locally
+func (bm *BaseManager) PullImageIfNeeded(ctx context.Context, imageName string) error {
+	ref := bm.taggedImage(imageName)
+	imageName = ref
+	if pinned, ok := bm.lock.pinned(ref); ok {
+		checkLocked(ctx, bm.dockerCli, ref, pinned)
+		imageName = pinned
+	}
+	img, _, err := bm.dockerCli.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		log.Printf("%s image not found locally, pulling...\n", imageName)
+		return PullImage(ctx, bm.dockerCli, imageName, bm.pullPlatform(ctx, ref, imageName))
 	}
+	if !bm.checkLocalPlatform(ctx, imageName, img) {
+		return PullImage(ctx, bm.dockerCli, imageName, bm.pullPlatform(ctx, ref, imageName))
+	}
+	log.Printf("Using existing %s image\n", imageName)
 	return nil
 }
 
+// ContainerSpec describes a container created by CreateContainerWithSpec
+type ContainerSpec struct {
+	Image      string
+	Name       string
+	Port       string
+	ExtraPorts []string // Additional container ports published on random host ports
+	Env        []string
+	VolumePath string
+	Cmd        []string
+	Binds      []string // Additional bind mounts in host:container[:mode] form
+	Labels     map[string]string
+	Network    string // Network joined on creation, instead of the default bridge
+	DataSubdir string // Subdirectory of the data directory, for clustered nodes
+	TLSDir     string // Where the certificates are copied before start, for images running as TLSUID
+	TLSUID     int
+}
+
 // CreateContainer creates a new container with the given configuration
 func (bm *BaseManager) CreateContainer(
 	ctx context.Context,
@@ -145,51 +210,129 @@ func (bm *BaseManager) CreateContainer(
 	volumePath string,
 	cmd []string,
 ) (string, string, error) {
+	return bm.CreateContainerWithSpec(ctx, ContainerSpec{
+		Image:      imageName,
+		Name:       containerName,
+		Port:       port,
+		Env:        env,
+		VolumePath: volumePath,
+		Cmd:        cmd,
+	})
+}
+
+// CreateContainerWithSpec creates and starts a container, returning its ID and
+// the host port bound to spec.Port
+func (bm *BaseManager) CreateContainerWithSpec(ctx context.Context, spec ContainerSpec) (string, string, error) {
 	containerConfig := &container.Config{
-		Image: imageName,
-		Env:   env,
-		ExposedPorts: nat.PortSet{
-			nat.Port(port): struct{}{},
-		},
+		Image:        bm.image(spec.Image),
+		Env:          spec.Env,
+		ExposedPorts: nat.PortSet{},
+		Labels:       map[string]string{},
+	}
+	for k, v := range spec.Labels {
+		containerConfig.Labels[k] = v
 	}
-	
-	if len(cmd) > 0 {
-		containerConfig.Cmd = cmd
+	if bm.opts.Name != "" {
+		containerConfig.Labels[labelInstance] = bm.opts.Name
+	}
+
+	if len(spec.Cmd) > 0 {
+		containerConfig.Cmd = spec.Cmd
 	}
 
 	hostConfig := &container.HostConfig{
-		PortBindings: nat.PortMap{
-			nat.Port(port): []nat.PortBinding{
-				{
-					HostIP:   "0.0.0.0",
-					HostPort: "0", // Let Docker assign a random port
-				},
+		PortBindings: nat.PortMap{},
+	}
+
+	// --port, --init and the env and volumes of dbin.yaml apply to the main
+	// container only
+	main := bm.opts.Name != "" && spec.Name == InstanceContainer(bm.opts.Name)
+	if main {
+		containerConfig.Labels[labelPort] = spec.Port
+		containerConfig.Env = mergeEnv(spec.Env, bm.opts.Env)
+		if bm.opts.Auth {
+			containerConfig.Labels[labelAuth] = "true"
+		}
+	}
+
+	// Behind the proxy, the database port is only reachable through it. The
+	// proxy takes --port and containers reach it on the host.
+	proxied := main && bm.proxyEnabled()
+	if proxied {
+		hostConfig.ExtraHosts = []string{proxyHost + ":host-gateway"}
+	}
+
+	for _, port := range append([]string{spec.Port}, spec.ExtraPorts...) {
+		hostIP := "0.0.0.0"
+		hostPort := "0" // Let Docker assign a random port
+		if main && port == spec.Port && bm.opts.Port != "" {
+			hostPort = bm.opts.Port
+		}
+		if proxied && port == spec.Port {
+			hostIP, hostPort = "127.0.0.1", "0"
+		}
+		containerConfig.ExposedPorts[nat.Port(port)] = struct{}{}
+		hostConfig.PortBindings[nat.Port(port)] = []nat.PortBinding{
+			{
+				HostIP:   hostIP,
+				HostPort: hostPort,
 			},
-		},
+		}
 	}
 
-	if bm.dataDir != "" && volumePath != "" {
+	if bm.opts.DataDir != "" && spec.VolumePath != "" {
+		dataDir := bm.opts.DataDir
+		if spec.DataSubdir != "" {
+			dataDir = filepath.Join(dataDir, spec.DataSubdir)
+			if err := os.MkdirAll(dataDir, 0755); err != nil {
+				return "", "", fmt.Errorf("failed to create data directory: %v", err)
+			}
+		}
 		hostConfig.Binds = []string{
-			fmt.Sprintf("%s:%s", bm.dataDir, volumePath),
+			fmt.Sprintf("%s:%s", dataDir, spec.VolumePath),
+		}
+	}
+	hostConfig.Binds = append(hostConfig.Binds, spec.Binds...)
+	if main && bm.opts.Init != "" {
+		initBind, err := bm.initBind()
+		if err != nil {
+			return "", "", err
 		}
+		hostConfig.Binds = append(hostConfig.Binds, initBind)
+	}
+	if main {
+		hostConfig.Binds = append(hostConfig.Binds, bm.opts.Volumes...)
 	}
 
-	resp, err := bm.dockerCli.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, containerName)
+	var networkingConfig *network.NetworkingConfig
+	if spec.Network != "" {
+		hostConfig.NetworkMode = container.NetworkMode(spec.Network)
+		networkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				spec.Network: {Aliases: []string{spec.Name}},
+			},
+		}
+	}
+
+	resp, err := bm.dockerCli.ContainerCreate(ctx, containerConfig, hostConfig, networkingConfig, bm.platforms[containerConfig.Image], spec.Name)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create container: %v", err)
 	}
-
-	bm.dbContainerId = resp.ID
+	if spec.TLSDir != "" && bm.tlsDir != "" {
+		if err := bm.copyTLSFiles(ctx, resp.ID, spec.TLSDir, spec.TLSUID); err != nil {
+			return "", "", err
+		}
+	}
 
 	log.Println("Starting container...")
-	if err := bm.dockerCli.ContainerStart(ctx, bm.dbContainerId, container.StartOptions{}); err != nil {
+	if err := bm.dockerCli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
 		return "", "", fmt.Errorf("failed to start container: %v", err)
 	}
 	log.Println("Container started successfully")
 
-	if bm.debug {
+	if bm.opts.Debug {
 		go func() {
-			reader, err := bm.dockerCli.ContainerLogs(ctx, bm.dbContainerId, container.LogsOptions{
+			reader, err := bm.dockerCli.ContainerLogs(ctx, resp.ID, container.LogsOptions{
 				ShowStdout: true,
 				ShowStderr: true,
 				Follow:     true,
@@ -221,19 +364,91 @@ func (bm *BaseManager) CreateContainer(
 	}
 
 	// Get the assigned port
-	inspect, err := bm.dockerCli.ContainerInspect(ctx, bm.dbContainerId)
+	inspect, err := bm.dockerCli.ContainerInspect(ctx, resp.ID)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to inspect container: %v", err)
 	}
 
-	bm.dbPort = inspect.NetworkSettings.Ports[nat.Port(port)][0].HostPort
-	return resp.ID, bm.dbPort, nil
+	port := inspect.NetworkSettings.Ports[nat.Port(spec.Port)][0].HostPort
+	if proxied {
+		if port, err = bm.startProxy(port); err != nil {
+			return "", "", fmt.Errorf("failed to start proxy: %v", err)
+		}
+	}
+	return resp.ID, port, nil
+}
+
+// mergeEnv returns env with the variables of extra added, replacing those
+// already set
+func mergeEnv(env, extra []string) []string {
+	if len(extra) == 0 {
+		return env
+	}
+	merged := make([]string, 0, len(env)+len(extra))
+	for _, e := range env {
+		key, _, _ := strings.Cut(e, "=")
+		overridden := false
+		for _, x := range extra {
+			overridden = overridden || strings.HasPrefix(x, key+"=")
+		}
+		if !overridden {
+			merged = append(merged, e)
+		}
+	}
+	return append(merged, extra...)
+}
+
+// initBind mounts the --init script or directory into the directory the
+// image runs init scripts from
+func (bm *BaseManager) initBind() (string, error) {
+	info, err := GetDatabaseInfo(bm.opts.Name)
+	if err != nil {
+		return "", err
+	}
+	if info.InitDir == "" {
+		return "", fmt.Errorf("%s does not support init scripts", bm.opts.Name)
+	}
+	path, err := filepath.Abs(bm.opts.Init)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read init scripts: %v", err)
+	}
+	if stat.IsDir() {
+		return fmt.Sprintf("%s:%s:ro", path, info.InitDir), nil
+	}
+	return fmt.Sprintf("%s:%s/%s:ro", path, info.InitDir, filepath.Base(path)), nil
 }
 
 // StartContainerClient starts a client inside the container with retry logic
 func (bm *BaseManager) StartContainerClient(command string, args ...string) error {
+	if bm.opts.ClientMode == ClientModeHost {
+		return fmt.Errorf("no host client available, use --client container")
+	}
+	if bm.opts.Client != "" {
+		return fmt.Errorf("no alternative clients available")
+	}
+	return bm.startContainerClient(nil, command, args...)
+}
+
+func (bm *BaseManager) startContainerClient(env []string, command string, args ...string) error {
+	execArgs := []string{"exec", "-it"}
+	for _, e := range env {
+		execArgs = append(execArgs, "-e", e)
+	}
+	execArgs = append(execArgs, bm.dbContainerId, command)
+	return runClientWithRetry(func() *exec.Cmd {
+		return exec.Command("docker", append(execArgs, args...)...)
+	})
+}
+
+// runClientWithRetry runs an interactive client attached to the terminal,
+// retrying while the database finishes starting up
+func runClientWithRetry(newCmd func() *exec.Cmd) error {
 	for i := 0; i < 5; i++ {
-		cmd := exec.Command("docker", append([]string{"exec", "-it", bm.dbContainerId}, append([]string{command}, args...)...)...)
+		cmd := newCmd()
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
@@ -252,20 +467,27 @@ func (bm *BaseManager) StartContainerClient(command string, args ...string) erro
 
 // Cleanup stops and removes the database container
 func (bm *BaseManager) Cleanup(ctx context.Context) error {
+	// Keep going on errors, so nodes, network and proxy are still cleaned up
+	var errs []error
 	if bm.dbContainerId != "" {
 		log.Printf("Stopping container %s...\n", bm.dbContainerId)
 		if err := bm.dockerCli.ContainerStop(ctx, bm.dbContainerId, container.StopOptions{}); err != nil {
-			return fmt.Errorf("failed to stop database container: %v", err)
+			errs = append(errs, fmt.Errorf("failed to stop database container: %v", err))
+		} else {
+			log.Println("Container stopped successfully")
 		}
-		log.Println("Container stopped successfully")
 
 		log.Println("Removing container...")
 		if err := bm.dockerCli.ContainerRemove(ctx, bm.dbContainerId, container.RemoveOptions{
 			Force: true,
 		}); err != nil {
-			return fmt.Errorf("failed to remove database container: %v", err)
+			errs = append(errs, fmt.Errorf("failed to remove database container: %v", err))
+		} else {
+			log.Println("Container removed successfully")
 		}
-		log.Println("Container removed successfully")
 	}
-	return nil
+	bm.cleanupNodes(ctx)
+	bm.cleanupNetwork(ctx)
+	bm.StopProxy()
+	return errors.Join(errs...)
 }
@@ -8,11 +8,41 @@ import (
 	"time"
 )
 
+var couchdbUIs = []WebUI{
+	{
+		Name:        "fauxton",
+		Description: "Fauxton administration UI",
+		Container:   "dbin-couchdb",
+		Port:        "5984/tcp",
+		Path:        "/_utils/",
+	},
+}
+
+var couchdbCredentials = Credentials{
+	User:     "admin",
+	Password: "password",
+}
+
+const couchdbImage = "couchdb:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "couchdb",
-		Description: "CouchDB database",
-		Manager:     NewCouchDBManager,
+		Name:           "couchdb",
+		Description:    "CouchDB database",
+		Category:       CategoryDocument,
+		Tags:           []string{"json", "nosql", "http", "replication"},
+		Manager:        NewCouchDBManager,
+		UIs:            couchdbUIs,
+		Credentials:    couchdbCredentials,
+		Image:          couchdbImage,
+		Port:           "5984/tcp",
+		DataPath:       "/opt/couchdb/data",
+		Memory:         "256 MB",
+		Readiness:      readinessWebUI,
+		CredentialsEnv: []CredentialsEnv{{User: "COUCHDB_USER", Password: "COUCHDB_PASSWORD"}},
+		Scheme:         "http",
+		Docs:           "https://docs.couchdb.org/",
+		Tutorial:       "https://docs.couchdb.org/en/stable/intro/tour.html",
 	})
 }
 
@@ -20,8 +50,8 @@ type CouchDBManager struct {
 	*BaseManager
 }
 
-func NewCouchDBManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewCouchDBManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,16 +64,21 @@ func NewCouchDBManager(dataDir string, debug bool) DatabaseManager {
 func (cm *CouchDBManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := cm.PullImageIfNeeded(ctx, "couchdb:latest"); err != nil {
+	if err := cm.PullImageIfNeeded(ctx, couchdbImage); err != nil {
+		return err
+	}
+
+	creds, err := cm.ResolveCredentials(couchdbCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
-		"COUCHDB_USER=admin",
-		"COUCHDB_PASSWORD=password",
+		"COUCHDB_USER=" + creds.User,
+		"COUCHDB_PASSWORD=" + creds.Password,
 	}
 
-	containerId, port, err := cm.CreateContainer(ctx, "couchdb:latest", "dbin-couchdb", "5984/tcp", env, "/opt/couchdb/data", nil)
+	containerId, port, err := cm.CreateContainer(ctx, couchdbImage, "dbin-couchdb", "5984/tcp", env, "/opt/couchdb/data", nil)
 	if err != nil {
 		return err
 	}
@@ -51,11 +86,13 @@ func (cm *CouchDBManager) StartDatabase() error {
 	cm.dbPort = port
 
 	log.Printf("CouchDB is ready and listening on port %s\n", cm.dbPort)
+	log.Printf("Username: %s", creds.User)
+	log.Printf("Password: %s", creds.Password)
 	return nil
 }
 
 func (cm *CouchDBManager) StartClient() error {
-	return StartWebInterface(cm.dbPort + "/_utils")
+	return cm.OpenWebUI(couchdbUIs)
 }
 
 func (cm *CouchDBManager) Cleanup() error {
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CredentialsEnv names the environment variables an image takes the
+// credentials from. User is empty when the image sets the password of its
+// default user only.
+type CredentialsEnv struct {
+	User     string
+	Password string
+}
+
+// Credentials are the login details of a database instance
+type Credentials struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Token    string `json:"token,omitempty"`
+}
+
+// IsZero reports whether no credentials are set
+func (c Credentials) IsZero() bool {
+	return c == Credentials{}
+}
+
+// StateDir returns the directory where dbin keeps its local state
+func StateDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find configuration directory: %v", err)
+	}
+	return filepath.Join(configDir, "dbin"), nil
+}
+
+func credentialsPath() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "credentials.json"), nil
+}
+
+func loadCredentialsStore() (map[string]Credentials, error) {
+	store := make(map[string]Credentials)
+
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return store, nil
+}
+
+// LoadCredentials returns the stored credentials of an instance
+func LoadCredentials(instance string) (Credentials, bool, error) {
+	store, err := loadCredentialsStore()
+	if err != nil {
+		return Credentials{}, false, err
+	}
+	creds, ok := store[instance]
+	return creds, ok, nil
+}
+
+// SaveCredentials stores the credentials of an instance in the local
+// credentials file, which is only readable by the current user
+func SaveCredentials(instance string, creds Credentials) error {
+	store, err := loadCredentialsStore()
+	if err != nil {
+		return err
+	}
+	store[instance] = creds
+
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials: %v", err)
+	}
+	// WriteFile keeps the mode of an existing file, so enforce it
+	return os.Chmod(path, 0600)
+}
+
+// InstanceCredentials returns the credentials of a running instance, falling
+// back to the database defaults when none were stored
+func InstanceCredentials(info DatabaseInfo) Credentials {
+	creds, ok, err := LoadCredentials(info.Name)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if !ok {
+		return info.Credentials
+	}
+	return creds
+}
+
+// connectionCredentials returns the credentials to connect to a running
+// instance with, given the labels of its main container. Databases with
+// --auth run without credentials unless started with it.
+func connectionCredentials(info DatabaseInfo, labels map[string]string) Credentials {
+	if info.Auth && labels[labelAuth] == "" {
+		return Credentials{}
+	}
+	return InstanceCredentials(info)
+}
+
+// ResolveCredentials applies --user, --password and --random-credentials to
+// the default credentials of the database and stores the result for the
+// instance, so clients and dbin exec can use it later
+func (bm *BaseManager) ResolveCredentials(defaults Credentials) (Credentials, error) {
+	creds := defaults
+
+	if bm.opts.User != "" {
+		creds.User = bm.opts.User
+	}
+
+	switch {
+	case bm.opts.Password != "":
+		creds.Password = bm.opts.Password
+	case bm.opts.RandomCredentials:
+		stored, ok, err := LoadCredentials(bm.opts.Name)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if ok && bm.opts.DataDir != "" && stored.User == creds.User {
+			// Persistent data was initialised with the stored secrets
+			creds = stored
+			break
+		}
+
+		if creds.Password, err = generatePassword(); err != nil {
+			return Credentials{}, err
+		}
+		if defaults.Token != "" {
+			if creds.Token, err = generateSecret(40); err != nil {
+				return Credentials{}, err
+			}
+		}
+	}
+
+	if bm.opts.Name != "" {
+		if err := SaveCredentials(bm.opts.Name, creds); err != nil {
+			return Credentials{}, err
+		}
+		if bm.opts.RandomCredentials {
+			path, _ := credentialsPath()
+			log.Printf("Credentials stored in %s", path)
+		}
+	}
+
+	bm.creds = creds
+	return creds, nil
+}
+
+// generatePassword returns a random password that also satisfies the
+// complexity rules some databases enforce, like OpenSearch's
+func generatePassword() (string, error) {
+	for {
+		var groups []string
+		for i := 0; i < 4; i++ {
+			group, err := generateSecret(6)
+			if err != nil {
+				return "", err
+			}
+			groups = append(groups, group)
+		}
+		password := strings.Join(groups, "-")
+		if strings.ContainsAny(password, "abcdefghijklmnopqrstuvwxyz") &&
+			strings.ContainsAny(password, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+			strings.ContainsAny(password, "0123456789") {
+			return password, nil
+		}
+	}
+}
+
+func generateSecret(length int) (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	secret := make([]byte, length)
+	for i := range secret {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate secret: %v", err)
+		}
+		secret[i] = alphabet[n.Int64()]
+	}
+	return string(secret), nil
+}
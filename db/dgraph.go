@@ -11,11 +11,47 @@ import (
 	"github.com/docker/docker/api/types/network"
 )
 
+var dgraphUIs = []WebUI{
+	{
+		Name:        "ratel",
+		Description: "Ratel UI",
+		Container:   "dbin-dgraph-ratel",
+		Port:        "8000/tcp",
+	},
+	{
+		Name:        "api",
+		Description: "Dgraph Alpha HTTP API",
+		Container:   "dbin-dgraph",
+		Port:        "8080/tcp",
+		Path:        "/health",
+	},
+}
+
+const (
+	dgraphImage = "dgraph/dgraph:latest"
+	ratelImage  = "dgraph/ratel:latest"
+)
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "dgraph",
 		Description: "Dgraph graph database",
+		Category:    CategoryGraph,
+		Tags:        []string{"graphql", "dql"},
 		Manager:     NewDgraphManager,
+		UIs:         dgraphUIs,
+		Image:       dgraphImage,
+		Port:        "8080/tcp",
+		DataPath:    "/dgraph",
+		Containers: []ContainerInfo{
+			{Name: "dbin-dgraph-zero", Image: dgraphImage, Ports: []string{"5080/tcp"}, DataPath: "/dgraph", Role: "Cluster coordinator"},
+			{Name: "dbin-dgraph", Image: dgraphImage, Ports: []string{"8080/tcp"}, DataPath: "/dgraph", Role: "Database server"},
+			{Name: "dbin-dgraph-ratel", Image: ratelImage, Ports: []string{"8000/tcp"}, Role: "Ratel web interface"},
+		},
+		Memory:    "2 GB",
+		Readiness: "Waits 5 seconds for Zero before starting Alpha, then polls Ratel up to 5 times 5 seconds apart",
+		Docs:      "https://dgraph.io/docs/",
+		Tutorial:  "https://dgraph.io/tour/",
 	})
 }
 
@@ -27,8 +63,8 @@ type DgraphManager struct {
 	alphaPort        string
 }
 
-func NewDgraphManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewDgraphManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -41,7 +77,7 @@ func NewDgraphManager(dataDir string, debug bool) DatabaseManager {
 func (dm *DgraphManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := dm.PullImageIfNeeded(ctx, "dgraph/dgraph:latest"); err != nil {
+	if err := dm.PullImageIfNeeded(ctx, dgraphImage); err != nil {
 		return err
 	}
 
@@ -56,7 +92,7 @@ func (dm *DgraphManager) StartDatabase() error {
 	zeroEnv := []string{}
 	zeroCmd := []string{"dgraph", "zero", "--my=dbin-dgraph-zero:5080"}
 
-	containerId, _, err := dm.CreateContainer(ctx, "dgraph/dgraph:latest", "dbin-dgraph-zero", "5080/tcp", zeroEnv, "/dgraph", zeroCmd)
+	containerId, _, err := dm.CreateContainer(ctx, dgraphImage, "dbin-dgraph-zero", "5080/tcp", zeroEnv, "/dgraph", zeroCmd)
 	if err != nil {
 		return err
 	}
@@ -70,11 +106,11 @@ func (dm *DgraphManager) StartDatabase() error {
 	// Wait for Zero to be ready
 	time.Sleep(5 * time.Second)
 
-	// Start Dgraph Alpha
+	// Start Dgraph Alpha, the main container of the instance
 	alphaEnv := []string{}
-	alphaCmd := []string{"dgraph", "alpha", "--my=dbin-dgraph-alpha:7080", "--zero=dbin-dgraph-zero:5080", "--security", "whitelist=0.0.0.0/0"}
+	alphaCmd := []string{"dgraph", "alpha", "--my=dbin-dgraph:7080", "--zero=dbin-dgraph-zero:5080", "--security", "whitelist=0.0.0.0/0"}
 
-	containerId, port, err := dm.CreateContainer(ctx, "dgraph/dgraph:latest", "dbin-dgraph-alpha", "8080/tcp", alphaEnv, "/dgraph", alphaCmd)
+	containerId, port, err := dm.CreateContainer(ctx, dgraphImage, "dbin-dgraph", "8080/tcp", alphaEnv, "/dgraph", alphaCmd)
 	if err != nil {
 		return err
 	}
@@ -88,14 +124,14 @@ func (dm *DgraphManager) StartDatabase() error {
 	}
 
 	// Pull and start Ratel UI
-	if err := dm.PullImageIfNeeded(ctx, "dgraph/ratel:latest"); err != nil {
+	if err := dm.PullImageIfNeeded(ctx, ratelImage); err != nil {
 		return err
 	}
 
 	ratelEnv := []string{}
 	ratelCmd := []string{"/usr/local/bin/dgraph-ratel"} // Correct path to executable
 
-	_, port, err = dm.CreateContainer(ctx, "dgraph/ratel:latest", "dbin-dgraph-ratel", "8000/tcp", ratelEnv, "", ratelCmd)
+	_, port, err = dm.CreateContainer(ctx, ratelImage, "dbin-dgraph-ratel", "8000/tcp", ratelEnv, "", ratelCmd)
 	if err != nil {
 		return err
 	}
@@ -106,7 +142,7 @@ func (dm *DgraphManager) StartDatabase() error {
 }
 
 func (dm *DgraphManager) StartClient() error {
-	return StartWebInterface(dm.ratelPort)
+	return dm.OpenWebUI(dgraphUIs)
 }
 
 func (dm *DgraphManager) Cleanup() error {
@@ -3,19 +3,122 @@ package db
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 )
 
+var elasticsearchUIs = []WebUI{
+	{
+		Name:        "kibana",
+		Description: "Kibana",
+		Container:   "dbin-elasticsearch-kibana",
+		Port:        "5601/tcp",
+		Attempts:    36,
+	},
+	{
+		Name:        "api",
+		Description: "Elasticsearch REST API",
+		Container:   "dbin-elasticsearch",
+		Port:        "9200/tcp",
+		Attempts:    12,
+	},
+}
+
+var elasticsearchCredentials = Credentials{
+	User:     "elastic",
+	Password: "changeme",
+}
+
+// elasticsearchCertsPath is where the server gets its copy of the
+// certificates, as Elasticsearch only reads them from its config directory
+const elasticsearchCertsPath = "/usr/share/elasticsearch/config" + tlsMountPath
+
+var elasticsearchMetrics = &EngineMetrics{
+	Command: func(conn Connection) []string {
+		args := []string{"curl", "--silent", "--show-error", "--fail"}
+		if conn.User != "" {
+			args = append(args, "--config", "-")
+		}
+		scheme := "http"
+		if conn.TLS != nil {
+			scheme = "https"
+			args = append(args, "--cacert", conn.TLS.CA)
+			if conn.TLS.Cert != "" {
+				args = append(args, "--cert", conn.TLS.Cert, "--key", conn.TLS.Key)
+			}
+		}
+		return append(args, scheme+"://localhost:9200/_cluster/health")
+	},
+	// The credentials go through a curl config on stdin rather than -u, which
+	// would show the password in the process list. Security ignores them when
+	// it is disabled.
+	Stdin: func(conn Connection) string {
+		if conn.User == "" {
+			return ""
+		}
+		quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		return fmt.Sprintf("user = \"%s\"\n", quote.Replace(conn.User+":"+conn.Password))
+	},
+	Parse: func(output string) ([]Metric, error) {
+		var health struct {
+			Status           string `json:"status"`
+			Nodes            int    `json:"number_of_nodes"`
+			ActiveShards     int    `json:"active_shards"`
+			UnassignedShards int    `json:"unassigned_shards"`
+			PendingTasks     int    `json:"number_of_pending_tasks"`
+		}
+		if err := json.Unmarshal([]byte(output), &health); err != nil {
+			return nil, fmt.Errorf("failed to decode cluster health: %v", err)
+		}
+		return []Metric{
+			{Name: "status", Value: health.Status},
+			{Name: "nodes", Value: strconv.Itoa(health.Nodes)},
+			{Name: "active shards", Value: strconv.Itoa(health.ActiveShards)},
+			{Name: "unassigned shards", Value: strconv.Itoa(health.UnassignedShards)},
+			{Name: "pending tasks", Value: strconv.Itoa(health.PendingTasks)},
+		}, nil
+	},
+}
+
+const (
+	elasticsearchImage = "elasticsearch:8.12.0"
+	kibanaImage        = "kibana:8.12.0"
+)
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "elasticsearch",
 		Description: "Elasticsearch search engine",
+		Category:    CategorySearch,
+		Tags:        []string{"full-text", "analytics", "json"},
 		Manager:     NewElasticsearchManager,
+		UIs:         elasticsearchUIs,
+		Credentials: elasticsearchCredentials,
+		TLS:         true,
+		Auth:        true,
+		Nodes:       true,
+		Image:       elasticsearchImage,
+		Port:        "9200/tcp",
+		DataPath:    "/usr/share/elasticsearch/data",
+		Containers: []ContainerInfo{
+			{Name: "dbin-elasticsearch", Image: elasticsearchImage, Ports: []string{"9200/tcp"}, DataPath: "/usr/share/elasticsearch/data", Role: "Search engine"},
+			{Name: "dbin-elasticsearch-kibana", Image: kibanaImage, Ports: []string{"5601/tcp"}, Role: "Kibana web interface"},
+		},
+		Memory:     "2 GB (512 MB heap per node, plus Kibana)",
+		Readiness:  "Waits 15 seconds before starting Kibana, or polls the cluster health with --nodes and the security API with --auth every 5 seconds, then polls Kibana up to 36 times 5 seconds apart",
+		Metrics:    elasticsearchMetrics,
+		Companions: []string{"kibana"},
+		Scheme:     "http",
+		Docs:       "https://www.elastic.co/guide/en/elasticsearch/reference/current/index.html",
+		Tutorial:   "https://www.elastic.co/guide/en/elasticsearch/reference/current/getting-started.html",
 	})
 }
 
@@ -26,8 +129,8 @@ type ElasticsearchManager struct {
 	kibanaPort              string
 }
 
-func NewElasticsearchManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewElasticsearchManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -40,11 +143,11 @@ func NewElasticsearchManager(dataDir string, debug bool) DatabaseManager {
 func (em *ElasticsearchManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := em.PullImageIfNeeded(ctx, "elasticsearch:8.12.0"); err != nil {
+	if err := em.PullImageIfNeeded(ctx, elasticsearchImage); err != nil {
 		return err
 	}
 
-	if err := em.PullImageIfNeeded(ctx, "kibana:8.12.0"); err != nil {
+	if err := em.PullImageIfNeeded(ctx, kibanaImage); err != nil {
 		return err
 	}
 
@@ -56,37 +159,69 @@ func (em *ElasticsearchManager) StartDatabase() error {
 	}
 
 	// Start Elasticsearch container first
-	env := []string{
-		"discovery.type=single-node",
-		"ES_JAVA_OPTS=-Xms512m -Xmx512m",
-		"xpack.security.enabled=false",
-		"bootstrap.memory_lock=true",
+	spec := ContainerSpec{
+		Image: elasticsearchImage,
+		Name:  "dbin-elasticsearch",
+		Port:  "9200/tcp",
+		Env: []string{
+			"discovery.type=single-node",
+			"ES_JAVA_OPTS=-Xms512m -Xmx512m",
+			"bootstrap.memory_lock=true",
+		},
+		VolumePath: "/usr/share/elasticsearch/data",
 	}
-
-	// Create Elasticsearch container with its native port
-	containerId, port, err := em.CreateContainer(ctx, "elasticsearch:8.12.0", "dbin-elasticsearch", "9200/tcp", env, "/usr/share/elasticsearch/data", nil)
-	if err != nil {
+	if err := em.configureSecurity(&spec); err != nil {
 		return err
 	}
-	em.elasticsearchContainerId = containerId
-	em.dbContainerId = containerId
-	em.dbPort = port
 
-	// Connect Elasticsearch container to the network
-	if err := em.dockerCli.NetworkConnect(ctx, networkResponse.ID, em.elasticsearchContainerId, nil); err != nil {
-		return fmt.Errorf("failed to connect Elasticsearch to network: %v", err)
+	if em.Clustered() {
+		if err := em.startSearchCluster(ctx, spec, networkName, "cluster.initial_master_nodes"); err != nil {
+			return err
+		}
+		api, err := em.newSearchAPI(em.dbPort, false, false)
+		if err != nil {
+			return err
+		}
+		if err := api.waitForNodes(em.Nodes()); err != nil {
+			return err
+		}
+		em.elasticsearchContainerId = em.dbContainerId
+	} else {
+		// Create Elasticsearch container with its native port
+		containerId, port, err := em.CreateContainerWithSpec(ctx, spec)
+		if err != nil {
+			return err
+		}
+		em.elasticsearchContainerId = containerId
+		em.dbContainerId = containerId
+		em.dbPort = port
+
+		// Connect Elasticsearch container to the network
+		if err := em.dockerCli.NetworkConnect(ctx, networkResponse.ID, em.elasticsearchContainerId, nil); err != nil {
+			return fmt.Errorf("failed to connect Elasticsearch to network: %v", err)
+		}
 	}
 
-	// Wait for Elasticsearch to be ready
-	time.Sleep(15 * time.Second)
+	kibanaSpec := ContainerSpec{
+		Image: kibanaImage,
+		Name:  "dbin-elasticsearch-kibana",
+		Port:  "5601/tcp",
+		Env: []string{
+			"ELASTICSEARCH_HOSTS=http://dbin-elasticsearch:9200",
+		},
+	}
 
-	// Start Kibana container
-	kibanaEnv := []string{
-		"ELASTICSEARCH_HOSTS=http://dbin-elasticsearch:9200",
+	// Wait for Elasticsearch to be ready
+	if em.opts.Auth {
+		if err := em.bootstrapUsers(&kibanaSpec); err != nil {
+			return err
+		}
+	} else if !em.Clustered() {
+		time.Sleep(15 * time.Second)
 	}
 
 	// Create Kibana container with port 5601
-	containerId, port, err = em.CreateContainer(ctx, "kibana:8.12.0", "dbin-elasticsearch-kibana", "5601/tcp", kibanaEnv, "", nil)
+	containerId, port, err := em.CreateContainerWithSpec(ctx, kibanaSpec)
 	if err != nil {
 		return err
 	}
@@ -99,11 +234,122 @@ func (em *ElasticsearchManager) StartDatabase() error {
 	}
 
 	log.Printf("Elasticsearch is ready on port %s and Kibana is accessible on port %s\n", em.dbPort, em.kibanaPort)
+	if em.opts.Auth {
+		log.Printf("Username: %s", em.creds.User)
+		log.Printf("Password: %s", em.creds.Password)
+	}
+	return nil
+}
+
+// configureSecurity enables authentication, and HTTPS with --tls, on the
+// Elasticsearch container
+func (em *ElasticsearchManager) configureSecurity(spec *ContainerSpec) error {
+	if !em.opts.Auth {
+		if em.opts.TLS {
+			return fmt.Errorf("--tls requires --auth for Elasticsearch")
+		}
+		spec.Env = append(spec.Env, "xpack.security.enabled=false")
+		return nil
+	}
+
+	creds, err := em.ResolveCredentials(elasticsearchCredentials)
+	if err != nil {
+		return err
+	}
+	spec.Env = append(spec.Env,
+		"xpack.security.enabled=true",
+		"ELASTIC_PASSWORD="+creds.Password,
+	)
+
+	tlsBind, err := em.SetupTLS("dbin-elasticsearch")
+	if err != nil {
+		return err
+	}
+	if !em.TLSEnabled() {
+		spec.Env = append(spec.Env, "xpack.security.http.ssl.enabled=false")
+		return nil
+	}
+
+	// The image runs as uid 1000, which gets its own copy of the keys. The
+	// mount is for clients running in the container.
+	spec.Binds = []string{tlsBind}
+	spec.TLSDir, spec.TLSUID = elasticsearchCertsPath, 1000
+	spec.Labels = map[string]string{labelHTTPS: "true"}
+	spec.Env = append(spec.Env,
+		"xpack.security.http.ssl.enabled=true",
+		"xpack.security.http.ssl.certificate="+elasticsearchCertsPath+"/server.crt",
+		"xpack.security.http.ssl.key="+elasticsearchCertsPath+"/server.key",
+		"xpack.security.http.ssl.certificate_authorities="+elasticsearchCertsPath+"/ca.pem",
+	)
+	if em.opts.MTLS {
+		spec.Env = append(spec.Env, "xpack.security.http.ssl.client_authentication=required")
+	}
+	return nil
+}
+
+// bootstrapUsers waits for Elasticsearch, creates the requested admin user
+// and sets up the kibana_system service account used by Kibana
+func (em *ElasticsearchManager) bootstrapUsers(kibanaSpec *ContainerSpec) error {
+	// The built-in superuser always exists, custom users are created with it
+	api, err := em.newSearchAPI(em.dbPort, em.TLSEnabled(), false)
+	if err != nil {
+		return err
+	}
+	api.creds.User = elasticsearchCredentials.User
+
+	log.Println("Waiting for Elasticsearch security to initialize...")
+	if err := api.waitUntilReady("/_security/_authenticate", 36); err != nil {
+		return err
+	}
+
+	if em.creds.User != elasticsearchCredentials.User {
+		err := api.do(http.MethodPost, "/_security/user/"+em.creds.User, map[string]interface{}{
+			"password": em.creds.Password,
+			"roles":    []string{"superuser"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create user %s: %v", em.creds.User, err)
+		}
+	}
+
+	kibanaPassword, err := generatePassword()
+	if err != nil {
+		return err
+	}
+	err = api.do(http.MethodPost, "/_security/user/kibana_system/_password", map[string]string{
+		"password": kibanaPassword,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set the kibana_system password: %v", err)
+	}
+	log.Println("Kibana connects as kibana_system")
+
+	kibanaSpec.Env = []string{
+		"ELASTICSEARCH_USERNAME=kibana_system",
+		"ELASTICSEARCH_PASSWORD=" + kibanaPassword,
+	}
+	if !em.TLSEnabled() {
+		kibanaSpec.Env = append(kibanaSpec.Env, "ELASTICSEARCH_HOSTS=http://dbin-elasticsearch:9200")
+		return nil
+	}
+
+	files := em.containerTLSFiles()
+	kibanaSpec.TLSDir, kibanaSpec.TLSUID = tlsMountPath, 1000
+	kibanaSpec.Env = append(kibanaSpec.Env,
+		"ELASTICSEARCH_HOSTS=https://dbin-elasticsearch:9200",
+		"ELASTICSEARCH_SSL_CERTIFICATEAUTHORITIES="+files.CA,
+	)
+	if files.Cert != "" {
+		kibanaSpec.Env = append(kibanaSpec.Env,
+			"ELASTICSEARCH_SSL_CERTIFICATE="+files.Cert,
+			"ELASTICSEARCH_SSL_KEY="+files.Key,
+		)
+	}
 	return nil
 }
 
 func (em *ElasticsearchManager) StartClient() error {
-	return StartWebInterface(em.dbPort)
+	return em.OpenWebUI(elasticsearchUIs)
 }
 
 func (em *ElasticsearchManager) Cleanup() error {
@@ -129,6 +375,8 @@ func (em *ElasticsearchManager) Cleanup() error {
 		}
 	}
 
+	em.cleanupNodes(ctx)
+
 	// Clean up the network
 	networks, err := em.dockerCli.NetworkList(ctx, network.ListOptions{})
 	if err == nil {
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InstanceContainer returns the name of the main container of a database
+func InstanceContainer(name string) string {
+	return "dbin-" + name
+}
+
+// ExecInstance runs a script against a running database with its native
+// client, without a TTY. The script is fed to the client on stdin and the
+// client's output is streamed to stdout and stderr. A non-zero exit of the
+// client is returned as an error carrying its exit code.
+func ExecInstance(name string, script io.Reader, format string, stdout, stderr io.Writer) error {
+	info, err := GetDatabaseInfo(name)
+	if err != nil {
+		return err
+	}
+
+	c, err := SelectClient(info.Clients, "")
+	if err != nil {
+		return fmt.Errorf("%s does not support exec: %v", name, err)
+	}
+
+	var formatArgs []string
+	if format != "" {
+		args, ok := c.Formats[format]
+		if !ok {
+			return fmt.Errorf("%s does not support %s output (available: %s)", c.Name, format, strings.Join(c.formatNames(), ", "))
+		}
+		formatArgs = args
+	}
+
+	args, err := clientExecArgs(info, c, "-i")
+	if err != nil {
+		return err
+	}
+	args = append(args, c.Exec...)
+	args = append(args, formatArgs...)
+
+	cmd := exec.Command("docker", args...)
+	cmd.Stdin = script
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// ConnectInstance runs the default client of a running database
+// interactively, on the terminal
+func ConnectInstance(name string) error {
+	info, err := GetDatabaseInfo(name)
+	if err != nil {
+		return err
+	}
+	c, err := SelectClient(info.Clients, "")
+	if err != nil {
+		return fmt.Errorf("%s has no client: %v", name, err)
+	}
+	args, err := clientExecArgs(info, c, "-it")
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("docker", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// clientExecArgs returns the docker exec arguments running a client in the
+// main container of a running database, connected with its credentials
+func clientExecArgs(info DatabaseInfo, c Client, flags string) ([]string, error) {
+	containerName, conn, err := instanceConnection(info)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"exec", flags}
+	for _, e := range c.env(conn) {
+		args = append(args, "-e", e)
+	}
+	args = append(args, containerName, c.Command)
+	args = append(args, c.args(conn)...)
+	return args, nil
+}
+
+// instanceConnection returns the main container of a running database and
+// how to connect to it from inside the container
+func instanceConnection(info DatabaseInfo) (string, Connection, error) {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return "", Connection{}, err
+	}
+	defer cli.Close()
+
+	containerName := InstanceContainer(info.Name)
+	inspect, err := cli.ContainerInspect(context.Background(), containerName)
+	if err != nil || !inspect.State.Running {
+		return "", Connection{}, fmt.Errorf("%s is not running, start it with 'dbin %s'", info.Name, info.Name)
+	}
+
+	creds := connectionCredentials(info, inspect.Config.Labels)
+	conn := Connection{
+		User:     creds.User,
+		Password: creds.Password,
+		Cluster:  labelledNodes(inspect.Config.Labels) > 1,
+	}
+	if port, ok := proxyPort(info.Name); ok {
+		conn.Host, conn.Port = proxyHost, port
+	}
+	for _, mount := range inspect.Mounts {
+		if mount.Destination == tlsMountPath {
+			_, err := os.Stat(filepath.Join(mount.Source, "client.crt"))
+			conn.TLS = tlsFilesIn(mount.Destination, err == nil)
+		}
+	}
+	return containerName, conn, nil
+}
+
+func (c Client) formatNames() []string {
+	var names []string
+	for name := range c.Formats {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return []string{"client default only"}
+	}
+	sort.Strings(names)
+	return names
+}
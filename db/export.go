@@ -0,0 +1,428 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"gopkg.in/yaml.v3"
+)
+
+// Export formats accepted by dbin export
+const (
+	ExportCompose    = "compose"
+	ExportKubernetes = "k8s"
+)
+
+// ServiceSpec is the configuration of one container of an instance, as
+// needed to recreate it without dbin
+type ServiceSpec struct {
+	Name       string // Container name, which the other containers resolve
+	Image      string
+	Entrypoint []string          // Only when it differs from the image's
+	Command    []string          // Only when it differs from the image's
+	Env        []string          // Only the variables the image doesn't set
+	Ports      []string          // Exposed container ports, e.g. 5432/tcp
+	Published  map[string]string // Published container ports to host ports
+	Binds      []string          // host:container[:mode] mounts
+	Network    string            // Network shared with the other containers
+	DependsOn  []string          // Containers started before this one
+}
+
+// CaptureInstance reads the configuration of the containers of a running
+// instance, in the order they were started
+func CaptureInstance(ctx context.Context, cli *client.Client, name string) ([]ServiceSpec, error) {
+	containers, err := instanceContainers(ctx, cli, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("%s is not running", name)
+	}
+	for _, c := range containers {
+		if labelledNodes(c.Labels) > 1 {
+			return nil, errClusterExport(name)
+		}
+	}
+	sort.SliceStable(containers, func(i, j int) bool {
+		return containers[i].Created < containers[j].Created
+	})
+
+	var specs []ServiceSpec
+	var started []string
+	for _, c := range containers {
+		inspect, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container: %v", err)
+		}
+		img, _, err := cli.ImageInspectWithRaw(ctx, inspect.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect image: %v", err)
+		}
+
+		spec := ServiceSpec{
+			Name:      strings.TrimPrefix(inspect.Name, "/"),
+			Image:     inspect.Config.Image,
+			Published: make(map[string]string),
+			Binds:     inspect.HostConfig.Binds,
+			DependsOn: append([]string(nil), started...),
+		}
+		var imageEnv, imageCmd, imageEntrypoint []string
+		if img.Config != nil {
+			imageEnv, imageCmd, imageEntrypoint = img.Config.Env, img.Config.Cmd, img.Config.Entrypoint
+		}
+		for _, e := range inspect.Config.Env {
+			if !contains(imageEnv, e) {
+				spec.Env = append(spec.Env, e)
+			}
+		}
+		if !equalStrings(inspect.Config.Cmd, imageCmd) {
+			spec.Command = inspect.Config.Cmd
+		}
+		if !equalStrings(inspect.Config.Entrypoint, imageEntrypoint) {
+			spec.Entrypoint = inspect.Config.Entrypoint
+		}
+
+		for port := range inspect.Config.ExposedPorts {
+			spec.Ports = append(spec.Ports, string(port))
+		}
+		sortPorts(spec.Ports)
+		for port, bindings := range inspect.HostConfig.PortBindings {
+			// Random host ports become the container port, like most
+			// compose files publish them
+			hostPort := port.Port()
+			if len(bindings) > 0 && bindings[0].HostPort != "" && bindings[0].HostPort != "0" {
+				hostPort = bindings[0].HostPort
+			}
+			spec.Published[string(port)] = hostPort
+		}
+
+		switch mode := string(inspect.HostConfig.NetworkMode); mode {
+		case "", "default", "bridge", "host", "none":
+		default:
+			spec.Network = mode
+		}
+
+		specs = append(specs, spec)
+		started = append(started, spec.Name)
+	}
+	return specs, nil
+}
+
+// errClusterExport is returned for instances started with --nodes, whose
+// replication is set up with docker exec once the nodes run, which the
+// configuration of the containers doesn't capture
+func errClusterExport(name string) error {
+	return fmt.Errorf("%s runs several nodes, whose replication dbin sets up after they start and an export cannot reproduce; export it without --nodes", name)
+}
+
+// CaptureDatabase reads the configuration of an instance, starting it with
+// opts for the time of the capture when it isn't running
+func CaptureDatabase(opts Options) ([]ServiceSpec, error) {
+	if opts.Nodes > 1 {
+		return nil, errClusterExport(opts.Name)
+	}
+	cli, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	containers, err := instanceContainers(ctx, cli, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) > 0 {
+		return CaptureInstance(ctx, cli, opts.Name)
+	}
+
+	info, err := GetDatabaseInfo(opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("%s is not running, starting it to capture its configuration...", opts.Name)
+	manager := info.Manager(opts)
+	defer manager.Cleanup()
+	if err := manager.StartDatabase(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", opts.Name, err)
+	}
+	return CaptureInstance(ctx, cli, opts.Name)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sortPorts sorts container ports numerically
+func sortPorts(ports []string) {
+	sort.Slice(ports, func(i, j int) bool {
+		pi, pj := nat.Port(ports[i]), nat.Port(ports[j])
+		if pi.Int() != pj.Int() {
+			return pi.Int() < pj.Int()
+		}
+		return pi.Proto() < pj.Proto()
+	})
+}
+
+// RenderExport renders the services in the given format
+func RenderExport(format string, services []ServiceSpec) ([]byte, error) {
+	switch format {
+	case ExportCompose:
+		return renderCompose(services)
+	case ExportKubernetes:
+		return renderKubernetes(services)
+	}
+	return nil, fmt.Errorf("unknown format %q, expected %s or %s", format, ExportCompose, ExportKubernetes)
+}
+
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+	Networks map[string]struct{}       `yaml:"networks,omitempty"`
+}
+
+type composeService struct {
+	Image       string   `yaml:"image"`
+	Entrypoint  []string `yaml:"entrypoint,omitempty,flow"`
+	Command     []string `yaml:"command,omitempty,flow"`
+	Environment []string `yaml:"environment,omitempty"`
+	Ports       []string `yaml:"ports,omitempty"`
+	Volumes     []string `yaml:"volumes,omitempty"`
+	Networks    []string `yaml:"networks,omitempty"`
+	DependsOn   []string `yaml:"depends_on,omitempty"`
+}
+
+// composeEscape escapes $ as $$, so that compose doesn't interpolate shell
+// variables of commands, such as "$PGDATA", from the host environment
+func composeEscape(values []string) []string {
+	var escaped []string
+	for _, v := range values {
+		escaped = append(escaped, strings.ReplaceAll(v, "$", "$$"))
+	}
+	return escaped
+}
+
+func renderCompose(services []ServiceSpec) ([]byte, error) {
+	file := composeFile{Services: make(map[string]composeService)}
+	for _, s := range services {
+		service := composeService{
+			Image:       s.Image,
+			Entrypoint:  composeEscape(s.Entrypoint),
+			Command:     composeEscape(s.Command),
+			Environment: composeEscape(s.Env),
+			Volumes:     s.Binds,
+			DependsOn:   s.DependsOn,
+		}
+		var published []string
+		for port := range s.Published {
+			published = append(published, port)
+		}
+		sortPorts(published)
+		for _, port := range published {
+			p := nat.Port(port)
+			mapping := s.Published[port] + ":" + p.Port()
+			if p.Proto() != "tcp" {
+				mapping += "/" + p.Proto()
+			}
+			service.Ports = append(service.Ports, mapping)
+		}
+		if s.Network != "" {
+			service.Networks = []string{s.Network}
+			if file.Networks == nil {
+				file.Networks = make(map[string]struct{})
+			}
+			file.Networks[s.Network] = struct{}{}
+		}
+		file.Services[s.Name] = service
+	}
+	return marshalYAML(file)
+}
+
+type k8sObject struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   k8sMetadata `yaml:"metadata"`
+	Spec       interface{} `yaml:"spec"`
+}
+
+type k8sMetadata struct {
+	Name   string            `yaml:"name,omitempty"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+type k8sDeploymentSpec struct {
+	Replicas int `yaml:"replicas"`
+	Selector struct {
+		MatchLabels map[string]string `yaml:"matchLabels"`
+	} `yaml:"selector"`
+	Template struct {
+		Metadata k8sMetadata `yaml:"metadata"`
+		Spec     k8sPodSpec  `yaml:"spec"`
+	} `yaml:"template"`
+}
+
+type k8sPodSpec struct {
+	Containers []k8sContainer `yaml:"containers"`
+	Volumes    []k8sVolume    `yaml:"volumes,omitempty"`
+}
+
+type k8sContainer struct {
+	Name         string           `yaml:"name"`
+	Image        string           `yaml:"image"`
+	Command      []string         `yaml:"command,omitempty,flow"`
+	Args         []string         `yaml:"args,omitempty,flow"`
+	Env          []k8sEnvVar      `yaml:"env,omitempty"`
+	Ports        []k8sPort        `yaml:"ports,omitempty"`
+	VolumeMounts []k8sVolumeMount `yaml:"volumeMounts,omitempty"`
+}
+
+type k8sEnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type k8sPort struct {
+	Name          string `yaml:"name,omitempty"`
+	ContainerPort int    `yaml:"containerPort,omitempty"`
+	Port          int    `yaml:"port,omitempty"`
+	TargetPort    int    `yaml:"targetPort,omitempty"`
+	Protocol      string `yaml:"protocol"`
+}
+
+type k8sVolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+type k8sVolume struct {
+	Name     string `yaml:"name"`
+	HostPath struct {
+		Path string `yaml:"path"`
+	} `yaml:"hostPath"`
+}
+
+type k8sServiceSpec struct {
+	Selector map[string]string `yaml:"selector"`
+	Ports    []k8sPort         `yaml:"ports"`
+}
+
+// renderKubernetes renders a Deployment per container, and a Service named
+// after it so the other containers resolve it like on the Docker network
+func renderKubernetes(services []ServiceSpec) ([]byte, error) {
+	var docs [][]byte
+	for _, s := range services {
+		labels := map[string]string{"app": s.Name}
+
+		c := k8sContainer{
+			Name:    s.Name,
+			Image:   s.Image,
+			Command: s.Entrypoint,
+			Args:    s.Command,
+		}
+		for _, e := range s.Env {
+			name, value, _ := strings.Cut(e, "=")
+			c.Env = append(c.Env, k8sEnvVar{Name: name, Value: value})
+		}
+		var servicePorts []k8sPort
+		for _, port := range s.Ports {
+			p := nat.Port(port)
+			protocol := strings.ToUpper(p.Proto())
+			c.Ports = append(c.Ports, k8sPort{ContainerPort: p.Int(), Protocol: protocol})
+			servicePorts = append(servicePorts, k8sPort{
+				Name:       p.Proto() + "-" + p.Port(),
+				Port:       p.Int(),
+				TargetPort: p.Int(),
+				Protocol:   protocol,
+			})
+		}
+
+		var pod k8sPodSpec
+		for i, bind := range s.Binds {
+			parts := strings.Split(bind, ":")
+			if len(parts) < 2 {
+				continue
+			}
+			volume := k8sVolume{Name: "volume-" + strconv.Itoa(i)}
+			volume.HostPath.Path = parts[0]
+			pod.Volumes = append(pod.Volumes, volume)
+			c.VolumeMounts = append(c.VolumeMounts, k8sVolumeMount{
+				Name:      volume.Name,
+				MountPath: parts[1],
+				ReadOnly:  len(parts) > 2 && strings.Contains(parts[2], "ro"),
+			})
+		}
+		pod.Containers = []k8sContainer{c}
+
+		var deployment k8sDeploymentSpec
+		deployment.Replicas = 1
+		deployment.Selector.MatchLabels = labels
+		deployment.Template.Metadata = k8sMetadata{Labels: labels}
+		deployment.Template.Spec = pod
+
+		doc, err := marshalYAML(k8sObject{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Metadata:   k8sMetadata{Name: s.Name, Labels: labels},
+			Spec:       deployment,
+		})
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+
+		if len(servicePorts) > 0 {
+			doc, err := marshalYAML(k8sObject{
+				APIVersion: "v1",
+				Kind:       "Service",
+				Metadata:   k8sMetadata{Name: s.Name, Labels: labels},
+				Spec:       k8sServiceSpec{Selector: labels, Ports: servicePorts},
+			})
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+	}
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+func marshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to render YAML: %v", err)
+	}
+	encoder.Close()
+	return buf.Bytes(), nil
+}
+
+// WriteExport writes rendered output to path, or stdout when path is empty
+// or "-"
+func WriteExport(path string, data []byte) error {
+	if path == "" || path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	log.Printf("Wrote %s", path)
+	return nil
+}
@@ -11,11 +11,40 @@ import (
 	"github.com/docker/docker/api/types/network"
 )
 
+var hbaseUIs = []WebUI{
+	{
+		Name:        "master",
+		Description: "HBase Master UI",
+		Container:   "dbin-hbase",
+		Port:        "16010/tcp",
+		Attempts:    12,
+	},
+}
+
+const (
+	hbaseImage     = "harisekhon/hbase:latest"
+	zookeeperImage = "zookeeper:latest"
+)
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "hbase",
 		Description: "Apache HBase database",
+		Category:    CategoryWideColumn,
+		Tags:        []string{"hadoop", "nosql"},
 		Manager:     NewHBaseManager,
+		UIs:         hbaseUIs,
+		Image:       hbaseImage,
+		Port:        "16010/tcp",
+		DataPath:    "/data",
+		Containers: []ContainerInfo{
+			{Name: "dbin-zookeeper", Image: zookeeperImage, Ports: []string{"2181/tcp"}, DataPath: "/data", Role: "Coordination service"},
+			{Name: "dbin-hbase", Image: hbaseImage, Ports: []string{"16010/tcp"}, DataPath: "/data", Role: "HBase master and region server"},
+		},
+		Memory:    "2 GB",
+		Readiness: "Waits 10 seconds for ZooKeeper before starting HBase, then polls the web interface up to 12 times 5 seconds apart",
+		Docs:      "https://hbase.apache.org/book.html",
+		Tutorial:  "https://hbase.apache.org/book.html#quickstart",
 	})
 }
 
@@ -25,8 +54,8 @@ type HBaseManager struct {
 	networkId            string
 }
 
-func NewHBaseManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewHBaseManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -41,8 +70,8 @@ func (hm *HBaseManager) StartDatabase() error {
 
 	// Pull required images
 	images := []string{
-		"zookeeper:latest",
-		"harisekhon/hbase:latest",
+		zookeeperImage,
+		hbaseImage,
 	}
 	for _, image := range images {
 		if err := hm.PullImageIfNeeded(ctx, image); err != nil {
@@ -60,7 +89,7 @@ func (hm *HBaseManager) StartDatabase() error {
 
 	// Start ZooKeeper first
 	zookeeperEnv := []string{}
-	containerId, _, err := hm.CreateContainer(ctx, "zookeeper:latest", "dbin-zookeeper", "2181/tcp", zookeeperEnv, "/data", nil)
+	containerId, _, err := hm.CreateContainer(ctx, zookeeperImage, "dbin-zookeeper", "2181/tcp", zookeeperEnv, "/data", nil)
 	if err != nil {
 		return err
 	}
@@ -79,7 +108,7 @@ func (hm *HBaseManager) StartDatabase() error {
 		"HBASE_CONF_hbase_zookeeper_quorum=dbin-zookeeper",
 	}
 
-	containerId, port, err := hm.CreateContainer(ctx, "harisekhon/hbase:latest", "dbin-hbase", "16010/tcp", hbaseEnv, "/data", nil)
+	containerId, port, err := hm.CreateContainer(ctx, hbaseImage, "dbin-hbase", "16010/tcp", hbaseEnv, "/data", nil)
 	if err != nil {
 		return err
 	}
@@ -96,6 +125,9 @@ func (hm *HBaseManager) StartDatabase() error {
 }
 
 func (hm *HBaseManager) StartClient() error {
+	if hm.WantsWebUI() {
+		return hm.OpenWebUI(hbaseUIs)
+	}
 	return hm.StartContainerClient("hbase", "shell")
 }
 
@@ -0,0 +1,409 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// pullAttempts is how many times a rate limited pull is tried
+const pullAttempts = 5
+
+// PullImage pulls an image for a platform, the daemon's when empty, showing
+// its progress, and records it as pulled by dbin for dbin images prune.
+// Images are pulled from the mirror configured for them, with the
+// credentials of docker login, and tagged with their usual name. Rate
+// limited pulls are retried with backoff.
+func PullImage(ctx context.Context, cli *client.Client, imageName, platform string) error {
+	config, err := loadRegistryConfig()
+	if err != nil {
+		return err
+	}
+	source := config.rewrite(imageName)
+	if source != imageName {
+		log.Printf("Pulling %s from %s", imageName, source)
+	}
+	auth, err := registryAuth(source)
+	if err != nil {
+		return err
+	}
+
+	backoff := 10 * time.Second
+	for attempt := 1; ; attempt++ {
+		err = pullOnce(ctx, cli, source, auth, platform)
+		if err == nil || !isRateLimited(err) || attempt == pullAttempts {
+			break
+		}
+		log.Printf("%s is rate limited, retrying in %s (attempt %d/%d)", registryHost(source), backoff, attempt, pullAttempts)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return err
+	}
+
+	// Digests can't be tagged, the mirror's reference is the one to run
+	pulled := imageName
+	if source != imageName {
+		if strings.Contains(imageName, "@") {
+			pulled = source
+		} else if err := cli.ImageTag(ctx, source, imageName); err != nil {
+			return fmt.Errorf("failed to tag %s as %s: %v", source, imageName, err)
+		}
+	}
+
+	inspect, _, err := cli.ImageInspectWithRaw(ctx, pulled)
+	if err != nil {
+		return fmt.Errorf("failed to inspect pulled image: %v", err)
+	}
+	if err := recordPull(pulled, inspect); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+func pullOnce(ctx context.Context, cli *client.Client, ref, auth, platform string) error {
+	reader, err := cli.ImagePull(ctx, ref, image.PullOptions{RegistryAuth: auth, Platform: platform})
+	if err != nil {
+		return fmt.Errorf("failed to pull image: %v", err)
+	}
+	defer reader.Close()
+	return renderPull(ref, reader)
+}
+
+// pulledImage records an image pulled by dbin
+type pulledImage struct {
+	Reference string    `json:"reference"`
+	ID        string    `json:"id"`
+	Platform  string    `json:"platform,omitempty"`
+	Pulled    time.Time `json:"pulled"`
+}
+
+func pulledImagesPath() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "images.json"), nil
+}
+
+func loadPulledImages() ([]pulledImage, error) {
+	path, err := pulledImagesPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pulled images: %v", err)
+	}
+	var pulled []pulledImage
+	if err := json.Unmarshal(data, &pulled); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return pulled, nil
+}
+
+func savePulledImages(pulled []pulledImage) error {
+	path, err := pulledImagesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+	data, err := json.MarshalIndent(pulled, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pulled images: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write pulled images: %v", err)
+	}
+	return nil
+}
+
+func recordPull(ref string, img types.ImageInspect) error {
+	pulled, err := loadPulledImages()
+	if err != nil {
+		return err
+	}
+	for _, p := range pulled {
+		if p.Reference == ref && p.ID == img.ID {
+			return nil
+		}
+	}
+	platform := formatPlatform(ocispec.Platform{OS: img.Os, Architecture: img.Architecture, Variant: img.Variant})
+	return savePulledImages(append(pulled, pulledImage{Reference: ref, ID: img.ID, Platform: platform, Pulled: time.Now()}))
+}
+
+// DatabaseImages returns the images of the containers of a database, with
+// version applied like --version does. The main image is replaced by the one
+// the database declares for arch, like archImage does; every image it may
+// run is returned when arch is empty.
+func DatabaseImages(info DatabaseInfo, version, arch string) []string {
+	var images []string
+	add := func(ref string) {
+		if !contains(images, ref) {
+			images = append(images, ref)
+		}
+	}
+	for _, c := range info.AllContainers() {
+		ref := info.versionedImage(c.Image, version)
+		if alternative, ok := info.ArchImages[arch]; ok && ref == info.Image {
+			ref = alternative
+		}
+		add(ref)
+	}
+	if arch == "" {
+		var archs []string
+		for a := range info.ArchImages {
+			archs = append(archs, a)
+		}
+		sort.Strings(archs)
+		for _, a := range archs {
+			add(info.ArchImages[a])
+		}
+	}
+	return images
+}
+
+// platformImage is an image and the platform it is pulled for, empty for
+// the daemon's
+type platformImage struct {
+	ref      string
+	platform string
+}
+
+// imageManager returns a manager resolving the images of a database in a
+// stack file, or named on the command line, like the one starting it
+func imageManager(cli *client.Client, d StackDatabase) *BaseManager {
+	return &BaseManager{
+		opts:      Options{Name: d.Name, Version: d.Version, Platform: d.Platform},
+		dockerCli: cli,
+	}
+}
+
+// platformImages returns the images the database runs on the platform set
+// with --platform, or the daemon's, each with the platform to pull it for:
+// images lacking that platform are pulled for one they have
+func (bm *BaseManager) platformImages(ctx context.Context) ([]platformImage, error) {
+	info, err := GetDatabaseInfo(bm.opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	var images []platformImage
+	for _, ref := range DatabaseImages(info, bm.opts.Version, bm.targetPlatform(ctx).Architecture) {
+		images = append(images, platformImage{ref: ref, platform: bm.choosePlatform(ctx, ref)})
+	}
+	return images, nil
+}
+
+// PullDatabaseImages pulls the images the given databases need ahead of
+// time. Images already present are only pulled again with refresh, to pick
+// up new builds of moving tags like latest.
+func PullDatabaseImages(databases []StackDatabase, refresh bool) error {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	var pulled []string
+	for _, d := range databases {
+		bm := imageManager(cli, d)
+		images, err := bm.platformImages(ctx)
+		if err != nil {
+			return err
+		}
+		for _, img := range images {
+			if contains(pulled, img.ref) {
+				continue
+			}
+			pulled = append(pulled, img.ref)
+			if !refresh {
+				if local, _, err := cli.ImageInspectWithRaw(ctx, img.ref); err == nil && bm.checkLocalPlatform(ctx, img.ref, local) {
+					log.Printf("%s is already pulled", img.ref)
+					continue
+				}
+			}
+			log.Printf("Pulling %s...", img.ref)
+			if err := PullImage(ctx, cli, img.ref, img.platform); err != nil {
+				return fmt.Errorf("%s: %v", img.ref, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LocalImage is a local image of one of the repositories dbin runs
+type LocalImage struct {
+	Databases []string  `json:"databases"`
+	Reference string    `json:"reference"`
+	ID        string    `json:"id"`
+	Size      int64     `json:"size"`
+	Created   time.Time `json:"created"`
+	Default   bool      `json:"default"` // Tag dbin runs without --version
+	ByDbin    bool      `json:"pulled_by_dbin"`
+}
+
+// ListDatabaseImages returns the local images of the repositories the
+// containers of the databases run
+func ListDatabaseImages() ([]LocalImage, error) {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	summaries, err := cli.ImageList(context.Background(), image.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %v", err)
+	}
+	pulled, err := loadPulledImages()
+	if err != nil {
+		return nil, err
+	}
+
+	repositories := make(map[string][]string) // Repository to databases
+	defaults := make(map[string]bool)
+	for _, info := range GetAllDatabases() {
+		for _, ref := range DatabaseImages(info, "", "") {
+			repository, tag := normalizeImage(ref)
+			if !contains(repositories[repository], info.Name) {
+				repositories[repository] = append(repositories[repository], info.Name)
+			}
+			defaults[repository+":"+tag] = true
+		}
+	}
+
+	var images []LocalImage
+	for _, summary := range summaries {
+		for _, ref := range summary.RepoTags {
+			repository, tag := normalizeImage(ref)
+			databases, ok := repositories[repository]
+			if !ok {
+				continue
+			}
+			sort.Strings(databases)
+			img := LocalImage{
+				Databases: databases,
+				Reference: repository + ":" + tag,
+				ID:        summary.ID,
+				Size:      summary.Size,
+				Created:   time.Unix(summary.Created, 0),
+				Default:   defaults[repository+":"+tag],
+			}
+			for _, p := range pulled {
+				img.ByDbin = img.ByDbin || p.ID == summary.ID
+			}
+			images = append(images, img)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Reference < images[j].Reference
+	})
+	return images, nil
+}
+
+// PruneImages removes the images pulled by dbin that are not the configured
+// version of a database anymore: tags other than the default ones or those
+// in keep, and older builds of a tag that was pulled again. Images of
+// containers are kept. It returns what it removed, or would remove with
+// dryRun.
+func PruneImages(keep []string, dryRun bool) ([]string, error) {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	pulled, err := loadPulledImages()
+	if err != nil {
+		return nil, err
+	}
+
+	configured := make(map[string]bool)
+	for _, info := range GetAllDatabases() {
+		for _, ref := range DatabaseImages(info, "", "") {
+			configured[ref] = true
+		}
+		for _, c := range info.Clients {
+			if c.Image != "" {
+				configured[c.Image] = true
+			}
+		}
+	}
+	for _, ref := range keep {
+		configured[ref] = true
+	}
+
+	var removed []string
+	var remaining []pulledImage
+	for _, p := range pulled {
+		current, _, err := cli.ImageInspectWithRaw(ctx, p.Reference)
+		superseded := err != nil || current.ID != p.ID
+		if !superseded && configured[p.Reference] {
+			remaining = append(remaining, p)
+			continue
+		}
+
+		// Untag stale references, and delete older builds by ID
+		target := p.Reference
+		if superseded {
+			if _, _, err := cli.ImageInspectWithRaw(ctx, p.ID); err != nil {
+				continue // Already gone
+			}
+			target = p.ID
+		}
+		label := p.Reference
+		if superseded {
+			label = fmt.Sprintf("%s (older build %s)", p.Reference, shortID(p.ID))
+		}
+
+		if dryRun {
+			removed = append(removed, label)
+			remaining = append(remaining, p)
+			continue
+		}
+		if _, err := cli.ImageRemove(ctx, target, image.RemoveOptions{PruneChildren: true}); err != nil {
+			log.Printf("Keeping %s: %v", label, err)
+			remaining = append(remaining, p)
+			continue
+		}
+		removed = append(removed, label)
+	}
+
+	if !dryRun {
+		if err := savePulledImages(remaining); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
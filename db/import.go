@@ -0,0 +1,538 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeFiles are the files dbin import reads by default, in the order
+// docker compose looks for them
+var ComposeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+type composeProject struct {
+	Name     string                    `yaml:"name"`
+	Services yaml.Node                 `yaml:"services"`
+	Volumes  map[string]*composeVolume `yaml:"volumes"`
+}
+
+type composeVolume struct {
+	Name     string      `yaml:"name"`
+	External interface{} `yaml:"external"`
+}
+
+type importedService struct {
+	Image       string         `yaml:"image"`
+	Environment composeEnv     `yaml:"environment"`
+	Ports       []composePort  `yaml:"ports"`
+	Volumes     []composeMount `yaml:"volumes"`
+}
+
+// importedKeys are the settings of a compose service that dbin import
+// either maps or can safely leave to dbin
+var importedKeys = []string{
+	"image", "environment", "ports", "volumes", "container_name", "hostname",
+	"restart", "healthcheck", "depends_on", "networks", "expose", "labels",
+}
+
+// composeEnv holds KEY=value variables, from either a list or a mapping
+type composeEnv []string
+
+func (e *composeEnv) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*e = list
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			key, v := value.Content[i].Value, value.Content[i+1]
+			if v.Tag == "!!null" {
+				*e = append(*e, key)
+				continue
+			}
+			*e = append(*e, key+"="+v.Value)
+		}
+	default:
+		return fmt.Errorf("environment must be a list or a mapping")
+	}
+	return nil
+}
+
+// composePort is a port of a service, in short or long syntax
+type composePort struct {
+	Host      string
+	Container string
+	Protocol  string
+}
+
+func (p *composePort) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var long struct {
+			Target    string `yaml:"target"`
+			Published string `yaml:"published"`
+			Protocol  string `yaml:"protocol"`
+		}
+		if err := value.Decode(&long); err != nil {
+			return err
+		}
+		*p = composePort{Host: long.Published, Container: long.Target, Protocol: long.Protocol}
+	} else {
+		// [[ip:]host:]container[/protocol]
+		spec, protocol, _ := strings.Cut(value.Value, "/")
+		parts := strings.Split(spec, ":")
+		p.Container = parts[len(parts)-1]
+		if len(parts) > 1 {
+			p.Host = parts[len(parts)-2]
+		}
+		p.Protocol = protocol
+	}
+	if p.Protocol == "" {
+		p.Protocol = "tcp"
+	}
+	return nil
+}
+
+// composeMount is a volume of a service, in short or long syntax
+type composeMount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+func (m *composeMount) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var long struct {
+			Source   string `yaml:"source"`
+			Target   string `yaml:"target"`
+			ReadOnly bool   `yaml:"read_only"`
+		}
+		if err := value.Decode(&long); err != nil {
+			return err
+		}
+		*m = composeMount{Source: long.Source, Target: long.Target, ReadOnly: long.ReadOnly}
+		return nil
+	}
+	parts := strings.Split(value.Value, ":")
+	switch len(parts) {
+	case 1:
+		m.Target = parts[0]
+	default:
+		m.Source, m.Target = parts[0], parts[1]
+		m.ReadOnly = len(parts) > 2 && strings.Contains(parts[2], "ro")
+	}
+	return nil
+}
+
+// isPath tells host paths from named volumes
+func isPath(source string) bool {
+	return strings.ContainsRune(source, '/') || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~")
+}
+
+// ImportCompose maps the database services of a compose file onto the
+// databases of dbin, as a stack to be written to stackPath. It also returns
+// notes about what could not be imported.
+func ImportCompose(composePath, stackPath string) (*Stack, []string, error) {
+	data, err := os.ReadFile(composePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read compose file: %v", err)
+	}
+	var project composeProject
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %v", composePath, err)
+	}
+	if project.Services.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s has no services", composePath)
+	}
+
+	composeDir, err := filepath.Abs(filepath.Dir(composePath))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	stackDir, err := filepath.Abs(filepath.Dir(stackPath))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	dotenv, err := readDotEnv(filepath.Join(composeDir, ".env"))
+	if err != nil {
+		return nil, nil, err
+	}
+	im := &importer{
+		project:    project,
+		name:       composeProjectName(project.Name, composeDir),
+		composeDir: composeDir,
+		stackDir:   stackDir,
+		dotenv:     dotenv,
+	}
+
+	stack := &Stack{Path: stackPath, EnvFile: ".env"}
+	if _, err := os.Stat(filepath.Join(composeDir, ".env")); err == nil {
+		// dbin up would otherwise replace the file the compose project reads
+		stack.EnvFile = ".env.dbin"
+		im.note("Set env_file to .env.dbin, as .env belongs to the compose project")
+	}
+	content := project.Services.Content
+	for i := 0; i+1 < len(content); i += 2 {
+		name := content[i].Value
+		var service importedService
+		if err := content[i+1].Decode(&service); err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: invalid service %s: %v", composePath, content[i].Line, name, err)
+		}
+		d, ok := im.service(name, service, content[i+1])
+		if !ok {
+			continue
+		}
+		if _, exists := stack.Database(d.Name); exists {
+			im.note("Skipped service %s: %s is already imported from another service", name, d.Name)
+			continue
+		}
+		stack.Databases = append(stack.Databases, d)
+	}
+	if len(stack.Databases) == 0 {
+		return nil, im.notes, fmt.Errorf("no database services found in %s", composePath)
+	}
+	return stack, im.notes, nil
+}
+
+type importer struct {
+	project    composeProject
+	name       string // Project name, which prefixes named volumes
+	composeDir string
+	stackDir   string
+	dotenv     map[string]string // Variables of the .env file next to the compose file
+	notes      []string
+}
+
+func (im *importer) note(format string, args ...interface{}) {
+	im.notes = append(im.notes, fmt.Sprintf(format, args...))
+}
+
+// service maps one compose service onto a database
+func (im *importer) service(name string, service importedService, node *yaml.Node) (StackDatabase, bool) {
+	service.Image = im.interpolate(service.Image)
+	if service.Image == "" {
+		im.note("Skipped service %s: it has no image", name)
+		return StackDatabase{}, false
+	}
+	info, tag, ok := databaseForImage(service.Image)
+	if !ok {
+		if owner, ok := companionOf(service.Image); ok {
+			im.note("Skipped service %s: dbin starts %s along with %s", name, service.Image, owner)
+		} else {
+			im.note("Skipped service %s: %s is not a database dbin runs", name, service.Image)
+		}
+		return StackDatabase{}, false
+	}
+
+	d := StackDatabase{Name: info.Name}
+	if _, defaultTag := splitImage(info.Image); tag != defaultTag {
+		d.Version = tag
+	}
+
+	var ignored []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i].Value; !contains(importedKeys, key) {
+			ignored = append(ignored, key)
+		}
+	}
+	if len(ignored) > 0 {
+		im.note("Ignored %s of service %s, dbin configures %s itself", strings.Join(ignored, ", "), name, info.Name)
+	}
+
+	env := make(map[string]string)
+	for _, e := range service.Environment {
+		key, value, ok := strings.Cut(e, "=")
+		if !ok {
+			// Passed through from the shell running docker compose
+			value, ok = im.lookupEnv(key)
+			if !ok {
+				continue
+			}
+		}
+		env[key] = im.interpolate(value)
+	}
+	im.credentials(&d, info, env)
+	if len(env) > 0 {
+		d.Env = env
+	}
+
+	mainPort := portNumber(info.Port)
+	for _, p := range service.Ports {
+		if p.Container == mainPort && p.Protocol == "tcp" {
+			// Random host ports stay random
+			if host := im.interpolate(p.Host); host != "" && !strings.Contains(host, "-") {
+				d.Port = host
+			}
+			continue
+		}
+		im.note("Skipped port %s of service %s, dbin publishes %s only", p.Container, name, mainPort)
+	}
+
+	for _, m := range service.Volumes {
+		im.mount(&d, info, name, m)
+	}
+	return d, true
+}
+
+// credentials moves the variables setting the credentials of the image to
+// the user and password of the database, for the clients of dbin to use them
+func (im *importer) credentials(d *StackDatabase, info DatabaseInfo, env map[string]string) {
+	vars, ok := credentialsVars(info.CredentialsEnv, env)
+	if !ok {
+		return
+	}
+	password := env[vars.Password]
+	user, ok := env[vars.User]
+	if !ok {
+		// Images default to their usual user, e.g. postgres for POSTGRES_PASSWORD
+		user = info.Credentials.User
+	}
+
+	if user != info.Credentials.User {
+		d.User = user
+	}
+	if password != info.Credentials.Password {
+		d.Password = password
+	}
+	// Databases running without security by default need --auth
+	d.Auth = info.Auth
+
+	// dbin sets the credentials itself, so the other variables would only
+	// override them with values its clients don't know
+	for _, vars := range info.CredentialsEnv {
+		delete(env, vars.User)
+		delete(env, vars.Password)
+	}
+}
+
+// credentialsVars returns the variables of env setting the credentials,
+// preferring those that name the user too
+func credentialsVars(candidates []CredentialsEnv, env map[string]string) (CredentialsEnv, bool) {
+	var fallback []CredentialsEnv
+	for _, vars := range candidates {
+		if _, ok := env[vars.Password]; !ok {
+			continue
+		}
+		if _, ok := env[vars.User]; ok || vars.User == "" {
+			return vars, true
+		}
+		fallback = append(fallback, vars)
+	}
+	if len(fallback) > 0 {
+		return fallback[0], true
+	}
+	return CredentialsEnv{}, false
+}
+
+// mount keeps a volume of a service, turning the first one holding init
+// scripts into init
+func (im *importer) mount(d *StackDatabase, info DatabaseInfo, service string, m composeMount) {
+	if m.Source == "" {
+		im.note("Skipped anonymous volume %s of service %s", m.Target, service)
+		return
+	}
+
+	source := im.interpolate(m.Source)
+	if isPath(source) {
+		path, err := im.path(source)
+		if err != nil {
+			im.note("Skipped volume %s of service %s: %v", m.Source, service, err)
+			return
+		}
+		if info.InitDir != "" && d.Init == "" && (m.Target == info.InitDir || strings.HasPrefix(m.Target, info.InitDir+"/")) {
+			d.Init = path
+			return
+		}
+		source = path
+	} else {
+		source = im.volumeName(source)
+	}
+
+	volume := source + ":" + m.Target
+	if m.ReadOnly {
+		volume += ":ro"
+	}
+	d.Volumes = append(d.Volumes, volume)
+}
+
+// path returns a host path of the compose file relative to the directory
+// of the stack file
+func (im *importer) path(source string) (string, error) {
+	if strings.HasPrefix(source, "~") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %v", err)
+		}
+		source = filepath.Join(home, source[1:])
+	}
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(im.composeDir, source)
+	}
+	rel, err := filepath.Rel(im.stackDir, source)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return source, nil
+	}
+	return "./" + filepath.ToSlash(rel), nil
+}
+
+// volumeName returns the name docker compose gives a named volume, so the
+// database keeps its data
+func (im *importer) volumeName(name string) string {
+	if v := im.project.Volumes[name]; v != nil {
+		if v.Name != "" {
+			return v.Name
+		}
+		if external, _ := v.External.(bool); external {
+			return name
+		}
+	}
+	return im.name + "_" + name
+}
+
+var projectNameInvalid = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// composeProjectName returns the project name docker compose would use
+func composeProjectName(name, dir string) string {
+	if name == "" {
+		name = filepath.Base(dir)
+	}
+	return projectNameInvalid.ReplaceAllString(strings.ToLower(name), "")
+}
+
+// databaseForImage returns the database running image, and its tag
+func databaseForImage(image string) (DatabaseInfo, string, bool) {
+	repository, tag := normalizeImage(image)
+	for _, info := range GetAllDatabases() {
+		if r, _ := normalizeImage(info.Image); r == repository {
+			return info, tag, true
+		}
+	}
+	return DatabaseInfo{}, "", false
+}
+
+// companionOf returns the database whose companions include image
+func companionOf(image string) (string, bool) {
+	repository, _ := normalizeImage(image)
+	for _, info := range GetAllDatabases() {
+		for _, companion := range info.Companions {
+			if r, _ := normalizeImage(companion); r == repository {
+				return info.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// normalizeImage strips the default registry and digest from an image
+// reference
+func normalizeImage(image string) (string, string) {
+	image, _, _ = strings.Cut(image, "@")
+	repository, tag := splitImage(image)
+	repository = strings.TrimPrefix(repository, "docker.io/")
+	repository = strings.TrimPrefix(repository, "library/")
+	return repository, tag
+}
+
+// portNumber returns the number of a container port such as 5432/tcp
+func portNumber(port string) string {
+	number, _, _ := strings.Cut(port, "/")
+	return number
+}
+
+// interpolate expands ${VAR}, ${VAR:-default} and ${VAR-default} from the
+// environment and the .env file like docker compose does
+func (im *importer) interpolate(value string) string {
+	return os.Expand(value, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		if key, def, ok := strings.Cut(name, ":-"); ok {
+			if v, _ := im.lookupEnv(key); v != "" {
+				return v
+			}
+			return def
+		}
+		if key, def, ok := strings.Cut(name, "-"); ok {
+			if v, ok := im.lookupEnv(key); ok {
+				return v
+			}
+			return def
+		}
+		v, _ := im.lookupEnv(name)
+		return v
+	})
+}
+
+// lookupEnv returns a variable of the environment, or else of the .env file
+func (im *importer) lookupEnv(key string) (string, bool) {
+	if v, ok := os.LookupEnv(key); ok {
+		return v, true
+	}
+	v, ok := im.dotenv[key]
+	return v, ok
+}
+
+// readDotEnv reads the KEY=value lines of a .env file, if it exists
+func readDotEnv(path string) (map[string]string, error) {
+	vars := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return vars, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+	return vars, nil
+}
+
+// Save writes the stack to its file
+func (s *Stack) Save(header string) error {
+	databases := &yaml.Node{Kind: yaml.MappingNode}
+	for _, d := range s.Databases {
+		var value yaml.Node
+		if err := value.Encode(d); err != nil {
+			return fmt.Errorf("failed to render %s: %v", d.Name, err)
+		}
+		databases.Content = append(databases.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: d.Name}, &value)
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, HeadComment: header}
+	if s.EnvFile != "" && s.EnvFile != ".env" {
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "env_file"},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: s.EnvFile})
+	}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "databases"}, databases)
+
+	data, err := marshalYAML(root)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.Path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", s.Path, err)
+	}
+	return nil
+}
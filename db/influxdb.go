@@ -8,11 +8,41 @@ import (
 	"time"
 )
 
+var influxdbUIs = []WebUI{
+	{
+		Name:        "web",
+		Description: "InfluxDB UI",
+		Container:   "dbin-influxdb",
+		Port:        "8086/tcp",
+	},
+}
+
+var influxdbCredentials = Credentials{
+	User:     "admin",
+	Password: "password",
+	Token:    "my-super-secret-auth-token",
+}
+
+const influxdbImage = "influxdb:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "influxdb",
-		Description: "InfluxDB time-series database",
-		Manager:     NewInfluxDBManager,
+		Name:           "influxdb",
+		Description:    "InfluxDB time-series database",
+		Category:       CategoryTimeSeries,
+		Tags:           []string{"metrics", "flux"},
+		Manager:        NewInfluxDBManager,
+		UIs:            influxdbUIs,
+		Credentials:    influxdbCredentials,
+		Image:          influxdbImage,
+		Port:           "8086/tcp",
+		DataPath:       "/var/lib/influxdb2",
+		Memory:         "512 MB",
+		Readiness:      readinessWebUI,
+		CredentialsEnv: []CredentialsEnv{{User: "DOCKER_INFLUXDB_INIT_USERNAME", Password: "DOCKER_INFLUXDB_INIT_PASSWORD"}},
+		Scheme:         "http",
+		Docs:           "https://docs.influxdata.com/influxdb/v2/",
+		Tutorial:       "https://docs.influxdata.com/influxdb/v2/get-started/",
 	})
 }
 
@@ -20,8 +50,8 @@ type InfluxDBManager struct {
 	*BaseManager
 }
 
-func NewInfluxDBManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewInfluxDBManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,20 +64,28 @@ func NewInfluxDBManager(dataDir string, debug bool) DatabaseManager {
 func (im *InfluxDBManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := im.PullImageIfNeeded(ctx, "influxdb:latest"); err != nil {
+	if err := im.PullImageIfNeeded(ctx, influxdbImage); err != nil {
 		return err
 	}
 
+	creds, err := im.ResolveCredentials(influxdbCredentials)
+	if err != nil {
+		return err
+	}
+	if len(creds.Password) < 8 {
+		return fmt.Errorf("influxdb requires a password of at least 8 characters")
+	}
+
 	env := []string{
 		"DOCKER_INFLUXDB_INIT_MODE=setup",
-		"DOCKER_INFLUXDB_INIT_USERNAME=admin",
-		"DOCKER_INFLUXDB_INIT_PASSWORD=password",
+		"DOCKER_INFLUXDB_INIT_USERNAME=" + creds.User,
+		"DOCKER_INFLUXDB_INIT_PASSWORD=" + creds.Password,
 		"DOCKER_INFLUXDB_INIT_ORG=myorg",
 		"DOCKER_INFLUXDB_INIT_BUCKET=mybucket",
-		"DOCKER_INFLUXDB_INIT_ADMIN_TOKEN=my-super-secret-auth-token",
+		"DOCKER_INFLUXDB_INIT_ADMIN_TOKEN=" + creds.Token,
 	}
 
-	containerId, port, err := im.CreateContainer(ctx, "influxdb:latest", "dbin-influxdb", "8086/tcp", env, "/var/lib/influxdb2", nil)
+	containerId, port, err := im.CreateContainer(ctx, influxdbImage, "dbin-influxdb", "8086/tcp", env, "/var/lib/influxdb2", nil)
 	if err != nil {
 		return err
 	}
@@ -56,17 +94,17 @@ func (im *InfluxDBManager) StartDatabase() error {
 
 	log.Printf("InfluxDB is ready and listening on port %s\n", im.dbPort)
 	log.Println("\nInfluxDB Web Interface Credentials:")
-	log.Println("Username: admin")
-	log.Println("Password: password")
+	log.Printf("Username: %s", creds.User)
+	log.Printf("Password: %s", creds.Password)
 	log.Println("Organization: myorg")
 	log.Println("Bucket: mybucket")
-	log.Println("Token: my-super-secret-auth-token\n")
+	log.Printf("Token: %s", creds.Token)
 	
 	return nil
 }
 
 func (im *InfluxDBManager) StartClient() error {
-	return StartWebInterface(im.dbPort)
+	return im.OpenWebUI(influxdbUIs)
 }
 
 func (im *InfluxDBManager) Cleanup() error {
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DatabaseDetails is what dbin info shows about a database
+type DatabaseDetails struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Category    string             `json:"category"`
+	Tags        []string           `json:"tags"`
+	Containers  []ContainerDetails `json:"containers"`
+	ArchImages  map[string]string  `json:"arch_images,omitempty"` // Main image replacements by architecture
+	Credentials *Credentials       `json:"credentials,omitempty"` // Defaults, nil when the database has none
+	Memory      string             `json:"memory"`
+	Readiness   string             `json:"readiness"`
+	Clients     []ClientDetails    `json:"clients"`
+	WebUIs      []WebUIDetails     `json:"web_uis"`
+	Flags       []string           `json:"flags"` // Optional flags the database supports
+	Docs        string             `json:"docs,omitempty"`
+	Tutorial    string             `json:"tutorial,omitempty"`
+}
+
+// ContainerDetails is a container of a database, with its image when pulled
+type ContainerDetails struct {
+	ContainerInfo
+	Pulled    *bool `json:"pulled,omitempty"` // nil when Docker is not reachable
+	ImageSize int64 `json:"image_size,omitempty"`
+}
+
+// ClientDetails is a command-line client and the command dbin runs for it
+type ClientDetails struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Command     string `json:"command"`
+	Sidecar     string `json:"sidecar,omitempty"` // Image the client runs from, if not the database's
+}
+
+// WebUIDetails is a web interface and where it is served
+type WebUIDetails struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Container   string `json:"container"`
+	Port        string `json:"port"`
+	Path        string `json:"path,omitempty"`
+}
+
+// DescribeDatabase returns what starting a database involves, from its
+// registry entry. Images are looked up locally when Docker is reachable.
+func DescribeDatabase(name string) (DatabaseDetails, error) {
+	info, err := GetDatabaseInfo(name)
+	if err != nil {
+		return DatabaseDetails{}, err
+	}
+
+	details := DatabaseDetails{
+		Name:        info.Name,
+		Description: info.Description,
+		Category:    info.Category,
+		Tags:        info.Tags,
+		Memory:      info.Memory,
+		Readiness:   info.Readiness,
+		Docs:        info.Docs,
+		Tutorial:    info.Tutorial,
+		ArchImages:  info.ArchImages,
+		Clients:     []ClientDetails{},
+		WebUIs:      []WebUIDetails{},
+		Flags:       supportedFlags(info),
+	}
+	if !info.Credentials.IsZero() {
+		creds := info.Credentials
+		details.Credentials = &creds
+	}
+
+	containers := info.AllContainers()
+	for _, c := range containers {
+		details.Containers = append(details.Containers, ContainerDetails{ContainerInfo: c})
+	}
+	inspectImages(details.Containers)
+
+	conn := Connection{User: info.Credentials.User, Password: info.Credentials.Password}
+	for _, c := range info.Clients {
+		client := ClientDetails{Name: c.Name, Description: c.Description}
+		if c.Image != "" {
+			sidecarConn := conn
+			sidecarConn.Host, sidecarConn.Port = "127.0.0.1", c.Port
+			client.Command = shellJoin(append([]string{c.Command}, c.args(sidecarConn)...))
+			client.Sidecar = c.Image
+		} else {
+			args := append([]string{"docker", "exec", "-it", mainContainerName(info), c.Command}, c.args(conn)...)
+			client.Command = shellJoin(args)
+		}
+		details.Clients = append(details.Clients, client)
+	}
+	for _, ui := range info.UIs {
+		details.WebUIs = append(details.WebUIs, WebUIDetails{
+			Name:        ui.Name,
+			Description: ui.Description,
+			Container:   ui.Container,
+			Port:        ui.Port,
+			Path:        ui.Path,
+		})
+	}
+	return details, nil
+}
+
+// mainContainerName returns the container clients run in, which is the
+// one publishing the main port
+func mainContainerName(info DatabaseInfo) string {
+	containers := info.AllContainers()
+	for _, c := range containers {
+		if contains(c.Ports, info.Port) {
+			return c.Name
+		}
+	}
+	return containers[0].Name
+}
+
+// inspectImages fills in which images are pulled and their size, leaving
+// them unknown when Docker is not reachable
+func inspectImages(containers []ContainerDetails) {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return
+	}
+	defer cli.Close()
+	ctx := context.Background()
+	if _, err := cli.Ping(ctx); err != nil {
+		return
+	}
+
+	for i := range containers {
+		pulled := false
+		img, _, err := cli.ImageInspectWithRaw(ctx, containers[i].Image)
+		if err == nil {
+			pulled = true
+			containers[i].ImageSize = img.Size
+		}
+		containers[i].Pulled = &pulled
+	}
+}
+
+func supportedFlags(info DatabaseInfo) []string {
+	flags := []string{"--version", "--port", "--data-dir"}
+	if !info.Credentials.IsZero() {
+		flags = append(flags, "--user", "--password", "--random-credentials")
+	}
+	if info.InitDir != "" {
+		flags = append(flags, "--init")
+	}
+	if info.TLS {
+		flags = append(flags, "--tls", "--mtls")
+	}
+	if info.Auth {
+		flags = append(flags, "--auth")
+	}
+	if info.Nodes {
+		flags = append(flags, "--nodes")
+	}
+	if info.Protocol != "" {
+		flags = append(flags, "--trace-queries")
+	}
+	if len(info.UIs) > 1 {
+		flags = append(flags, "--ui")
+	}
+	return flags
+}
+
+// shellJoin quotes args that need it to show a copyable command
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$\\;&|<>()*?!`") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// FormatSize formats a size in bytes for humans
+func FormatSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "kMGTPE"[exp])
+}
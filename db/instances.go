@@ -0,0 +1,177 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Instance is a database started by dbin, from any command
+type Instance struct {
+	Name       string
+	Containers int
+	Running    int
+	Port       string // Host port of the main port, if published
+}
+
+// ListInstances returns the instances that have containers, by name
+func ListInstances(ctx context.Context, cli *client.Client) ([]Instance, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", labelInstance)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	byName := make(map[string]*Instance)
+	var instances []*Instance
+	for _, c := range containers {
+		name := c.Labels[labelInstance]
+		instance, ok := byName[name]
+		if !ok {
+			instance = &Instance{Name: name}
+			byName[name] = instance
+			instances = append(instances, instance)
+		}
+		instance.Containers++
+		if c.State == "running" {
+			instance.Running++
+		}
+		if port := hostPort(c); port != "" && instance.Port == "" {
+			instance.Port = port
+		}
+	}
+
+	var list []Instance
+	for _, instance := range instances {
+		list = append(list, *instance)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// InstanceLogs returns the last lines the main container of an instance
+// logged
+func InstanceLogs(ctx context.Context, cli *client.Client, name string, tail int) ([]string, error) {
+	info, err := GetDatabaseInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	containerName := mainContainerName(info)
+	inspect, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not running", name)
+	}
+
+	reader, err := cli.ContainerLogs(ctx, containerName, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       fmt.Sprint(tail),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logs: %v", err)
+	}
+	defer reader.Close()
+
+	// Without a TTY, stdout and stderr are multiplexed
+	var buf bytes.Buffer
+	if inspect.Config.Tty {
+		_, err = io.Copy(&buf, reader)
+	} else {
+		_, err = stdcopy.StdCopy(&buf, &buf, reader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logs: %v", err)
+	}
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n"), nil
+}
+
+// RestartInstance restarts the containers of an instance in the order they
+// were started
+func RestartInstance(ctx context.Context, cli *client.Client, name string) error {
+	containers, err := instanceContainers(ctx, cli, name)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("%s is not running", name)
+	}
+	sort.SliceStable(containers, func(i, j int) bool {
+		return containers[i].Created < containers[j].Created
+	})
+	for _, c := range containers {
+		log.Printf("Restarting %s...", strings.TrimPrefix(c.Names[0], "/"))
+		if err := cli.ContainerRestart(ctx, c.ID, container.StopOptions{}); err != nil {
+			return fmt.Errorf("failed to restart container: %v", err)
+		}
+	}
+	return nil
+}
+
+// SnapshotInstance archives the data directory of the main container of an
+// instance to a tar file in dir, and returns its path. The container is
+// paused meanwhile, so the files are consistent with each other.
+func SnapshotInstance(ctx context.Context, cli *client.Client, name, dir string) (string, error) {
+	info, err := GetDatabaseInfo(name)
+	if err != nil {
+		return "", err
+	}
+	if info.DataPath == "" {
+		return "", fmt.Errorf("%s has no data directory to snapshot", name)
+	}
+	containerName := mainContainerName(info)
+	var dataPath string
+	for _, c := range info.AllContainers() {
+		if c.Name == containerName {
+			dataPath = c.DataPath
+		}
+	}
+	if dataPath == "" {
+		dataPath = info.DataPath
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %v", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.tar", name, time.Now().Format("20060102-150405")))
+
+	if err := cli.ContainerPause(ctx, containerName); err != nil {
+		return "", fmt.Errorf("failed to pause %s: %v", containerName, err)
+	}
+	defer cli.ContainerUnpause(ctx, containerName)
+
+	reader, _, err := cli.CopyFromContainer(ctx, containerName, dataPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy %s: %v", dataPath, err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot: %v", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, reader); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,222 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+)
+
+// LockFile is the lockfile dbin reads from the current directory, or next to
+// the stack file for dbin up
+const LockFile = "dbin.lock"
+
+// Lock pins the image references dbin runs to the digests they had when
+// dbin lock was run, so everyone gets the same images from moving tags
+type Lock struct {
+	Path      string            `yaml:"-"`
+	Images    map[string]string `yaml:"images"`              // Reference, e.g. mongo:latest, to its digest
+	Platforms map[string]string `yaml:"platforms,omitempty"` // Platform of references not run natively, e.g. linux/amd64
+
+	registries *registryConfig // Mirrors the pinned images are pulled from
+}
+
+// LoadLock reads a lockfile, returning nil when it doesn't exist
+func LoadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %v", err)
+	}
+	lock := &Lock{Path: path}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if lock.registries, err = loadRegistryConfig(); err != nil {
+		return nil, err
+	}
+	for ref, digest := range lock.Images {
+		if !strings.HasPrefix(digest, "sha256:") {
+			return nil, fmt.Errorf("%s: invalid digest %q for %s", path, digest, ref)
+		}
+	}
+	return lock, nil
+}
+
+// Save writes the lockfile
+func (l *Lock) Save() error {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by dbin lock, commit it so everyone runs the same images.\n")
+	buf.WriteString("# Run dbin lock again to update the digests.\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(l); err != nil {
+		return fmt.Errorf("failed to encode lockfile: %v", err)
+	}
+	if err := os.WriteFile(l.Path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %v", err)
+	}
+	return nil
+}
+
+// pinned returns the reference by digest locked for ref, on its mirror if
+// it has one. It is safe to call on a nil lock.
+func (l *Lock) pinned(ref string) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+	digest, ok := l.Images[ref]
+	if !ok {
+		return "", false
+	}
+	repository, _ := splitImage(ref)
+	return l.registries.rewrite(repository + "@" + digest), true
+}
+
+// platform returns the platform locked for ref, empty for the daemon's. It
+// is safe to call on a nil lock.
+func (l *Lock) platform(ref string) string {
+	if l == nil {
+		return ""
+	}
+	return l.Platforms[ref]
+}
+
+// Pinned returns the references by digest of the lockfile
+func (l *Lock) Pinned() []string {
+	var refs []string
+	for ref := range l.Images {
+		pinned, _ := l.pinned(ref)
+		refs = append(refs, pinned)
+	}
+	return refs
+}
+
+// ResolveDigest returns the digest a reference has in its registry, which
+// for multi-platform images is the digest of the index. When the registry
+// can't be reached, the digest of the local image is used.
+func ResolveDigest(ctx context.Context, cli *client.Client, ref string) (string, error) {
+	dist, err := inspectDistribution(ctx, cli, ref)
+	if err == nil {
+		return dist.Descriptor.Digest.String(), nil
+	}
+	if digest, ok := localDigest(ctx, cli, ref); ok {
+		log.Printf("Warning: cannot reach the registry of %s, locking the local image: %v", ref, err)
+		return digest, nil
+	}
+	return "", fmt.Errorf("failed to resolve %s: %v", ref, err)
+}
+
+// localDigest returns the registry digest of a local image
+func localDigest(ctx context.Context, cli *client.Client, ref string) (string, bool) {
+	img, _, err := cli.ImageInspectWithRaw(ctx, ref)
+	if err != nil || len(img.RepoDigests) == 0 {
+		return "", false
+	}
+	repository, _ := normalizeImage(ref)
+	for _, d := range img.RepoDigests {
+		name, digest, _ := strings.Cut(d, "@")
+		if r, _ := normalizeImage(name); r == repository {
+			return digest, true
+		}
+	}
+	return "", false
+}
+
+// checkLocked warns when the local image of ref is not the locked one, as
+// dbin runs the locked image instead
+func checkLocked(ctx context.Context, cli *client.Client, ref, pinned string) {
+	img, _, err := cli.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return
+	}
+	_, locked, _ := strings.Cut(pinned, "@")
+	for _, d := range img.RepoDigests {
+		if strings.HasSuffix(d, "@"+locked) {
+			return
+		}
+	}
+	local := shortID(img.ID)
+	if digest, ok := localDigest(ctx, cli, ref); ok {
+		local = digest
+	}
+	log.Printf("Warning: local %s is %s but %s locks %s, using the locked image", ref, local, LockFile, locked)
+}
+
+// LockImages resolves the images of the given databases to their digests
+// and writes them to the lockfile at path. Entries of other images are kept
+// when merge is set.
+func LockImages(path string, databases []StackDatabase, merge bool) (*Lock, error) {
+	lock := &Lock{Path: path, Images: make(map[string]string), Platforms: make(map[string]string)}
+	if merge {
+		existing, err := LoadLock(path)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			lock.Images = existing.Images
+			for ref, platform := range existing.Platforms {
+				lock.Platforms[ref] = platform
+			}
+		}
+	}
+
+	cli, err := NewDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	for _, d := range databases {
+		info, err := GetDatabaseInfo(d.Name)
+		if err != nil {
+			return nil, err
+		}
+		bm := imageManager(cli, d)
+		images, err := bm.platformImages(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range info.Clients {
+			if c.Image != "" {
+				images = append(images, platformImage{ref: c.Image, platform: bm.choosePlatform(ctx, c.Image)})
+			}
+		}
+		for _, img := range images {
+			digest, err := ResolveDigest(ctx, cli, img.ref)
+			if err != nil {
+				return nil, err
+			}
+			if previous, ok := lock.Images[img.ref]; ok && previous != digest {
+				log.Printf("%s: %s -> %s", img.ref, previous, digest)
+			} else if !ok {
+				log.Printf("%s: %s", img.ref, digest)
+			}
+			lock.Images[img.ref] = digest
+			if img.platform != "" {
+				lock.Platforms[img.ref] = img.platform
+			} else {
+				delete(lock.Platforms, img.ref)
+			}
+		}
+	}
+
+	if err := lock.Save(); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// LockFor returns the path of the lockfile next to a stack file
+func LockFor(stackPath string) string {
+	return filepath.Join(filepath.Dir(stackPath), LockFile)
+}
@@ -5,16 +5,60 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
+var mariadbClients = []Client{
+	{
+		Name:        "mariadb",
+		Description: "MariaDB command-line client",
+		Command:     "mariadb",
+		Args: func(conn Connection) []string {
+			args := []string{"-u" + conn.User, "-p" + conn.Password}
+			if conn.Host != "" {
+				args = append(args, "-h", conn.Host, "-P", conn.Port)
+			}
+			if conn.Host != "" && conn.TLS != nil {
+				args = append(args, "--ssl", "--ssl-verify-server-cert", "--ssl-ca="+conn.TLS.CA)
+				if conn.TLS.Cert != "" {
+					args = append(args, "--ssl-cert="+conn.TLS.Cert, "--ssl-key="+conn.TLS.Key)
+				}
+			}
+			return args
+		},
+		Formats: map[string][]string{
+			"table": {"--table"},
+		},
+	},
+	mycliClient,
+}
+
+const mariadbImage = "mariadb:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "mariadb",
-		Description: "MariaDB database",
-		Manager:     NewMariaDBManager,
+		Name:           "mariadb",
+		Description:    "MariaDB database",
+		Category:       CategoryRelational,
+		Tags:           []string{"sql", "oltp", "mysql-compatible"},
+		Manager:        NewMariaDBManager,
+		Clients:        mariadbClients,
+		Credentials:    mysqlCredentials,
+		TLS:            true,
+		Protocol:       "mysql",
+		Image:          mariadbImage,
+		Port:           "3306/tcp",
+		DataPath:       "/var/lib/mysql",
+		Memory:         "512 MB",
+		Readiness:      readinessSQL,
+		CredentialsEnv: []CredentialsEnv{{User: "MYSQL_USER", Password: "MYSQL_PASSWORD"}, {User: "MARIADB_USER", Password: "MARIADB_PASSWORD"}, {Password: "MYSQL_ROOT_PASSWORD"}, {Password: "MARIADB_ROOT_PASSWORD"}},
+		InitDir:        "/docker-entrypoint-initdb.d",
+		Scheme:         "mysql",
+		Docs:           "https://mariadb.com/kb/en/documentation/",
+		Tutorial:       "https://mariadb.com/kb/en/a-mariadb-primer/",
 	})
 }
 
@@ -22,8 +66,8 @@ type MariaDBManager struct {
 	*BaseManager
 }
 
-func NewMariaDBManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewMariaDBManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -36,39 +80,73 @@ func NewMariaDBManager(dataDir string, debug bool) DatabaseManager {
 func (mm *MariaDBManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := mm.PullImageIfNeeded(ctx, "mariadb:latest"); err != nil {
+	if err := mm.PullImageIfNeeded(ctx, mariadbImage); err != nil {
+		return err
+	}
+
+	creds, err := mm.ResolveCredentials(mysqlCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
-		"MYSQL_ROOT_PASSWORD=root",
+		"MYSQL_ROOT_PASSWORD=" + creds.Password,
 		"MYSQL_DATABASE=test",
 	}
+	if creds.User != "root" {
+		env = append(env, "MYSQL_USER="+creds.User, "MYSQL_PASSWORD="+creds.Password)
+	}
 
-	containerId, port, err := mm.CreateContainer(ctx, "mariadb:latest", "dbin-mariadb", "3306/tcp", env, "/var/lib/mysql", nil)
+	tlsBind, err := mm.SetupTLS("dbin-mariadb")
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image:      mariadbImage,
+		Name:       "dbin-mariadb",
+		Port:       "3306/tcp",
+		Env:        env,
+		VolumePath: "/var/lib/mysql",
+	}
+	if tlsBind != "" {
+		spec.Binds = []string{tlsBind}
+		spec.Cmd = mysqlTLSCommand()
+	}
+
+	containerId, port, err := mm.CreateContainerWithSpec(ctx, spec)
 	if err != nil {
 		return err
 	}
 	mm.dbContainerId = containerId
 	mm.dbPort = port
 
-	fmt.Println("Waiting for database to be ready...")
+	log.Println("Waiting for database to be ready...")
 	if err := mm.waitForDatabase(); err != nil {
 		return fmt.Errorf("database failed to start: %v", err)
 	}
 
-	fmt.Printf("Database is ready and listening on port %s\n", mm.dbPort)
+	log.Printf("Database is ready and listening on port %s", mm.dbPort)
 	return nil
 }
 
 func (mm *MariaDBManager) waitForDatabase() error {
-	connStr := fmt.Sprintf("root:root@tcp(localhost:%s)/test", mm.dbPort)
+	params, err := mysqlDSNParams(mm.BaseManager)
+	if err != nil {
+		return err
+	}
+	connStr := fmt.Sprintf("%s:%s@tcp(localhost:%s)/test%s", mm.creds.User, mm.creds.Password, mm.dbPort, params)
 
 	for i := 0; i < 30; i++ {
-		fmt.Printf("Attempting database connection (attempt %d/30)...\n", i+1)
+		log.Printf("Attempting database connection (attempt %d/30)...", i+1)
 		db, err := sql.Open("mysql", connStr)
 		if err == nil {
 			err = db.Ping()
+			if err == nil && mm.opts.MTLS {
+				err = mysqlRequireX509(db)
+				db.Close()
+				return err
+			}
 			if err == nil {
 				db.Close()
 				return nil
@@ -82,7 +160,7 @@ func (mm *MariaDBManager) waitForDatabase() error {
 }
 
 func (mm *MariaDBManager) StartClient() error {
-	return mm.StartContainerClient("mariadb", "-uroot", "-proot")
+	return mm.StartClientFrom(mariadbClients)
 }
 
 func (mm *MariaDBManager) Cleanup() error {
@@ -5,14 +5,65 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os/exec"
+	"strings"
 	"time"
 )
 
+var mongoClients = []Client{
+	{
+		Name:        "mongosh",
+		Description: "MongoDB Shell",
+		Command:     "mongosh",
+		Args: func(conn Connection) []string {
+			var args []string
+			if conn.Host != "" {
+				args = append(args, "--host", conn.Host, "--port", conn.Port)
+			}
+			if conn.User != "" {
+				args = append(args, "-u", conn.User, "-p", conn.Password, "--authenticationDatabase", "admin")
+			}
+			if conn.TLS != nil {
+				args = append(args, "--tls", "--tlsCAFile", conn.TLS.CA)
+				if conn.TLS.CertKey != "" {
+					args = append(args, "--tlsCertificateKeyFile", conn.TLS.CertKey)
+				}
+			}
+			return args
+		},
+		Exec: []string{"--quiet"},
+	},
+}
+
+var mongoCredentials = Credentials{
+	User:     "root",
+	Password: "root",
+}
+
+const mongoImage = "mongo:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "mongo",
-		Description: "MongoDB database",
-		Manager:     NewMongoManager,
+		Name:           "mongo",
+		Description:    "MongoDB database",
+		Category:       CategoryDocument,
+		Tags:           []string{"json", "nosql"},
+		Manager:        NewMongoManager,
+		Clients:        mongoClients,
+		Credentials:    mongoCredentials,
+		TLS:            true,
+		Auth:           true,
+		Nodes:          true,
+		Image:          mongoImage,
+		Port:           "27017/tcp",
+		DataPath:       "/data/db",
+		Memory:         "512 MB per node",
+		Readiness:      readinessClient + ". With --nodes, pings every member and waits for a primary",
+		InitDir:        "/docker-entrypoint-initdb.d",
+		CredentialsEnv: []CredentialsEnv{{User: "MONGO_INITDB_ROOT_USERNAME", Password: "MONGO_INITDB_ROOT_PASSWORD"}},
+		Scheme:         "mongodb",
+		Docs:           "https://www.mongodb.com/docs/manual/",
+		Tutorial:       "https://www.mongodb.com/docs/manual/tutorial/getting-started/",
 	})
 }
 
@@ -20,8 +71,8 @@ type MongoManager struct {
 	*BaseManager
 }
 
-func NewMongoManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewMongoManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,11 +85,50 @@ func NewMongoManager(dataDir string, debug bool) DatabaseManager {
 func (mm *MongoManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := mm.PullImageIfNeeded(ctx, "mongo:latest"); err != nil {
+	if err := mm.PullImageIfNeeded(ctx, mongoImage); err != nil {
 		return err
 	}
 
-	containerId, port, err := mm.CreateContainer(ctx, "mongo:latest", "dbin-mongo", "27017/tcp", nil, "/data/db", nil)
+	tlsBind, err := mm.SetupTLS("dbin-mongo")
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image:      mongoImage,
+		Name:       "dbin-mongo",
+		Port:       "27017/tcp",
+		VolumePath: "/data/db",
+	}
+	if tlsBind != "" {
+		spec.Binds = []string{tlsBind}
+		args := []string{
+			"--tlsMode", "requireTLS",
+			"--tlsCertificateKeyFile", tlsServerPath + "/server.pem",
+			"--tlsCAFile", tlsServerPath + "/ca.pem",
+		}
+		if !mm.opts.MTLS {
+			args = append(args, "--tlsAllowConnectionsWithoutCertificates")
+		}
+		spec.Cmd = tlsServerCommand("mongodb", args...)
+	}
+
+	if mm.opts.Auth {
+		creds, err := mm.ResolveCredentials(mongoCredentials)
+		if err != nil {
+			return err
+		}
+		spec.Env = []string{
+			"MONGO_INITDB_ROOT_USERNAME=" + creds.User,
+			"MONGO_INITDB_ROOT_PASSWORD=" + creds.Password,
+		}
+	}
+
+	if mm.Clustered() {
+		return mm.startReplicaSet(ctx, spec)
+	}
+
+	containerId, port, err := mm.CreateContainerWithSpec(ctx, spec)
 	if err != nil {
 		return err
 	}
@@ -46,11 +136,58 @@ func (mm *MongoManager) StartDatabase() error {
 	mm.dbPort = port
 
 	log.Printf("MongoDB is ready and listening on port %s\n", mm.dbPort)
+	if mm.opts.Auth {
+		log.Printf("Username: %s", mm.creds.User)
+		log.Printf("Password: %s", mm.creds.Password)
+	}
+	return nil
+}
+
+// mongoReplicaSet is the name of the replica set started with --nodes
+const mongoReplicaSet = "dbin-rs"
+
+// startReplicaSet starts one mongod per node and initiates a replica set,
+// with the first node preferred as primary
+func (mm *MongoManager) startReplicaSet(ctx context.Context, spec ContainerSpec) error {
+	networkName, err := mm.CreateStackNetwork(ctx)
+	if err != nil {
+		return err
+	}
+
+	spec.Cmd = []string{"--replSet", mongoReplicaSet, "--bind_ip_all"}
+	var members []string
+	for i, name := range mm.nodeNames() {
+		containerId, _, err := mm.StartNode(ctx, spec, networkName, i+1)
+		if err != nil {
+			return err
+		}
+		if err := WaitForNode(containerId, name, 30, nil, "mongosh", "--quiet", "--eval", "db.adminCommand('ping')"); err != nil {
+			return err
+		}
+
+		priority := 1
+		if i == 0 {
+			priority = 2
+		}
+		members = append(members, fmt.Sprintf("{_id: %d, host: '%s:27017', priority: %d}", i, name, priority))
+	}
+
+	log.Println("Initiating replica set...")
+	initiate := fmt.Sprintf("rs.initiate({_id: '%s', members: [%s]})", mongoReplicaSet, strings.Join(members, ", "))
+	if out, err := exec.Command("docker", "exec", mm.dbContainerId, "mongosh", "--quiet", "--eval", initiate).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to initiate replica set: %v: %s", err, out)
+	}
+
+	if err := WaitForNode(mm.dbContainerId, "a primary to be elected", 30, nil, "mongosh", "--quiet", "--eval", "quit(db.hello().isWritablePrimary ? 0 : 1)"); err != nil {
+		return err
+	}
+
+	log.Printf("MongoDB replica set %s is ready with %d members, the primary listens on port %s\n", mongoReplicaSet, mm.Nodes(), mm.dbPort)
 	return nil
 }
 
 func (mm *MongoManager) StartClient() error {
-	return mm.StartContainerClient("mongosh")
+	return mm.StartClientFrom(mongoClients)
 }
 
 func (mm *MongoManager) Cleanup() error {
@@ -5,16 +5,143 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
+var mysqlClients = []Client{
+	{
+		Name:        "mysql",
+		Description: "MySQL command-line client",
+		Command:     "mysql",
+		Args: func(conn Connection) []string {
+			args := []string{"-u" + conn.User, "-p" + conn.Password}
+			if conn.Host != "" {
+				args = append(args, "-h", conn.Host, "-P", conn.Port)
+			}
+			if conn.Host != "" && conn.TLS != nil {
+				args = append(args, "--ssl-mode=VERIFY_IDENTITY", "--ssl-ca="+conn.TLS.CA)
+				if conn.TLS.Cert != "" {
+					args = append(args, "--ssl-cert="+conn.TLS.Cert, "--ssl-key="+conn.TLS.Key)
+				}
+			}
+			return args
+		},
+		Formats: map[string][]string{
+			"table": {"--table"},
+		},
+	},
+	mycliClient,
+}
+
+// mysqlCredentials are shared by the MySQL-compatible databases
+var mysqlCredentials = Credentials{
+	User:     "root",
+	Password: "root",
+}
+
+// mysqlTLSCommand runs the image entrypoint with the server options enabling
+// TLS, which it prepends the server binary to. Only encrypted TCP connections
+// are accepted.
+func mysqlTLSCommand() []string {
+	return tlsServerCommand("mysql",
+		"--ssl-ca="+tlsServerPath+"/ca.pem",
+		"--ssl-cert="+tlsServerPath+"/server.crt",
+		"--ssl-key="+tlsServerPath+"/server.key",
+		"--require-secure-transport=ON",
+	)
+}
+
+// mysqlRequireX509 requires a client certificate from the accounts connecting
+// over TCP, for --mtls. Local accounts keep using the socket of docker exec.
+func mysqlRequireX509(db *sql.DB) error {
+	rows, err := db.Query("SELECT user FROM mysql.user WHERE host = '%'")
+	if err != nil {
+		return fmt.Errorf("failed to list users: %v", err)
+	}
+	var users []string
+	for rows.Next() {
+		var user string
+		if err := rows.Scan(&user); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, user)
+	}
+	rows.Close()
+
+	for _, user := range users {
+		user = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(user)
+		if _, err := db.Exec(fmt.Sprintf("ALTER USER '%s'@'%%' REQUIRE X509", user)); err != nil {
+			return fmt.Errorf("failed to require a client certificate from %s: %v", user, err)
+		}
+	}
+	return nil
+}
+
+// mysqlDSNParams returns the DSN parameters used by dbin to check readiness,
+// registering the TLS configuration of the instance when needed
+func mysqlDSNParams(bm *BaseManager) (string, error) {
+	if !bm.TLSEnabled() {
+		return "", nil
+	}
+	config, err := bm.clientTLSConfig()
+	if err != nil {
+		return "", err
+	}
+	name := "dbin-" + bm.opts.Name
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return "", fmt.Errorf("failed to register TLS configuration: %v", err)
+	}
+	return "?tls=" + name, nil
+}
+
+// mycliClient is shared by the MySQL-compatible databases
+var mycliClient = Client{
+	Name:        "mycli",
+	Description: "MySQL REPL with auto-completion and syntax highlighting",
+	Command:     "mycli",
+	Image:       sidecarPythonImage,
+	Install:     "pip install --quiet --disable-pip-version-check --root-user-action=ignore mycli",
+	Port:        "3306",
+	Args: func(conn Connection) []string {
+		args := []string{"-h", conn.Host, "-P", conn.Port, "-u", conn.User, "-p", conn.Password}
+		if conn.TLS != nil {
+			args = append(args, "--ssl-ca", conn.TLS.CA)
+			if conn.TLS.Cert != "" {
+				args = append(args, "--ssl-cert", conn.TLS.Cert, "--ssl-key", conn.TLS.Key)
+			}
+		}
+		return args
+	},
+}
+
+const mysqlImage = "mysql:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "mysql",
-		Description: "MySQL database",
-		Manager:     NewMySQLManager,
+		Name:           "mysql",
+		Description:    "MySQL database",
+		Category:       CategoryRelational,
+		Tags:           []string{"sql", "oltp"},
+		Manager:        NewMySQLManager,
+		Clients:        mysqlClients,
+		Credentials:    mysqlCredentials,
+		TLS:            true,
+		Protocol:       "mysql",
+		Image:          mysqlImage,
+		Port:           "3306/tcp",
+		DataPath:       "/var/lib/mysql",
+		Memory:         "512 MB",
+		Readiness:      readinessSQL,
+		CredentialsEnv: []CredentialsEnv{{User: "MYSQL_USER", Password: "MYSQL_PASSWORD"}, {Password: "MYSQL_ROOT_PASSWORD"}},
+		InitDir:        "/docker-entrypoint-initdb.d",
+		Scheme:         "mysql",
+		Docs:           "https://dev.mysql.com/doc/",
+		Tutorial:       "https://dev.mysql.com/doc/refman/8.0/en/tutorial.html",
 	})
 }
 
@@ -22,8 +149,8 @@ type MySQLManager struct {
 	*BaseManager
 }
 
-func NewMySQLManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewMySQLManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -36,39 +163,73 @@ func NewMySQLManager(dataDir string, debug bool) DatabaseManager {
 func (mm *MySQLManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := mm.PullImageIfNeeded(ctx, "mysql:latest"); err != nil {
+	if err := mm.PullImageIfNeeded(ctx, mysqlImage); err != nil {
+		return err
+	}
+
+	creds, err := mm.ResolveCredentials(mysqlCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
-		"MYSQL_ROOT_PASSWORD=root",
+		"MYSQL_ROOT_PASSWORD=" + creds.Password,
 		"MYSQL_DATABASE=test",
 	}
+	if creds.User != "root" {
+		env = append(env, "MYSQL_USER="+creds.User, "MYSQL_PASSWORD="+creds.Password)
+	}
 
-	containerId, port, err := mm.CreateContainer(ctx, "mysql:latest", "dbin-mysql", "3306/tcp", env, "/var/lib/mysql", nil)
+	tlsBind, err := mm.SetupTLS("dbin-mysql")
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image:      mysqlImage,
+		Name:       "dbin-mysql",
+		Port:       "3306/tcp",
+		Env:        env,
+		VolumePath: "/var/lib/mysql",
+	}
+	if tlsBind != "" {
+		spec.Binds = []string{tlsBind}
+		spec.Cmd = mysqlTLSCommand()
+	}
+
+	containerId, port, err := mm.CreateContainerWithSpec(ctx, spec)
 	if err != nil {
 		return err
 	}
 	mm.dbContainerId = containerId
 	mm.dbPort = port
 
-	fmt.Println("Waiting for database to be ready...")
+	log.Println("Waiting for database to be ready...")
 	if err := mm.waitForDatabase(); err != nil {
 		return fmt.Errorf("database failed to start: %v", err)
 	}
 
-	fmt.Printf("Database is ready and listening on port %s\n", mm.dbPort)
+	log.Printf("Database is ready and listening on port %s", mm.dbPort)
 	return nil
 }
 
 func (mm *MySQLManager) waitForDatabase() error {
-	connStr := fmt.Sprintf("root:root@tcp(localhost:%s)/test", mm.dbPort)
+	params, err := mysqlDSNParams(mm.BaseManager)
+	if err != nil {
+		return err
+	}
+	connStr := fmt.Sprintf("%s:%s@tcp(localhost:%s)/test%s", mm.creds.User, mm.creds.Password, mm.dbPort, params)
 
 	for i := 0; i < 30; i++ {
-		fmt.Printf("Attempting database connection (attempt %d/30)...\n", i+1)
+		log.Printf("Attempting database connection (attempt %d/30)...", i+1)
 		db, err := sql.Open("mysql", connStr)
 		if err == nil {
 			err = db.Ping()
+			if err == nil && mm.opts.MTLS {
+				err = mysqlRequireX509(db)
+				db.Close()
+				return err
+			}
 			if err == nil {
 				db.Close()
 				return nil
@@ -82,7 +243,7 @@ func (mm *MySQLManager) waitForDatabase() error {
 }
 
 func (mm *MySQLManager) StartClient() error {
-	return mm.StartContainerClient("mysql", "-uroot", "-proot")
+	return mm.StartClientFrom(mysqlClients)
 }
 
 func (mm *MySQLManager) Cleanup() error {
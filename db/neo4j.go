@@ -8,11 +8,59 @@ import (
 	"time"
 )
 
+var neo4jUIs = []WebUI{
+	{
+		Name:        "browser",
+		Description: "Neo4j Browser",
+		Container:   "dbin-neo4j",
+		Port:        "7474/tcp",
+		Path:        "/browser/?dbms=neo4j://localhost:{port:7687/tcp}",
+	},
+}
+
+var neo4jCredentials = Credentials{
+	User:     "neo4j",
+	Password: "password",
+}
+
+var neo4jClients = []Client{
+	{
+		Name:        "cypher-shell",
+		Description: "Neo4j Cypher shell",
+		Command:     "cypher-shell",
+		Args: func(conn Connection) []string {
+			args := []string{"-u", conn.User, "-p", conn.Password}
+			if conn.Host != "" {
+				args = append(args, "-a", fmt.Sprintf("neo4j://%s:%s", conn.Host, conn.Port))
+			}
+			return args
+		},
+		Formats: map[string][]string{
+			"table": {"--format", "verbose"},
+		},
+	},
+}
+
+const neo4jImage = "neo4j:latest"
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "neo4j",
 		Description: "Neo4j database",
+		Category:    CategoryGraph,
+		Tags:        []string{"cypher"},
 		Manager:     NewNeo4jManager,
+		UIs:         neo4jUIs,
+		Clients:     neo4jClients,
+		Credentials: neo4jCredentials,
+		Image:       neo4jImage,
+		Port:        "7687/tcp",
+		DataPath:    "/data",
+		Memory:      "1 GB",
+		Readiness:   readinessClient,
+		Scheme:      "bolt",
+		Docs:        "https://neo4j.com/docs/",
+		Tutorial:    "https://neo4j.com/docs/getting-started/",
 	})
 }
 
@@ -20,8 +68,8 @@ type Neo4jManager struct {
 	*BaseManager
 }
 
-func NewNeo4jManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewNeo4jManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,15 +82,33 @@ func NewNeo4jManager(dataDir string, debug bool) DatabaseManager {
 func (nm *Neo4jManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := nm.PullImageIfNeeded(ctx, "neo4j:latest"); err != nil {
+	if err := nm.PullImageIfNeeded(ctx, neo4jImage); err != nil {
 		return err
 	}
 
+	if nm.opts.User != "" && nm.opts.User != neo4jCredentials.User {
+		return fmt.Errorf("neo4j only supports the %s user", neo4jCredentials.User)
+	}
+	creds, err := nm.ResolveCredentials(neo4jCredentials)
+	if err != nil {
+		return err
+	}
+	if len(creds.Password) < 8 {
+		return fmt.Errorf("neo4j requires a password of at least 8 characters")
+	}
+
 	env := []string{
-		"NEO4J_AUTH=neo4j/password",
+		fmt.Sprintf("NEO4J_AUTH=%s/%s", creds.User, creds.Password),
 	}
 
-	containerId, port, err := nm.CreateContainer(ctx, "neo4j:latest", "dbin-neo4j", "7687/tcp", env, "/data", nil)
+	containerId, port, err := nm.CreateContainerWithSpec(ctx, ContainerSpec{
+		Image:      neo4jImage,
+		Name:       "dbin-neo4j",
+		Port:       "7687/tcp",
+		ExtraPorts: []string{"7474/tcp"}, // Neo4j Browser
+		Env:        env,
+		VolumePath: "/data",
+	})
 	if err != nil {
 		return err
 	}
@@ -50,11 +116,15 @@ func (nm *Neo4jManager) StartDatabase() error {
 	nm.dbPort = port
 
 	log.Printf("Neo4j is ready and listening on port %s\n", nm.dbPort)
+	log.Println("Use --ui browser or 'dbin open neo4j' to open the Neo4j Browser")
 	return nil
 }
 
 func (nm *Neo4jManager) StartClient() error {
-	return nm.StartContainerClient("cypher-shell", "-u", "neo4j", "-p", "password")
+	if nm.WantsWebUI() {
+		return nm.OpenWebUI(neo4jUIs)
+	}
+	return nm.StartClientFrom(neo4jClients)
 }
 
 func (nm *Neo4jManager) Cleanup() error {
@@ -5,17 +5,72 @@ import (
 	_ "embed"
 	"fmt"
 	"log"
+	"net/http"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 )
 
+var opensearchUIs = []WebUI{
+	{
+		Name:        "dashboards",
+		Description: "OpenSearch Dashboards",
+		Container:   "dbin-opensearch-dashboards",
+		Port:        "5601/tcp",
+		Attempts:    36,
+	},
+	{
+		Name:        "api",
+		Description: "OpenSearch REST API",
+		Container:   "dbin-opensearch",
+		Port:        "9200/tcp",
+		Attempts:    12,
+	},
+}
+
+var opensearchCredentials = Credentials{
+	User:     "admin",
+	Password: "Dbin-Admin-1",
+}
+
+// opensearchCertsPath is where the server gets its copy of the certificates,
+// as OpenSearch only reads them from its config directory
+const opensearchCertsPath = "/usr/share/opensearch/config" + tlsMountPath
+
+const (
+	opensearchImage           = "opensearchproject/opensearch:latest"
+	opensearchDashboardsImage = "opensearchproject/opensearch-dashboards:latest"
+)
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "opensearch",
 		Description: "OpenSearch search engine",
+		Category:    CategorySearch,
+		Tags:        []string{"full-text", "analytics", "json"},
 		Manager:     NewOpenSearchManager,
+		UIs:         opensearchUIs,
+		Credentials: opensearchCredentials,
+		TLS:         true,
+		Auth:        true,
+		Nodes:       true,
+		AuthNodes:   true,
+		Image:       opensearchImage,
+		Port:        "9200/tcp",
+		DataPath:    "/usr/share/opensearch/data",
+		Containers: []ContainerInfo{
+			{Name: "dbin-opensearch", Image: opensearchImage, Ports: []string{"9200/tcp"}, DataPath: "/usr/share/opensearch/data", Role: "Search engine"},
+			{Name: "dbin-opensearch-dashboards", Image: opensearchDashboardsImage, Ports: []string{"5601/tcp"}, Role: "OpenSearch Dashboards web interface"},
+		},
+		Memory:     "2 GB (512 MB heap per node, plus Dashboards)",
+		Readiness:  "Waits 10 seconds before starting Dashboards, or polls the cluster health with --nodes and the security API with --auth every 5 seconds, then polls Dashboards up to 36 times 5 seconds apart",
+		Companions: []string{"opensearchproject/opensearch-dashboards"},
+		Scheme:     "http",
+		Docs:       "https://opensearch.org/docs/latest/",
+		Tutorial:   "https://opensearch.org/docs/latest/getting-started/",
 	})
 }
 
@@ -26,8 +81,8 @@ type OpenSearchManager struct {
 	dashboardsPort        string
 }
 
-func NewOpenSearchManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewOpenSearchManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -40,11 +95,11 @@ func NewOpenSearchManager(dataDir string, debug bool) DatabaseManager {
 func (om *OpenSearchManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := om.PullImageIfNeeded(ctx, "opensearchproject/opensearch:latest"); err != nil {
+	if err := om.PullImageIfNeeded(ctx, opensearchImage); err != nil {
 		return err
 	}
 
-	if err := om.PullImageIfNeeded(ctx, "opensearchproject/opensearch-dashboards:latest"); err != nil {
+	if err := om.PullImageIfNeeded(ctx, opensearchDashboardsImage); err != nil {
 		return err
 	}
 
@@ -56,39 +111,74 @@ func (om *OpenSearchManager) StartDatabase() error {
 	}
 
 	// Start OpenSearch container first
-	env := []string{
-		"discovery.type=single-node",
-		"OPENSEARCH_JAVA_OPTS=-Xms512m -Xmx512m",
-		"bootstrap.memory_lock=true",
-		"DISABLE_SECURITY_PLUGIN=true",
-		"OPENSEARCH_INITIAL_ADMIN_PASSWORD=admin",
+	spec := ContainerSpec{
+		Image: opensearchImage,
+		Name:  "dbin-opensearch",
+		Port:  "9200/tcp",
+		Env: []string{
+			"discovery.type=single-node",
+			"OPENSEARCH_JAVA_OPTS=-Xms512m -Xmx512m",
+			"bootstrap.memory_lock=true",
+		},
+		VolumePath: "/usr/share/opensearch/data",
 	}
-
-	// Create OpenSearch container with its native port
-	containerId, port, err := om.CreateContainer(ctx, "opensearchproject/opensearch:latest", "dbin-opensearch", "9200/tcp", env, "/usr/share/opensearch/data", nil)
-	if err != nil {
+	if err := om.configureSecurity(&spec); err != nil {
 		return err
 	}
-	om.opensearchContainerId = containerId
-	om.dbContainerId = containerId
-	om.dbPort = port
 
-	// Connect OpenSearch container to the network
-	if err := om.dockerCli.NetworkConnect(ctx, networkResponse.ID, om.opensearchContainerId, nil); err != nil {
-		return fmt.Errorf("failed to connect OpenSearch to network: %v", err)
-	}
+	if om.Clustered() {
+		// With --auth, nodes share the transport certificates of the demo configuration
+		if err := om.startSearchCluster(ctx, spec, networkName, "cluster.initial_cluster_manager_nodes"); err != nil {
+			return err
+		}
+		api, err := om.newSearchAPI(om.dbPort, om.opts.Auth, true)
+		if err != nil {
+			return err
+		}
+		// Requested users are only created once the cluster is up
+		api.creds.User = opensearchCredentials.User
+		if err := api.waitForNodes(om.Nodes()); err != nil {
+			return err
+		}
+		om.opensearchContainerId = om.dbContainerId
+	} else {
+		// Create OpenSearch container with its native port
+		containerId, port, err := om.CreateContainerWithSpec(ctx, spec)
+		if err != nil {
+			return err
+		}
+		om.opensearchContainerId = containerId
+		om.dbContainerId = containerId
+		om.dbPort = port
 
-	// Wait for OpenSearch to be ready
-	time.Sleep(10 * time.Second)
+		// Connect OpenSearch container to the network
+		if err := om.dockerCli.NetworkConnect(ctx, networkResponse.ID, om.opensearchContainerId, nil); err != nil {
+			return fmt.Errorf("failed to connect OpenSearch to network: %v", err)
+		}
+	}
 
 	// Start OpenSearch Dashboards container
-	dashboardsEnv := []string{
-		"DISABLE_SECURITY_DASHBOARDS_PLUGIN=true",
-		"OPENSEARCH_HOSTS=http://dbin-opensearch:9200",
+	dashboardsSpec := ContainerSpec{
+		Image: opensearchDashboardsImage,
+		Name:  "dbin-opensearch-dashboards",
+		Port:  "5601/tcp",
+		Env: []string{
+			"DISABLE_SECURITY_DASHBOARDS_PLUGIN=true",
+			"OPENSEARCH_HOSTS=http://dbin-opensearch:9200",
+		},
+	}
+
+	// Wait for OpenSearch to be ready
+	if om.opts.Auth {
+		if err := om.bootstrapUsers(&dashboardsSpec); err != nil {
+			return err
+		}
+	} else if !om.Clustered() {
+		time.Sleep(10 * time.Second)
 	}
 
 	// Create OpenSearch Dashboards container with port 5601
-	containerId, port, err = om.CreateContainer(ctx, "opensearchproject/opensearch-dashboards:latest", "dbin-opensearch-dashboards", "5601/tcp", dashboardsEnv, "", nil)
+	containerId, port, err := om.CreateContainerWithSpec(ctx, dashboardsSpec)
 	if err != nil {
 		return err
 	}
@@ -101,11 +191,126 @@ func (om *OpenSearchManager) StartDatabase() error {
 	}
 
 	log.Printf("OpenSearch is ready on port %s and Dashboards is accessible on port %s\n", om.dbPort, om.dashboardsPort)
+	if om.opts.Auth {
+		log.Printf("Username: %s", om.creds.User)
+		log.Printf("Password: %s", om.creds.Password)
+	}
 	return nil
 }
 
+// configureSecurity enables the security plugin, which serves HTTPS with its
+// demo certificates unless --tls replaces them with dbin's
+func (om *OpenSearchManager) configureSecurity(spec *ContainerSpec) error {
+	if !om.opts.Auth {
+		if om.opts.TLS {
+			return fmt.Errorf("--tls requires --auth for OpenSearch")
+		}
+		spec.Env = append(spec.Env,
+			"DISABLE_SECURITY_PLUGIN=true",
+			"OPENSEARCH_INITIAL_ADMIN_PASSWORD=admin",
+		)
+		return nil
+	}
+
+	creds, err := om.ResolveCredentials(opensearchCredentials)
+	if err != nil {
+		return err
+	}
+	if !strongPassword(creds.Password) {
+		return fmt.Errorf("OpenSearch requires a password of at least 8 characters with upper and lower case letters, digits and symbols")
+	}
+	// The built-in admin gets the same password and creates any other user
+	spec.Env = append(spec.Env, "OPENSEARCH_INITIAL_ADMIN_PASSWORD="+creds.Password)
+	spec.Labels = map[string]string{labelHTTPS: "true"}
+
+	tlsBind, err := om.SetupTLS("dbin-opensearch")
+	if err != nil {
+		return err
+	}
+	if !om.TLSEnabled() {
+		return nil
+	}
+
+	// The image runs as uid 1000, which gets its own copy of the keys. The
+	// mount is for clients running in the container.
+	spec.Binds = []string{tlsBind}
+	spec.TLSDir, spec.TLSUID = opensearchCertsPath, 1000
+	// Paths are relative to the config directory
+	spec.Env = append(spec.Env,
+		"plugins.security.ssl.http.pemcert_filepath=dbin-tls/server.crt",
+		"plugins.security.ssl.http.pemkey_filepath=dbin-tls/server.key",
+		"plugins.security.ssl.http.pemtrustedcas_filepath=dbin-tls/ca.pem",
+	)
+	if om.opts.MTLS {
+		spec.Env = append(spec.Env, "plugins.security.ssl.http.clientauth_mode=REQUIRE")
+	}
+	return nil
+}
+
+// bootstrapUsers waits for the security plugin, creates the requested admin
+// user and points Dashboards at the kibanaserver service account
+func (om *OpenSearchManager) bootstrapUsers(dashboardsSpec *ContainerSpec) error {
+	api, err := om.newSearchAPI(om.dbPort, true, true)
+	if err != nil {
+		return err
+	}
+	api.creds.User = opensearchCredentials.User
+
+	log.Println("Waiting for OpenSearch security to initialize...")
+	if err := api.waitUntilReady("/_plugins/_security/authinfo", 36); err != nil {
+		return err
+	}
+
+	if om.creds.User != opensearchCredentials.User {
+		err := api.do(http.MethodPut, "/_plugins/_security/api/internalusers/"+om.creds.User, map[string]interface{}{
+			"password":      om.creds.Password,
+			"backend_roles": []string{"admin"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create user %s: %v", om.creds.User, err)
+		}
+	}
+
+	// kibanaserver is a reserved account of the demo security configuration
+	log.Println("Dashboards connects as kibanaserver with password kibanaserver")
+	dashboardsSpec.Env = []string{
+		"OPENSEARCH_HOSTS=https://dbin-opensearch:9200",
+		"OPENSEARCH_USERNAME=kibanaserver",
+		"OPENSEARCH_PASSWORD=kibanaserver",
+	}
+	if !om.TLSEnabled() {
+		dashboardsSpec.Env = append(dashboardsSpec.Env, "OPENSEARCH_SSL_VERIFICATIONMODE=none")
+		return nil
+	}
+
+	files := om.containerTLSFiles()
+	dashboardsSpec.TLSDir, dashboardsSpec.TLSUID = tlsMountPath, 1000
+	dashboardsSpec.Env = append(dashboardsSpec.Env,
+		"OPENSEARCH_SSL_VERIFICATIONMODE=full",
+		"OPENSEARCH_SSL_CERTIFICATEAUTHORITIES="+files.CA,
+	)
+	if files.Cert != "" {
+		dashboardsSpec.Env = append(dashboardsSpec.Env,
+			"OPENSEARCH_SSL_CERTIFICATE="+files.Cert,
+			"OPENSEARCH_SSL_KEY="+files.Key,
+		)
+	}
+	return nil
+}
+
+// strongPassword mirrors the password policy of the OpenSearch security plugin
+func strongPassword(password string) bool {
+	return len(password) >= 8 &&
+		strings.ContainsAny(password, "abcdefghijklmnopqrstuvwxyz") &&
+		strings.ContainsAny(password, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+		strings.ContainsAny(password, "0123456789") &&
+		strings.IndexFunc(password, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) >= 0
+}
+
 func (om *OpenSearchManager) StartClient() error {
-	return StartWebInterface(om.dbPort)
+	return om.OpenWebUI(opensearchUIs)
 }
 
 func (om *OpenSearchManager) Cleanup() error {
@@ -131,6 +336,8 @@ func (om *OpenSearchManager) Cleanup() error {
 		}
 	}
 
+	om.cleanupNodes(ctx)
+
 	// Clean up the network
 	networks, err := om.dockerCli.NetworkList(ctx, network.ListOptions{})
 	if err == nil {
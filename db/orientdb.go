@@ -8,11 +8,38 @@ import (
 	"time"
 )
 
+var orientdbUIs = []WebUI{
+	{
+		Name:        "studio",
+		Description: "OrientDB Studio",
+		Container:   "dbin-orientdb",
+		Port:        "2480/tcp",
+	},
+}
+
+var orientdbCredentials = Credentials{
+	User:     "root",
+	Password: "root",
+}
+
+const orientdbImage = "orientdb:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "orientdb",
-		Description: "OrientDB multi-model database",
-		Manager:     NewOrientDBManager,
+		Name:           "orientdb",
+		Description:    "OrientDB multi-model database",
+		Category:       CategoryMultiModel,
+		Tags:           []string{"document", "graph", "sql"},
+		Manager:        NewOrientDBManager,
+		UIs:            orientdbUIs,
+		Credentials:    orientdbCredentials,
+		Image:          orientdbImage,
+		Port:           "2480/tcp",
+		DataPath:       "/orientdb/databases",
+		Memory:         "1 GB",
+		Readiness:      readinessWebUI,
+		CredentialsEnv: []CredentialsEnv{{Password: "ORIENTDB_ROOT_PASSWORD"}},
+		Docs:           "https://orientdb.org/docs/3.2.x/",
 	})
 }
 
@@ -20,8 +47,8 @@ type OrientDBManager struct {
 	*BaseManager
 }
 
-func NewOrientDBManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewOrientDBManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,15 +61,23 @@ func NewOrientDBManager(dataDir string, debug bool) DatabaseManager {
 func (om *OrientDBManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := om.PullImageIfNeeded(ctx, "orientdb:latest"); err != nil {
+	if err := om.PullImageIfNeeded(ctx, orientdbImage); err != nil {
+		return err
+	}
+
+	if om.opts.User != "" && om.opts.User != orientdbCredentials.User {
+		return fmt.Errorf("orientdb only supports the %s user", orientdbCredentials.User)
+	}
+	creds, err := om.ResolveCredentials(orientdbCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
-		"ORIENTDB_ROOT_PASSWORD=root",
+		"ORIENTDB_ROOT_PASSWORD=" + creds.Password,
 	}
 
-	containerId, port, err := om.CreateContainer(ctx, "orientdb:latest", "dbin-orientdb", "2480/tcp", env, "/orientdb/databases", nil)
+	containerId, port, err := om.CreateContainer(ctx, orientdbImage, "dbin-orientdb", "2480/tcp", env, "/orientdb/databases", nil)
 	if err != nil {
 		return err
 	}
@@ -55,9 +90,9 @@ func (om *OrientDBManager) StartDatabase() error {
 
 func (om *OrientDBManager) StartClient() error {
 	log.Println("\nOrientDB Web Interface Credentials:")
-	log.Println("Username: root")
-	log.Println("Password: root\n")
-	return StartWebInterface(om.dbPort)
+	log.Printf("Username: %s", om.creds.User)
+	log.Printf("Password: %s", om.creds.Password)
+	return om.OpenWebUI(orientdbUIs)
 }
 
 func (om *OrientDBManager) Cleanup() error {
@@ -4,16 +4,35 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"log"
 	"time"
 	"database/sql"
 	_ "github.com/lib/pq"
 )
 
+const pgvectorImage = "ankane/pgvector:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "pgvector",
-		Description: "PostgreSQL with pgvector extension",
-		Manager:     NewPgVectorManager,
+		Name:           "pgvector",
+		Description:    "PostgreSQL with pgvector extension",
+		Category:       CategoryVector,
+		Tags:           []string{"sql", "postgres", "embeddings"},
+		Manager:        NewPgVectorManager,
+		Clients:        postgresClients,
+		Credentials:    postgresCredentials,
+		TLS:            true,
+		Protocol:       "postgres",
+		Image:          pgvectorImage,
+		ArchImages:     map[string]string{"arm64": "pgvector/pgvector:pg16"},
+		Port:           "5432/tcp",
+		DataPath:       "/var/lib/postgresql/data",
+		Memory:         "256 MB",
+		Readiness:      readinessSQL,
+		CredentialsEnv: []CredentialsEnv{{User: "POSTGRES_USER", Password: "POSTGRES_PASSWORD"}},
+		InitDir:        "/docker-entrypoint-initdb.d",
+		Scheme:         "postgres",
+		Docs:           "https://github.com/pgvector/pgvector#readme",
 	})
 }
 
@@ -21,8 +40,8 @@ type PgVectorManager struct {
 	*BaseManager
 }
 
-func NewPgVectorManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewPgVectorManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -35,37 +54,59 @@ func NewPgVectorManager(dataDir string, debug bool) DatabaseManager {
 func (pm *PgVectorManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := pm.PullImageIfNeeded(ctx, "ankane/pgvector:latest"); err != nil {
+	if err := pm.PullImageIfNeeded(ctx, pgvectorImage); err != nil {
+		return err
+	}
+
+	creds, err := pm.ResolveCredentials(postgresCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
-		"POSTGRES_PASSWORD=postgres",
-		"POSTGRES_USER=postgres",
+		"POSTGRES_PASSWORD=" + creds.Password,
+		"POSTGRES_USER=" + creds.User,
 		"POSTGRES_DB=postgres",
 	}
 
-	containerId, port, err := pm.CreateContainer(ctx, "ankane/pgvector:latest", "dbin-pgvector", "5432/tcp", env, "/var/lib/postgresql/data", nil)
+	tlsBind, err := pm.SetupTLS("dbin-pgvector")
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image:      pgvectorImage,
+		Name:       "dbin-pgvector",
+		Port:       "5432/tcp",
+		Env:        env,
+		VolumePath: "/var/lib/postgresql/data",
+	}
+	if tlsBind != "" {
+		spec.Binds = []string{tlsBind}
+		spec.Cmd = postgresTLSCommand(pm.opts.MTLS)
+	}
+
+	containerId, port, err := pm.CreateContainerWithSpec(ctx, spec)
 	if err != nil {
 		return err
 	}
 	pm.dbContainerId = containerId
 	pm.dbPort = port
 
-	fmt.Println("Waiting for database to be ready...")
+	log.Println("Waiting for database to be ready...")
 	if err := pm.waitForDatabase(); err != nil {
 		return fmt.Errorf("database failed to start: %v", err)
 	}
 
-	fmt.Printf("pgvector is ready and listening on port %s\n", pm.dbPort)
+	log.Printf("pgvector is ready and listening on port %s", pm.dbPort)
 	return nil
 }
 
 func (pm *PgVectorManager) waitForDatabase() error {
-	connStr := fmt.Sprintf("host=localhost port=%s user=postgres password=postgres dbname=postgres sslmode=disable", pm.dbPort)
+	connStr := fmt.Sprintf("host=localhost port=%s user=%s password=%s dbname=postgres %s", pm.dbPort, quoteConnParam(pm.creds.User), quoteConnParam(pm.creds.Password), postgresSSLParams(pm.hostTLSFiles()))
 
 	for i := 0; i < 30; i++ {
-		fmt.Printf("Attempting database connection (attempt %d/30)...\n", i+1)
+		log.Printf("Attempting database connection (attempt %d/30)...", i+1)
 		db, err := sql.Open("postgres", connStr)
 		if err == nil {
 			err = db.Ping()
@@ -86,7 +127,7 @@ func (pm *PgVectorManager) waitForDatabase() error {
 }
 
 func (pm *PgVectorManager) StartClient() error {
-	return pm.StartContainerClient("psql", "-U", "postgres")
+	return pm.StartClientFrom(postgresClients)
 }
 
 func (pm *PgVectorManager) Cleanup() error {
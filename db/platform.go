@@ -0,0 +1,222 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ParsePlatform parses a platform as given to --platform, e.g. linux/amd64
+// or linux/arm/v7
+func ParsePlatform(s string) (ocispec.Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return ocispec.Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant] such as linux/amd64", s)
+	}
+	platform := ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+func formatPlatform(p ocispec.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// daemonPlatform returns the platform of the Docker daemon, which is the
+// one images run natively on
+func (bm *BaseManager) daemonPlatform(ctx context.Context) ocispec.Platform {
+	if bm.hostPlatform == nil {
+		platform := ocispec.Platform{OS: "linux", Architecture: runtime.GOARCH}
+		if version, err := bm.dockerCli.ServerVersion(ctx); err == nil {
+			platform = ocispec.Platform{OS: version.Os, Architecture: version.Arch}
+		}
+		bm.hostPlatform = &platform
+	}
+	return *bm.hostPlatform
+}
+
+// targetPlatform returns the platform to run images for: --platform, or the
+// daemon's
+func (bm *BaseManager) targetPlatform(ctx context.Context) ocispec.Platform {
+	if bm.opts.Platform != "" {
+		if platform, err := ParsePlatform(bm.opts.Platform); err == nil {
+			return platform
+		}
+	}
+	return bm.daemonPlatform(ctx)
+}
+
+// archImage returns the image a database declares to run instead of ref
+// on the target architecture, or ref
+func (bm *BaseManager) archImage(ref string) string {
+	info, err := GetDatabaseInfo(bm.opts.Name)
+	if err != nil || ref != info.Image {
+		return ref
+	}
+	arch := bm.targetPlatform(context.Background()).Architecture
+	if alternative, ok := info.ArchImages[arch]; ok {
+		if !bm.archNoted {
+			log.Printf("%s has no %s image, running %s instead", ref, arch, alternative)
+			bm.archNoted = true
+		}
+		return alternative
+	}
+	return ref
+}
+
+// choosePlatform returns the platform to pull ref for, from the platforms
+// its registry has: empty to let Docker pick the native one, the target
+// platform with --platform, or another one that needs emulation
+func (bm *BaseManager) choosePlatform(ctx context.Context, ref string) string {
+	target := bm.targetPlatform(ctx)
+	preferred := ""
+	if bm.opts.Platform != "" {
+		preferred = formatPlatform(target)
+	}
+
+	dist, err := inspectDistribution(ctx, bm.dockerCli, ref)
+	if err != nil {
+		return preferred
+	}
+	var available []ocispec.Platform
+	for _, p := range dist.Platforms {
+		// Attestations are listed as unknown/unknown
+		if p.OS != "unknown" {
+			available = append(available, p)
+		}
+	}
+	if len(available) == 0 {
+		return preferred
+	}
+	for _, p := range available {
+		if p.OS == target.OS && p.Architecture == target.Architecture && (target.Variant == "" || p.Variant == target.Variant) {
+			return preferred
+		}
+	}
+
+	fallback := available[0]
+	for _, p := range available {
+		if p.OS == "linux" && p.Architecture == "amd64" {
+			fallback = p
+		}
+	}
+	bm.warnEmulation(ctx, ref, target, fallback)
+	bm.setPlatform(ref, fallback)
+	return formatPlatform(fallback)
+}
+
+// pullPlatform returns the platform to pull imageName, the reference ref
+// resolves to, for: the one the lockfile records for ref unless --platform
+// is set, or the one choosePlatform finds
+func (bm *BaseManager) pullPlatform(ctx context.Context, ref, imageName string) string {
+	if locked := bm.lock.platform(ref); locked != "" && bm.opts.Platform == "" {
+		if platform, err := ParsePlatform(locked); err == nil {
+			bm.warnEmulation(ctx, imageName, bm.daemonPlatform(ctx), platform)
+			bm.setPlatform(imageName, platform)
+			return locked
+		}
+	}
+	return bm.choosePlatform(ctx, imageName)
+}
+
+// checkLocalPlatform checks the platform of a local image. It returns false
+// when another platform was asked for with --platform, so it gets pulled.
+func (bm *BaseManager) checkLocalPlatform(ctx context.Context, ref string, img types.ImageInspect) bool {
+	local := ocispec.Platform{OS: img.Os, Architecture: img.Architecture, Variant: img.Variant}
+	if bm.opts.Platform != "" {
+		target := bm.targetPlatform(ctx)
+		if local.OS != target.OS || local.Architecture != target.Architecture || (target.Variant != "" && local.Variant != target.Variant) {
+			log.Printf("Local %s image is %s, pulling %s", ref, formatPlatform(local), formatPlatform(target))
+			return false
+		}
+	}
+	if local.Architecture != bm.daemonPlatform(ctx).Architecture {
+		bm.warnEmulation(ctx, ref, bm.daemonPlatform(ctx), local)
+	}
+	bm.setPlatform(ref, local)
+	return true
+}
+
+func (bm *BaseManager) setPlatform(ref string, platform ocispec.Platform) {
+	if bm.platforms == nil {
+		bm.platforms = make(map[string]*ocispec.Platform)
+	}
+	bm.platforms[ref] = &platform
+}
+
+// warnEmulation tells that ref runs as another architecture than the
+// daemon's, and whether the daemon can emulate it
+func (bm *BaseManager) warnEmulation(ctx context.Context, ref string, target, actual ocispec.Platform) {
+	native := bm.daemonPlatform(ctx)
+	if actual.Architecture == native.Architecture {
+		return
+	}
+	if target.Architecture != actual.Architecture {
+		log.Printf("Warning: %s has no %s image, using %s", ref, formatPlatform(target), formatPlatform(actual))
+	}
+	switch bm.canEmulate(ctx, actual.Architecture) {
+	case emulationAvailable:
+		log.Printf("Warning: %s runs under %s emulation on this %s machine, expect it to be slower and some databases to crash",
+			ref, actual.Architecture, native.Architecture)
+	case emulationUnavailable:
+		log.Printf("Warning: this Docker cannot emulate %s, %s will likely fail with 'exec format error'. Install emulation with: docker run --privileged --rm tonistiigi/binfmt --install %s",
+			actual.Architecture, ref, actual.Architecture)
+	default:
+		log.Printf("Warning: %s needs %s emulation on this %s machine, which Docker Desktop provides; elsewhere see https://docs.docker.com/build/building/multi-platform/#qemu",
+			ref, actual.Architecture, native.Architecture)
+	}
+}
+
+const (
+	emulationUnknown = iota
+	emulationAvailable
+	emulationUnavailable
+)
+
+// qemuArchs maps architectures to the names of their QEMU binfmt handlers
+var qemuArchs = map[string]string{
+	"amd64":   "x86_64",
+	"arm64":   "aarch64",
+	"arm":     "arm",
+	"386":     "i386",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// canEmulate tells whether the daemon can run images of arch. Docker Desktop
+// always can; a local Linux daemon can when a binfmt handler is registered.
+func (bm *BaseManager) canEmulate(ctx context.Context, arch string) int {
+	info, err := bm.dockerCli.Info(ctx)
+	if err != nil {
+		return emulationUnknown
+	}
+	if strings.Contains(info.OperatingSystem, "Docker Desktop") {
+		return emulationAvailable
+	}
+	if runtime.GOOS != "linux" || !strings.HasPrefix(bm.dockerCli.DaemonHost(), "unix://") {
+		return emulationUnknown
+	}
+	handlers := []string{"qemu-" + qemuArchs[arch]}
+	if arch == "amd64" {
+		handlers = append(handlers, "rosetta")
+	}
+	for _, handler := range handlers {
+		if _, err := os.Stat("/proc/sys/fs/binfmt_misc/" + handler); err == nil {
+			return emulationAvailable
+		}
+	}
+	return emulationUnavailable
+}
@@ -4,16 +4,35 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"log"
 	"time"
 	"database/sql"
 	_ "github.com/lib/pq"
 )
 
+const postgisImage = "postgis/postgis:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "postgis",
-		Description: "PostGIS spatial database",
-		Manager:     NewPostGISManager,
+		Name:           "postgis",
+		Description:    "PostGIS spatial database",
+		Category:       CategorySpatial,
+		Tags:           []string{"sql", "postgres", "gis"},
+		Manager:        NewPostGISManager,
+		Clients:        postgresClients,
+		Credentials:    postgresCredentials,
+		TLS:            true,
+		Protocol:       "postgres",
+		Image:          postgisImage,
+		Port:           "5432/tcp",
+		DataPath:       "/var/lib/postgresql/data",
+		Memory:         "256 MB",
+		Readiness:      readinessSQL,
+		CredentialsEnv: []CredentialsEnv{{User: "POSTGRES_USER", Password: "POSTGRES_PASSWORD"}},
+		InitDir:        "/docker-entrypoint-initdb.d",
+		Scheme:         "postgres",
+		Docs:           "https://postgis.net/documentation/",
+		Tutorial:       "https://postgis.net/workshops/postgis-intro/",
 	})
 }
 
@@ -21,8 +40,8 @@ type PostGISManager struct {
 	*BaseManager
 }
 
-func NewPostGISManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewPostGISManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -35,37 +54,59 @@ func NewPostGISManager(dataDir string, debug bool) DatabaseManager {
 func (pm *PostGISManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := pm.PullImageIfNeeded(ctx, "postgis/postgis:latest"); err != nil {
+	if err := pm.PullImageIfNeeded(ctx, postgisImage); err != nil {
+		return err
+	}
+
+	creds, err := pm.ResolveCredentials(postgresCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
-		"POSTGRES_PASSWORD=postgres",
-		"POSTGRES_USER=postgres",
+		"POSTGRES_PASSWORD=" + creds.Password,
+		"POSTGRES_USER=" + creds.User,
 		"POSTGRES_DB=postgres",
 	}
 
-	containerId, port, err := pm.CreateContainer(ctx, "postgis/postgis:latest", "dbin-postgis", "5432/tcp", env, "/var/lib/postgresql/data", nil)
+	tlsBind, err := pm.SetupTLS("dbin-postgis")
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image:      postgisImage,
+		Name:       "dbin-postgis",
+		Port:       "5432/tcp",
+		Env:        env,
+		VolumePath: "/var/lib/postgresql/data",
+	}
+	if tlsBind != "" {
+		spec.Binds = []string{tlsBind}
+		spec.Cmd = postgresTLSCommand(pm.opts.MTLS)
+	}
+
+	containerId, port, err := pm.CreateContainerWithSpec(ctx, spec)
 	if err != nil {
 		return err
 	}
 	pm.dbContainerId = containerId
 	pm.dbPort = port
 
-	fmt.Println("Waiting for database to be ready...")
+	log.Println("Waiting for database to be ready...")
 	if err := pm.waitForDatabase(); err != nil {
 		return fmt.Errorf("database failed to start: %v", err)
 	}
 
-	fmt.Printf("PostGIS is ready and listening on port %s\n", pm.dbPort)
+	log.Printf("PostGIS is ready and listening on port %s", pm.dbPort)
 	return nil
 }
 
 func (pm *PostGISManager) waitForDatabase() error {
-	connStr := fmt.Sprintf("host=localhost port=%s user=postgres password=postgres dbname=postgres sslmode=disable", pm.dbPort)
+	connStr := fmt.Sprintf("host=localhost port=%s user=%s password=%s dbname=postgres %s", pm.dbPort, quoteConnParam(pm.creds.User), quoteConnParam(pm.creds.Password), postgresSSLParams(pm.hostTLSFiles()))
 
 	for i := 0; i < 30; i++ {
-		fmt.Printf("Attempting database connection (attempt %d/30)...\n", i+1)
+		log.Printf("Attempting database connection (attempt %d/30)...", i+1)
 		db, err := sql.Open("postgres", connStr)
 		if err == nil {
 			err = db.Ping()
@@ -82,7 +123,7 @@ func (pm *PostGISManager) waitForDatabase() error {
 }
 
 func (pm *PostGISManager) StartClient() error {
-	return pm.StartContainerClient("psql", "-U", "postgres")
+	return pm.StartClientFrom(postgresClients)
 }
 
 func (pm *PostGISManager) Cleanup() error {
@@ -5,16 +5,162 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
 )
 
+var postgresCredentials = Credentials{
+	User:     "postgres",
+	Password: "postgres",
+}
+
+var postgresClients = []Client{
+	{
+		Name:        "psql",
+		Description: "PostgreSQL interactive terminal",
+		Command:     "psql",
+		Args: func(conn Connection) []string {
+			args := []string{"-U", conn.User, "-d", "postgres"}
+			if conn.Host != "" {
+				args = append(args, "-h", conn.Host, "-p", conn.Port)
+			}
+			return args
+		},
+		Env:  postgresClientEnv,
+		Exec: []string{"-v", "ON_ERROR_STOP=1"},
+		Formats: map[string][]string{
+			"table": {},
+			"csv":   {"--csv"},
+		},
+	},
+	{
+		Name:        "pgcli",
+		Description: "Postgres REPL with auto-completion and syntax highlighting",
+		Command:     "pgcli",
+		Image:       sidecarPythonImage,
+		Install:     "pip install --quiet --disable-pip-version-check --root-user-action=ignore pgcli psycopg-binary",
+		Port:        "5432",
+		Args: func(conn Connection) []string {
+			return []string{"-h", conn.Host, "-p", conn.Port, "-U", conn.User, "-d", "postgres"}
+		},
+		Env: postgresClientEnv,
+	},
+}
+
+// postgresClientEnv passes the password and, for TCP connections to a
+// TLS-enabled instance, the certificates through libpq environment variables
+func postgresClientEnv(conn Connection) []string {
+	env := []string{"PGPASSWORD=" + conn.Password}
+	if conn.Host != "" && conn.TLS != nil {
+		env = append(env, "PGSSLMODE=verify-full", "PGSSLROOTCERT="+conn.TLS.CA)
+		if conn.TLS.Cert != "" {
+			env = append(env, "PGSSLCERT="+conn.TLS.Cert, "PGSSLKEY="+conn.TLS.Key)
+		}
+	}
+	return env
+}
+
+// postgresTLSCommand starts the server with TLS enabled. Postgres refuses a
+// key that is not owned by its user, so the mounted certificates are copied
+// and handed over to it before running the image entrypoint. Its own
+// pg_hba.conf only accepts TCP connections over TLS, with a client
+// certificate signed by the dbin CA when mtls is set.
+func postgresTLSCommand(mtls bool) []string {
+	clients := "hostssl all all all scram-sha-256"
+	if mtls {
+		// verify-ca, as the client certificate is not issued to a given user
+		clients += " clientcert=verify-ca"
+	}
+	hba := tlsServerPath + "/pg_hba.conf"
+	script := tlsCopyScript("postgres") +
+		" && printf '%s\\n' 'local all all trust' '" + clients + "'" +
+		" 'hostssl replication all all scram-sha-256' > " + hba +
+		" && chown postgres " + hba +
+		` && exec docker-entrypoint.sh "$@"`
+	return []string{"sh", "-c", script, "sh", "postgres",
+		"-c", "ssl=on",
+		"-c", "ssl_cert_file=" + tlsServerPath + "/server.crt",
+		"-c", "ssl_key_file=" + tlsServerPath + "/server.key",
+		"-c", "ssl_ca_file=" + tlsServerPath + "/ca.pem",
+		"-c", "hba_file=" + hba,
+	}
+}
+
+// postgresSSLParams returns the connection string parameters used by dbin to
+// check readiness
+func postgresSSLParams(files *TLSFiles) string {
+	if files == nil {
+		return "sslmode=disable"
+	}
+	params := "sslmode=verify-full sslrootcert=" + quoteConnParam(files.CA)
+	if files.Cert != "" {
+		params += " sslcert=" + quoteConnParam(files.Cert) + " sslkey=" + quoteConnParam(files.Key)
+	}
+	return params
+}
+
+func quoteConnParam(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// postgresMetricsQuery counts the client connections, but its own, against
+// the limit
+const postgresMetricsQuery = `SELECT count(*),
+  count(*) FILTER (WHERE state = 'active'),
+  count(*) FILTER (WHERE state LIKE 'idle in transaction%'),
+  current_setting('max_connections')
+FROM pg_stat_activity
+WHERE backend_type = 'client backend' AND pid <> pg_backend_pid()`
+
+var postgresMetrics = &EngineMetrics{
+	Command: func(conn Connection) []string {
+		return []string{"psql", "-U", conn.User, "-d", "postgres", "-XAt", "-c", postgresMetricsQuery}
+	},
+	Parse: func(output string) ([]Metric, error) {
+		fields := strings.Split(strings.TrimSpace(output), "|")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected pg_stat_activity output: %q", output)
+		}
+		return []Metric{
+			{Name: "connections", Value: fields[0] + " / " + fields[3]},
+			{Name: "active", Value: fields[1]},
+			{Name: "idle in transaction", Value: fields[2]},
+		}, nil
+	},
+}
+
+const postgresImage = "postgres:latest"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "postgres",
-		Description: "PostgreSQL database",
-		Manager:     NewPostgresManager,
+		Name:           "postgres",
+		Description:    "PostgreSQL database",
+		Category:       CategoryRelational,
+		Tags:           []string{"sql", "oltp"},
+		Manager:        NewPostgresManager,
+		Clients:        postgresClients,
+		Credentials:    postgresCredentials,
+		TLS:            true,
+		Protocol:       "postgres",
+		Nodes:          true,
+		Image:          postgresImage,
+		Port:           "5432/tcp",
+		DataPath:       "/var/lib/postgresql/data",
+		Memory:         "256 MB per node",
+		Readiness:      readinessSQL + ". With --nodes, waits for the standbys to stream from the primary",
+		Metrics:        postgresMetrics,
+		CredentialsEnv: []CredentialsEnv{{User: "POSTGRES_USER", Password: "POSTGRES_PASSWORD"}},
+		InitDir:        "/docker-entrypoint-initdb.d",
+		Scheme:         "postgres",
+		Docs:           "https://www.postgresql.org/docs/current/",
+		Tutorial:       "https://www.postgresql.org/docs/current/tutorial.html",
 	})
 }
 
@@ -22,8 +168,8 @@ type PostgresManager struct {
 	*BaseManager
 }
 
-func NewPostgresManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewPostgresManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -36,37 +182,128 @@ func NewPostgresManager(dataDir string, debug bool) DatabaseManager {
 func (pm *PostgresManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := pm.PullImageIfNeeded(ctx, "postgres:latest"); err != nil {
+	if err := pm.PullImageIfNeeded(ctx, postgresImage); err != nil {
+		return err
+	}
+
+	creds, err := pm.ResolveCredentials(postgresCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
-		"POSTGRES_PASSWORD=postgres",
-		"POSTGRES_USER=postgres",
+		"POSTGRES_PASSWORD=" + creds.Password,
+		"POSTGRES_USER=" + creds.User,
 		"POSTGRES_DB=postgres",
 	}
 
-	containerId, port, err := pm.CreateContainer(ctx, "postgres:latest", "dbin-postgres", "5432/tcp", env, "/var/lib/postgresql/data", nil)
+	tlsBind, err := pm.SetupTLS("dbin-postgres")
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image:      postgresImage,
+		Name:       "dbin-postgres",
+		Port:       "5432/tcp",
+		Env:        env,
+		VolumePath: "/var/lib/postgresql/data",
+	}
+	if tlsBind != "" {
+		spec.Binds = []string{tlsBind}
+		spec.Cmd = postgresTLSCommand(pm.opts.MTLS)
+	}
+
+	if pm.Clustered() {
+		return pm.startReplicas(ctx, spec)
+	}
+
+	containerId, port, err := pm.CreateContainerWithSpec(ctx, spec)
 	if err != nil {
 		return err
 	}
 	pm.dbContainerId = containerId
 	pm.dbPort = port
 
-	fmt.Println("Waiting for database to be ready...")
+	log.Println("Waiting for database to be ready...")
 	if err := pm.waitForDatabase(); err != nil {
 		return fmt.Errorf("database failed to start: %v", err)
 	}
 
-	fmt.Printf("Database is ready and listening on port %s\n", pm.dbPort)
+	log.Printf("Database is ready and listening on port %s", pm.dbPort)
+	return nil
+}
+
+// postgresReplicaCommand clones the primary with pg_basebackup on first start
+// and runs the copy as a hot standby streaming from it. PGHOST, PGUSER and
+// PGPASSWORD point it at the primary.
+func postgresReplicaCommand() []string {
+	script := `mkdir -p "$PGDATA" && chown postgres "$PGDATA" && chmod 700 "$PGDATA"` +
+		` && exec gosu postgres sh -c '` +
+		`if [ ! -s "$PGDATA/PG_VERSION" ]; then` +
+		` until pg_basebackup -D "$PGDATA" -R -X stream; do sleep 2; done;` +
+		` fi; exec postgres'`
+	return []string{"sh", "-c", script}
+}
+
+// startReplicas starts a primary and streams to the other nodes, which run
+// as read-only hot standbys
+func (pm *PostgresManager) startReplicas(ctx context.Context, spec ContainerSpec) error {
+	networkName, err := pm.CreateStackNetwork(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := pm.StartNode(ctx, spec, networkName, 1); err != nil {
+		return err
+	}
+	log.Println("Waiting for the primary to be ready...")
+	if err := pm.waitForDatabase(); err != nil {
+		return fmt.Errorf("primary failed to start: %v", err)
+	}
+
+	// The image only allows regular connections from the network. The data
+	// directory outlives the container, so only add the line once.
+	allowReplication := `line="host replication all all scram-sha-256"; grep -qxF "$line" "$PGDATA/pg_hba.conf" || echo "$line" >> "$PGDATA/pg_hba.conf"`
+	if out, err := exec.Command("docker", "exec", pm.dbContainerId, "sh", "-c", allowReplication).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to allow replication: %v: %s", err, out)
+	}
+	if out, err := exec.Command("docker", "exec", pm.dbContainerId, "psql", "-U", pm.creds.User, "-d", "postgres", "-c", "SELECT pg_reload_conf()").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to reload configuration: %v: %s", err, out)
+	}
+
+	replica := spec
+	replica.Env = []string{
+		"PGHOST=" + NodeContainer(pm.opts.Name, 1),
+		"PGUSER=" + pm.creds.User,
+		"PGPASSWORD=" + pm.creds.Password,
+	}
+	replica.Cmd = postgresReplicaCommand()
+	for i := 2; i <= pm.Nodes(); i++ {
+		if _, _, err := pm.StartNode(ctx, replica, networkName, i); err != nil {
+			return err
+		}
+	}
+
+	replicas := pm.Nodes() - 1
+	query := "SELECT count(*) FROM pg_stat_replication WHERE state = 'streaming'"
+	err = WaitForNode(pm.dbContainerId, fmt.Sprintf("%d replicas to stream", replicas), 60, func(output string) bool {
+		count, err := strconv.Atoi(strings.TrimSpace(output))
+		return err == nil && count >= replicas
+	}, "psql", "-U", pm.creds.User, "-d", "postgres", "-tAc", query)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Primary is ready and listening on port %s, with %d streaming replicas", pm.dbPort, replicas)
 	return nil
 }
 
 func (pm *PostgresManager) waitForDatabase() error {
-	connStr := fmt.Sprintf("host=localhost port=%s user=postgres password=postgres dbname=postgres sslmode=disable", pm.dbPort)
+	connStr := fmt.Sprintf("host=localhost port=%s user=%s password=%s dbname=postgres %s", pm.dbPort, quoteConnParam(pm.creds.User), quoteConnParam(pm.creds.Password), postgresSSLParams(pm.hostTLSFiles()))
 
 	for i := 0; i < 30; i++ {
-		fmt.Printf("Attempting database connection (attempt %d/30)...\n", i+1)
+		log.Printf("Attempting database connection (attempt %d/30)...", i+1)
 		db, err := sql.Open("postgres", connStr)
 		if err == nil {
 			err = db.Ping()
@@ -83,7 +320,7 @@ func (pm *PostgresManager) waitForDatabase() error {
 }
 
 func (pm *PostgresManager) StartClient() error {
-	return pm.StartContainerClient("psql", "-U", "postgres")
+	return pm.StartClientFrom(postgresClients)
 }
 
 func (pm *PostgresManager) Cleanup() error {
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// dockerMessage is a message of the JSON stream of an image pull
+type dockerMessage struct {
+	Status         string `json:"status"`
+	ID             string `json:"id"`
+	Progress       string `json:"progress"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error       string `json:"error"`
+	ErrorDetail struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// PlainProgress makes pulls log their progress instead of drawing it, for
+// callers that draw on the terminal themselves
+var PlainProgress bool
+
+// layerProgress is the state of one layer of a pull
+type layerProgress struct {
+	id         string
+	phase      string // Last status, e.g. Downloading or Extracting
+	downloaded int64
+	size       int64 // Compressed size, known once downloading starts
+	extracted  int64
+	done       bool
+}
+
+// pullRenderer shows the progress of a pull: a line per layer and an
+// overall bar on terminals, log lines of the phase changes otherwise
+type pullRenderer struct {
+	ref      string
+	out      *os.File
+	tty      bool
+	layers   []*layerProgress
+	byID     map[string]*layerProgress
+	lines    int // Lines drawn by the last render, to redraw over them
+	rendered time.Time
+}
+
+func newPullRenderer(ref string) *pullRenderer {
+	return &pullRenderer{
+		ref:  ref,
+		out:  os.Stdout,
+		tty:  !PlainProgress && term.IsTerminal(int(os.Stdout.Fd())),
+		byID: make(map[string]*layerProgress),
+	}
+}
+
+// renderPull reads the JSON stream of a pull until it ends, failing on the
+// errors it reports, such as denied access or unknown tags
+func renderPull(ref string, stream io.Reader) error {
+	r := newPullRenderer(ref)
+	decoder := json.NewDecoder(stream)
+	for {
+		var msg dockerMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("error decoding docker message: %v", err)
+		}
+		if msg.Error != "" || msg.ErrorDetail.Message != "" {
+			r.finish()
+			message := msg.ErrorDetail.Message
+			if message == "" {
+				message = msg.Error
+			}
+			return fmt.Errorf("failed to pull image: %s", message)
+		}
+		r.update(msg)
+	}
+	r.finish()
+	return nil
+}
+
+func (r *pullRenderer) update(msg dockerMessage) {
+	// Messages without a layer, such as the final digest and status
+	if msg.ID == "" || strings.HasPrefix(msg.Status, "Pulling from") {
+		if !r.tty && msg.Status != "" && !strings.HasPrefix(msg.Status, "Pulling from") {
+			log.Printf("%s: %s", r.ref, msg.Status)
+		}
+		return
+	}
+
+	layer, ok := r.byID[msg.ID]
+	if !ok {
+		layer = &layerProgress{id: msg.ID}
+		r.byID[msg.ID] = layer
+		r.layers = append(r.layers, layer)
+	}
+	changed := layer.phase != msg.Status
+	layer.phase = msg.Status
+
+	switch msg.Status {
+	case "Downloading":
+		layer.downloaded = msg.ProgressDetail.Current
+		if msg.ProgressDetail.Total > 0 {
+			layer.size = msg.ProgressDetail.Total
+		}
+	case "Verifying Checksum", "Download complete":
+		layer.downloaded = layer.size
+	case "Extracting":
+		layer.downloaded = layer.size
+		layer.extracted = msg.ProgressDetail.Current
+	case "Pull complete", "Already exists":
+		layer.downloaded, layer.extracted = layer.size, layer.size
+		layer.done = true
+	}
+
+	if r.tty {
+		if changed || time.Since(r.rendered) > 100*time.Millisecond {
+			r.render()
+		}
+		return
+	}
+	if changed {
+		switch msg.Status {
+		case "Downloading":
+			log.Printf("%s: layer %s: downloading %s", r.ref, shortID(layer.id), FormatSize(layer.size))
+		case "Extracting", "Pull complete", "Already exists":
+			log.Printf("%s: layer %s: %s (%s)", r.ref, shortID(layer.id), strings.ToLower(msg.Status), r.summary())
+		}
+	}
+}
+
+// summary returns the overall progress: bytes downloaded of the sizes known
+// so far, and layers done
+func (r *pullRenderer) summary() string {
+	var downloaded, size int64
+	done := 0
+	for _, l := range r.layers {
+		downloaded += l.downloaded
+		size += l.size
+		if l.done {
+			done++
+		}
+	}
+	return fmt.Sprintf("%s / %s, %d/%d layers", FormatSize(downloaded), FormatSize(size), done, len(r.layers))
+}
+
+func (r *pullRenderer) fraction() float64 {
+	var progress, total float64
+	for _, l := range r.layers {
+		if l.done {
+			progress, total = progress+1, total+1
+			continue
+		}
+		if l.size > 0 {
+			// Downloading and extracting count for half each
+			progress += (float64(l.downloaded) + float64(l.extracted)) / float64(2*l.size)
+		}
+		total++
+	}
+	if total == 0 {
+		return 0
+	}
+	return progress / total
+}
+
+func (r *pullRenderer) render() {
+	width := 80
+	if w, _, err := term.GetSize(int(r.out.Fd())); err == nil && w > 0 {
+		width = w
+	}
+
+	var lines []string
+	for _, l := range r.layers {
+		line := fmt.Sprintf("%s %-18s", shortID(l.id), l.phase)
+		switch {
+		case l.phase == "Downloading" && l.size > 0:
+			line += fmt.Sprintf(" %s %s / %s", bar(float64(l.downloaded)/float64(l.size), 20), FormatSize(l.downloaded), FormatSize(l.size))
+		case l.phase == "Extracting" && l.size > 0:
+			line += fmt.Sprintf(" %s %s / %s", bar(float64(l.extracted)/float64(l.size), 20), FormatSize(l.extracted), FormatSize(l.size))
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	fraction := r.fraction()
+	lines = append(lines, fmt.Sprintf("%s %s %5.1f%% %s", r.ref, bar(fraction, 30), fraction*100, r.summary()))
+
+	var b strings.Builder
+	for i := 0; i < r.lines; i++ {
+		b.WriteString("\033[1A\033[K") // Move up and clear line
+	}
+	for _, line := range lines {
+		// Wrapped lines would break the redraw
+		if len(line) >= width {
+			line = line[:width-1]
+		}
+		b.WriteString(line + "\n")
+	}
+	fmt.Fprint(r.out, b.String())
+	r.lines = len(lines)
+	r.rendered = time.Now()
+}
+
+func (r *pullRenderer) finish() {
+	if r.tty && len(r.layers) > 0 {
+		r.render()
+	}
+}
+
+func bar(fraction float64, width int) string {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction * float64(width))
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", width-filled) + "]"
+}
@@ -8,11 +8,36 @@ import (
 	"time"
 )
 
+var prometheusUIs = []WebUI{
+	{
+		Name:        "web",
+		Description: "Prometheus expression browser",
+		Container:   "dbin-prometheus",
+		Port:        "9090/tcp",
+	},
+}
+
+const prometheusImage = "prom/prometheus:latest"
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "prometheus",
 		Description: "Prometheus monitoring system",
+		Category:    CategoryTimeSeries,
+		Tags:        []string{"metrics", "promql", "monitoring"},
 		Manager:     NewPrometheusManager,
+		UIs:         prometheusUIs,
+		Image:       prometheusImage,
+		Port:        "9090/tcp",
+		DataPath:    "/prometheus",
+		Containers: []ContainerInfo{
+			{Name: "dbin-prometheus", Image: prometheusImage, Ports: []string{"9090/tcp"}, DataPath: "/prometheus", Role: "Monitoring server"},
+		},
+		Memory:    "256 MB",
+		Readiness: readinessWebUI,
+		Scheme:    "http",
+		Docs:      "https://prometheus.io/docs/",
+		Tutorial:  "https://prometheus.io/docs/prometheus/latest/getting_started/",
 	})
 }
 
@@ -20,8 +45,8 @@ type PrometheusManager struct {
 	*BaseManager
 }
 
-func NewPrometheusManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewPrometheusManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,11 +59,11 @@ func NewPrometheusManager(dataDir string, debug bool) DatabaseManager {
 func (pm *PrometheusManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := pm.PullImageIfNeeded(ctx, "prom/prometheus:latest"); err != nil {
+	if err := pm.PullImageIfNeeded(ctx, prometheusImage); err != nil {
 		return err
 	}
 
-	containerId, port, err := pm.CreateContainer(ctx, "prom/prometheus:latest", "prometheus-db", "9090/tcp", nil, "/prometheus", nil)
+	containerId, port, err := pm.CreateContainer(ctx, prometheusImage, "dbin-prometheus", "9090/tcp", nil, "/prometheus", nil)
 	if err != nil {
 		return err
 	}
@@ -50,7 +75,7 @@ func (pm *PrometheusManager) StartDatabase() error {
 }
 
 func (pm *PrometheusManager) StartClient() error {
-	return StartWebInterface(pm.dbPort)
+	return pm.OpenWebUI(prometheusUIs)
 }
 
 func (pm *PrometheusManager) Cleanup() error {
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"dbin/internal/proxy"
+	"dbin/internal/trace"
+)
+
+// Proxied is implemented by every manager through BaseManager. With --proxy
+// or --trace-queries, the main container of the database is created behind
+// the fault-injecting proxy, which takes the place of its published port.
+type Proxied interface {
+	Proxying() bool
+	StopProxy()
+}
+
+// proxyHost is how containers reach the proxy listening on the host
+const proxyHost = "host.docker.internal"
+
+// ProxyState records where the proxy of a running instance listens, so that
+// dbin proxy can find its control API
+type ProxyState struct {
+	Listen  string `json:"listen"`
+	Target  string `json:"target"`
+	Control string `json:"control"`
+}
+
+func proxyStatePath(instance string) (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "proxy", instance+".json"), nil
+}
+
+// LoadProxyState returns the proxy state of a running instance
+func LoadProxyState(instance string) (ProxyState, error) {
+	var state ProxyState
+	path, err := proxyStatePath(instance)
+	if err != nil {
+		return state, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, fmt.Errorf("%s is not running behind a proxy, start it with 'dbin %s --proxy'", instance, instance)
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read proxy state: %v", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return state, nil
+}
+
+func (bm *BaseManager) proxyEnabled() bool {
+	return bm.opts.Proxy || bm.opts.TraceQueries != ""
+}
+
+// Proxying reports whether the database runs behind the proxy
+func (bm *BaseManager) Proxying() bool {
+	return bm.proxy != nil
+}
+
+// startProxy starts the proxy in front of the database port, published on
+// the loopback interface only at target, and its control API, tracing
+// queries when --trace-queries is set. It returns the port of the proxy,
+// which is --port when set.
+func (bm *BaseManager) startProxy(target string) (string, error) {
+	p, err := proxy.Listen("0.0.0.0:"+bm.opts.Port, "127.0.0.1:"+target)
+	if err != nil {
+		return "", err
+	}
+	if bm.opts.TraceQueries != "" {
+		if err := bm.traceQueries(p); err != nil {
+			p.Close()
+			return "", err
+		}
+	}
+	control, err := p.ServeControl("127.0.0.1:0")
+	if err != nil {
+		p.Close()
+		return "", err
+	}
+
+	state := ProxyState{Listen: "127.0.0.1:" + p.Port(), Target: p.Target(), Control: control}
+	path, err := proxyStatePath(bm.opts.Name)
+	if err != nil {
+		p.Close()
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		p.Close()
+		return "", fmt.Errorf("failed to create state directory: %v", err)
+	}
+	data, _ := json.MarshalIndent(state, "", "  ")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		p.Close()
+		return "", fmt.Errorf("failed to write proxy state: %v", err)
+	}
+
+	bm.proxy = p
+	log.Printf("Proxy listening on %s, forwarding to %s", state.Listen, state.Target)
+	log.Printf("Proxy control API at %s/settings, or use 'dbin proxy %s set latency=200ms'", control, bm.opts.Name)
+	return p.Port(), nil
+}
+
+// StopProxy stops the proxy and forgets its state
+func (bm *BaseManager) StopProxy() {
+	if bm.proxy == nil {
+		return
+	}
+	bm.proxy.Close()
+	bm.proxy = nil
+	if bm.queryLog != nil {
+		bm.queryLog.Close()
+		bm.queryLog = nil
+	}
+	if path, err := proxyStatePath(bm.opts.Name); err == nil {
+		os.Remove(path)
+	}
+}
+
+// traceQueries makes the proxy decode the protocol of the database and log
+// every query to the destination given with --trace-queries
+func (bm *BaseManager) traceQueries(p *proxy.Proxy) error {
+	info, err := GetDatabaseInfo(bm.opts.Name)
+	if err != nil {
+		return err
+	}
+	if info.Protocol == "" {
+		return fmt.Errorf("%s does not support --trace-queries", bm.opts.Name)
+	}
+
+	queryLog, err := trace.NewLogger(info.Protocol, bm.opts.TraceQueries)
+	if err != nil {
+		return err
+	}
+	p.SetObserver(func() proxy.Observer {
+		return queryLog.NewDecoder()
+	})
+	bm.queryLog = queryLog
+
+	if bm.opts.TraceQueries == "-" {
+		log.Printf("Tracing %s queries through the proxy", info.Protocol)
+	} else {
+		log.Printf("Tracing %s queries through the proxy to %s", info.Protocol, bm.opts.TraceQueries)
+	}
+	return nil
+}
+
+// proxyPort returns the port of the proxy of a running instance, if it runs
+// behind one
+func proxyPort(instance string) (string, bool) {
+	state, err := LoadProxyState(instance)
+	if err != nil {
+		return "", false
+	}
+	_, port, err := net.SplitHostPort(state.Listen)
+	return port, err == nil
+}
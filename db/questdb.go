@@ -8,11 +8,31 @@ import (
 	"time"
 )
 
+var questdbUIs = []WebUI{
+	{
+		Name:        "console",
+		Description: "QuestDB web console",
+		Container:   "dbin-questdb",
+		Port:        "9000/tcp",
+	},
+}
+
+const questdbImage = "questdb/questdb:latest"
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "questdb",
 		Description: "QuestDB database",
+		Category:    CategoryTimeSeries,
+		Tags:        []string{"sql", "metrics"},
 		Manager:     NewQuestDBManager,
+		UIs:         questdbUIs,
+		Image:       questdbImage,
+		Port:        "9000/tcp",
+		DataPath:    "/root/.questdb",
+		Memory:      "1 GB",
+		Readiness:   readinessWebUI,
+		Docs:        "https://questdb.io/docs/",
 	})
 }
 
@@ -20,8 +40,8 @@ type QuestDBManager struct {
 	*BaseManager
 }
 
-func NewQuestDBManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewQuestDBManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,11 +54,11 @@ func NewQuestDBManager(dataDir string, debug bool) DatabaseManager {
 func (qm *QuestDBManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := qm.PullImageIfNeeded(ctx, "questdb/questdb:latest"); err != nil {
+	if err := qm.PullImageIfNeeded(ctx, questdbImage); err != nil {
 		return err
 	}
 
-	containerId, port, err := qm.CreateContainer(ctx, "questdb/questdb:latest", "dbin-questdb", "9000/tcp", nil, "/root/.questdb", nil)
+	containerId, port, err := qm.CreateContainer(ctx, questdbImage, "dbin-questdb", "9000/tcp", nil, "/root/.questdb", nil)
 	if err != nil {
 		return err
 	}
@@ -50,7 +70,7 @@ func (qm *QuestDBManager) StartDatabase() error {
 }
 
 func (qm *QuestDBManager) StartClient() error {
-	return StartWebInterface(qm.dbPort)
+	return qm.OpenWebUI(questdbUIs)
 }
 
 func (qm *QuestDBManager) Cleanup() error {
@@ -5,14 +5,151 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os/exec"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var redisClients = []Client{
+	{
+		Name:        "redis-cli",
+		Description: "Redis command-line interface",
+		Command:     "redis-cli",
+		Args: func(conn Connection) []string {
+			var args []string
+			if conn.Host != "" {
+				args = append(args, "-h", conn.Host, "-p", conn.Port)
+			}
+			if conn.Cluster {
+				// Follow MOVED redirections to the node owning the slot
+				args = append(args, "-c")
+			}
+			return append(args, redisClientTLSArgs(conn)...)
+		},
+		Formats: map[string][]string{
+			"csv":  {"--csv"},
+			"json": {"--json"},
+		},
+	},
+	iredisClient,
+}
+
+// redisTLSCommand runs a Redis-compatible server accepting TLS connections
+// only, requiring client certificates when mtls is set
+func redisTLSCommand(server, port string, mtls bool) []string {
+	authClients := "no"
+	if mtls {
+		authClients = "yes"
+	}
+	// The image runs server as the user of the same name
+	return tlsServerCommand(strings.TrimSuffix(server, "-server"),
+		server,
+		"--port", "0",
+		"--tls-port", port,
+		"--tls-cert-file", tlsServerPath+"/server.crt",
+		"--tls-key-file", tlsServerPath+"/server.key",
+		"--tls-ca-cert-file", tlsServerPath+"/ca.pem",
+		"--tls-auth-clients", authClients,
+	)
+}
+
+func redisClientTLSArgs(conn Connection) []string {
+	if conn.TLS == nil {
+		return nil
+	}
+	args := []string{"--tls", "--cacert", conn.TLS.CA}
+	if conn.TLS.Cert != "" {
+		args = append(args, "--cert", conn.TLS.Cert, "--key", conn.TLS.Key)
+	}
+	return args
+}
+
+// iredisClient is shared by the Redis-compatible databases
+var iredisClient = Client{
+	Name:        "iredis",
+	Description: "Redis REPL with auto-completion and syntax highlighting",
+	Command:     "iredis",
+	Image:       sidecarPythonImage,
+	Install:     "pip install --quiet --disable-pip-version-check --root-user-action=ignore iredis",
+	Port:        "6379",
+	NoTLS:       true, // iredis has no options for a CA or client certificates
+	Args: func(conn Connection) []string {
+		return []string{"-h", conn.Host, "-p", conn.Port}
+	},
+}
+
+var redisMetrics = &EngineMetrics{
+	Command: func(conn Connection) []string {
+		args := append([]string{"redis-cli"}, redisClientTLSArgs(conn)...)
+		return append(args, "INFO")
+	},
+	Parse: parseRedisInfo,
+}
+
+// parseRedisInfo picks the clients, memory, throughput, hit rate and keys
+// from the output of INFO
+func parseRedisInfo(output string) ([]Metric, error) {
+	values := make(map[string]string)
+	keys := 0
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		values[key] = value
+		// Keyspace lines look like db0:keys=12,expires=0,avg_ttl=0
+		if strings.HasPrefix(key, "db") {
+			count, _, _ := strings.Cut(strings.TrimPrefix(value, "keys="), ",")
+			n, _ := strconv.Atoi(count)
+			keys += n
+		}
+	}
+	if values["connected_clients"] == "" {
+		return nil, fmt.Errorf("unexpected INFO output")
+	}
+
+	memory := values["used_memory_human"]
+	if values["maxmemory"] != "" && values["maxmemory"] != "0" {
+		memory += " / " + values["maxmemory_human"]
+	}
+	hitRate := "-"
+	hits, _ := strconv.ParseFloat(values["keyspace_hits"], 64)
+	misses, _ := strconv.ParseFloat(values["keyspace_misses"], 64)
+	if hits+misses > 0 {
+		hitRate = fmt.Sprintf("%.1f%%", hits/(hits+misses)*100)
+	}
+	return []Metric{
+		{Name: "clients", Value: values["connected_clients"]},
+		{Name: "memory", Value: memory},
+		{Name: "ops/s", Value: values["instantaneous_ops_per_sec"]},
+		{Name: "hit rate", Value: hitRate},
+		{Name: "keys", Value: strconv.Itoa(keys)},
+	}, nil
+}
+
+const redisImage = "redis:latest"
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "redis",
 		Description: "Redis database",
+		Category:    CategoryKeyValue,
+		Tags:        []string{"cache", "in-memory", "pubsub"},
 		Manager:     NewRedisManager,
+		Clients:     redisClients,
+		TLS:         true,
+		Protocol:    "redis",
+		Nodes:       true,
+		Image:       redisImage,
+		Port:        "6379/tcp",
+		DataPath:    "/data",
+		Memory:      "64 MB per node",
+		Readiness:   readinessClient + ". With --nodes, pings every node and waits for the cluster to be ready",
+		Metrics:     redisMetrics,
+		Scheme:      "redis",
+		Docs:        "https://redis.io/docs/",
+		Tutorial:    "https://redis.io/docs/latest/develop/get-started/",
 	})
 }
 
@@ -20,8 +157,8 @@ type RedisManager struct {
 	*BaseManager
 }
 
-func NewRedisManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewRedisManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,11 +171,31 @@ func NewRedisManager(dataDir string, debug bool) DatabaseManager {
 func (rm *RedisManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := rm.PullImageIfNeeded(ctx, "redis:latest"); err != nil {
+	if err := rm.PullImageIfNeeded(ctx, redisImage); err != nil {
 		return err
 	}
 
-	containerId, port, err := rm.CreateContainer(ctx, "redis:latest", "dbin-redis", "6379/tcp", nil, "/data", nil)
+	tlsBind, err := rm.SetupTLS("dbin-redis")
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image:      redisImage,
+		Name:       "dbin-redis",
+		Port:       "6379/tcp",
+		VolumePath: "/data",
+	}
+	if tlsBind != "" {
+		spec.Binds = []string{tlsBind}
+		spec.Cmd = redisTLSCommand("redis-server", "6379", rm.opts.MTLS)
+	}
+
+	if rm.Clustered() {
+		return rm.startCluster(ctx, spec)
+	}
+
+	containerId, port, err := rm.CreateContainerWithSpec(ctx, spec)
 	if err != nil {
 		return err
 	}
@@ -49,8 +206,68 @@ func (rm *RedisManager) StartDatabase() error {
 	return nil
 }
 
+// startCluster starts a Redis Cluster, with one replica per master when
+// there are enough nodes
+func (rm *RedisManager) startCluster(ctx context.Context, spec ContainerSpec) error {
+	if rm.Nodes() < 3 {
+		return fmt.Errorf("redis cluster needs at least 3 nodes")
+	}
+
+	networkName, err := rm.CreateStackNetwork(ctx)
+	if err != nil {
+		return err
+	}
+
+	spec.Cmd = []string{
+		"redis-server",
+		"--cluster-enabled", "yes",
+		"--cluster-config-file", "nodes.conf",
+		"--cluster-node-timeout", "5000",
+		"--appendonly", "yes",
+	}
+
+	// redis-cli --cluster only accepts IP addresses
+	var addresses []string
+	for i, name := range rm.nodeNames() {
+		containerId, _, err := rm.StartNode(ctx, spec, networkName, i+1)
+		if err != nil {
+			return err
+		}
+		if err := WaitForNode(containerId, name, 15, nil, "redis-cli", "ping"); err != nil {
+			return err
+		}
+		ip, err := rm.nodeAddress(ctx, containerId, networkName)
+		if err != nil {
+			return err
+		}
+		addresses = append(addresses, ip+":6379")
+	}
+
+	replicas := "0"
+	if rm.Nodes() >= 6 {
+		replicas = "1"
+	}
+
+	log.Println("Allocating hash slots...")
+	args := append([]string{"exec", rm.dbContainerId, "redis-cli", "--cluster", "create"}, addresses...)
+	args = append(args, "--cluster-replicas", replicas, "--cluster-yes")
+	if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create cluster: %v: %s", err, out)
+	}
+
+	err = WaitForNode(rm.dbContainerId, "the cluster to be ready", 30, func(output string) bool {
+		return strings.Contains(output, "cluster_state:ok")
+	}, "redis-cli", "cluster", "info")
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Redis cluster is ready with %d nodes, the first node listens on port %s\n", rm.Nodes(), rm.dbPort)
+	return nil
+}
+
 func (rm *RedisManager) StartClient() error {
-	return rm.StartContainerClient("redis-cli")
+	return rm.StartClientFrom(redisClients)
 }
 
 func (rm *RedisManager) Cleanup() error {
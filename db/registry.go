@@ -3,14 +3,125 @@ package db
 import "fmt"
 
 type DatabaseInfo struct {
-	Name        string
-	Description string
-	Manager     func(string, bool) DatabaseManager
+	Name           string
+	Description    string
+	Category       string   // Paradigm the database is listed under
+	Tags           []string // Keywords matched by dbin list --search
+	Manager        func(Options) DatabaseManager
+	UIs            []WebUI
+	Clients        []Client
+	Credentials    Credentials       // Default credentials
+	TLS            bool              // Supports --tls
+	Auth           bool              // Supports --auth, security is disabled otherwise
+	Nodes          bool              // Supports --nodes
+	AuthNodes      bool              // Supports --auth together with --nodes
+	Protocol       string            // Wire protocol decoded by --trace-queries, if any
+	Image          string            // Main image, whose tag --version replaces
+	ArchImages     map[string]string // Images to run instead of Image on architectures it lacks, e.g. arm64
+	Port           string            // Container port of the main container, e.g. 5432/tcp
+	DataPath       string            // Directory of the main container --data-dir is mounted on
+	Containers     []ContainerInfo   // Containers started, in order, when not just the main one
+	Memory         string            // Memory the database needs, roughly
+	Readiness      string            // How dbin waits for the database to be ready
+	Metrics        *EngineMetrics    // Engine-level figures shown by dbin stats, if cheap to read
+	CredentialsEnv []CredentialsEnv  // Variables of the image setting the credentials, read by dbin import
+	Companions     []string          // Repositories of images versioned along with Image, like Kibana
+	InitDir        string            // Directory the image runs init scripts from, for --init
+	Scheme         string            // URL scheme of the main port, for the .env written by dbin up
+	Docs           string            // Documentation URL
+	Tutorial       string            // Getting started guide URL, if any
+}
+
+// ContainerInfo describes one of the containers a database runs
+type ContainerInfo struct {
+	Name     string   `json:"name"`
+	Image    string   `json:"image"`
+	Ports    []string `json:"ports"`
+	DataPath string   `json:"data_path,omitempty"`
+	Role     string   `json:"role,omitempty"`
+}
+
+// Readiness strategies shared by several databases
+const (
+	readinessSQL    = "Connects with the Go driver, up to 30 attempts 1 second apart"
+	readinessClient = "None, the client retries up to 5 times 5 seconds apart while the database starts"
+	readinessWebUI  = "Polls the web interface up to 5 times 5 seconds apart"
+)
+
+// AllContainers returns the containers the database runs, in start order
+func (info DatabaseInfo) AllContainers() []ContainerInfo {
+	if len(info.Containers) > 0 {
+		return info.Containers
+	}
+	main := ContainerInfo{
+		Name:     InstanceContainer(info.Name),
+		Image:    info.Image,
+		DataPath: info.DataPath,
+		Role:     "Database server",
+	}
+	if info.Port != "" {
+		main.Ports = append(main.Ports, info.Port)
+	}
+	for _, ui := range info.UIs {
+		if ui.Container == main.Name && !contains(main.Ports, ui.Port) {
+			main.Ports = append(main.Ports, ui.Port)
+		}
+	}
+	return []ContainerInfo{main}
+}
+
+// Categories of databases, in the order dbin list shows them
+const (
+	CategoryRelational = "relational"
+	CategoryDocument   = "document"
+	CategoryGraph      = "graph"
+	CategorySearch     = "search"
+	CategoryWideColumn = "wide-column"
+	CategoryTimeSeries = "time-series"
+	CategorySpatial    = "spatial"
+	CategoryKeyValue   = "key-value"
+	CategoryVector     = "vector"
+	CategoryMultiModel = "multi-model"
+)
+
+var Categories = []string{
+	CategoryRelational, CategoryDocument, CategoryGraph, CategorySearch, CategoryWideColumn,
+	CategoryTimeSeries, CategorySpatial, CategoryKeyValue, CategoryVector, CategoryMultiModel,
+}
+
+var categoryTitles = map[string]string{
+	CategoryRelational: "Relational (SQL)",
+	CategoryDocument:   "Document (NoSQL)",
+	CategoryGraph:      "Graph",
+	CategorySearch:     "Search Engine",
+	CategoryWideColumn: "Wide Column",
+	CategoryTimeSeries: "Time Series",
+	CategorySpatial:    "Spatial",
+	CategoryKeyValue:   "Key-Value",
+	CategoryVector:     "Vector",
+	CategoryMultiModel: "Multi-Model",
+}
+
+// CategoryTitle returns the heading of a category
+func CategoryTitle(category string) string {
+	if title, ok := categoryTitles[category]; ok {
+		return title
+	}
+	return category
 }
 
 var registry = make(map[string]DatabaseInfo)
 
 func Register(info DatabaseInfo) {
+	// --port, --init, dbin status and the others find the main container by
+	// its name
+	main := len(info.Containers) == 0
+	for _, c := range info.Containers {
+		main = main || c.Name == InstanceContainer(info.Name)
+	}
+	if !main {
+		panic(fmt.Sprintf("%s has no container named %s", info.Name, InstanceContainer(info.Name)))
+	}
 	registry[info.Name] = info
 }
 
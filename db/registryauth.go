@@ -0,0 +1,234 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	registrytypes "github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+)
+
+// dockerHubAuthKey is the key of Docker Hub in the Docker config file
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// registryConfig is dbin/registries.yaml under the user configuration
+// directory. Mirrors rewrite image prefixes, e.g. docker.io to pull every
+// Docker Hub image from a mirror, or docker.io/library/postgres to move
+// only that repository:
+//
+//	mirrors:
+//	  docker.io: mirror.corp
+//	  docker.io/bitnami: registry.corp/hub/bitnami
+type registryConfig struct {
+	Mirrors map[string]string `yaml:"mirrors"`
+}
+
+func loadRegistryConfig() (*registryConfig, error) {
+	config := &registryConfig{}
+	dir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "registries.yaml")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read registry configuration: %v", err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+	}
+
+	// DBIN_REGISTRY_MIRROR is a shorthand for a Docker Hub mirror
+	if mirror := os.Getenv("DBIN_REGISTRY_MIRROR"); mirror != "" {
+		if config.Mirrors == nil {
+			config.Mirrors = make(map[string]string)
+		}
+		config.Mirrors["docker.io"] = strings.TrimSuffix(mirror, "/")
+	}
+	return config, nil
+}
+
+// rewrite returns the reference to pull ref from, applying the longest
+// mirror prefix that matches it. It is safe to call on a nil config.
+func (c *registryConfig) rewrite(ref string) string {
+	if c == nil || len(c.Mirrors) == 0 {
+		return ref
+	}
+	full := fullImage(ref)
+	prefixes := make([]string, 0, len(c.Mirrors))
+	for prefix := range c.Mirrors {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+	for _, prefix := range prefixes {
+		rest, ok := strings.CutPrefix(full, strings.TrimSuffix(prefix, "/"))
+		if ok && (rest == "" || strings.ContainsAny(rest[:1], "/:@")) {
+			return strings.TrimSuffix(c.Mirrors[prefix], "/") + rest
+		}
+	}
+	return ref
+}
+
+// fullImage returns ref with its registry and, on Docker Hub, the library
+// namespace of official images, e.g. docker.io/library/postgres:16
+func fullImage(ref string) string {
+	first, _, found := strings.Cut(ref, "/")
+	switch {
+	case !found:
+		return "docker.io/library/" + ref
+	case strings.ContainsAny(first, ".:") || first == "localhost":
+		return ref
+	}
+	return "docker.io/" + ref
+}
+
+// registryHost returns the registry an image is pulled from
+func registryHost(ref string) string {
+	host, _, _ := strings.Cut(fullImage(ref), "/")
+	return host
+}
+
+// dockerConfig is the part of ~/.docker/config.json holding credentials
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// registryAuth returns the credentials docker login stored for the registry
+// of ref, encoded for the X-Registry-Auth header, or an empty string to pull
+// anonymously
+func registryAuth(ref string) (string, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read Docker config: %v", err)
+	}
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to parse Docker config: %v", err)
+	}
+
+	host := registryHost(ref)
+	keys := []string{host, "https://" + host, "http://" + host}
+	if host == "docker.io" {
+		keys = []string{dockerHubAuthKey, "index.docker.io", "docker.io", "registry-1.docker.io"}
+	}
+
+	auth := registrytypes.AuthConfig{ServerAddress: keys[0]}
+	helper := config.CredsStore
+	for _, key := range keys {
+		if h, ok := config.CredHelpers[key]; ok {
+			helper = h
+			break
+		}
+	}
+	found := false
+	if helper != "" {
+		if found, err = helperCredentials(helper, keys[0], &auth); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	for _, key := range keys {
+		entry, ok := config.Auths[key]
+		if found || !ok {
+			continue
+		}
+		if entry.IdentityToken != "" {
+			auth.IdentityToken = entry.IdentityToken
+			found = true
+		}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return "", fmt.Errorf("invalid credentials for %s in Docker config: %v", key, err)
+			}
+			auth.Username, auth.Password, _ = strings.Cut(string(decoded), ":")
+			found = true
+		}
+	}
+	if !found {
+		return "", nil
+	}
+	return registrytypes.EncodeAuthConfig(auth)
+}
+
+// helperCredentials asks a Docker credential helper, such as osxkeychain or
+// ecr-login, for the credentials of a registry
+func helperCredentials(helper, serverURL string, auth *registrytypes.AuthConfig) (bool, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// Helpers fail when they have nothing for the registry
+		if strings.Contains(string(out)+stderr.String(), "credentials not found") {
+			return false, nil
+		}
+		return false, fmt.Errorf("credential helper %s failed: %v", helper, err)
+	}
+	var creds struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return false, fmt.Errorf("failed to parse the output of credential helper %s: %v", helper, err)
+	}
+	if creds.Username == "<token>" {
+		auth.IdentityToken = creds.Secret
+	} else {
+		auth.Username, auth.Password = creds.Username, creds.Secret
+	}
+	return true, nil
+}
+
+// inspectDistribution reads the manifest of ref from its registry, or its
+// mirror, without pulling it
+func inspectDistribution(ctx context.Context, cli *client.Client, ref string) (registrytypes.DistributionInspect, error) {
+	config, err := loadRegistryConfig()
+	if err != nil {
+		return registrytypes.DistributionInspect{}, err
+	}
+	source := config.rewrite(ref)
+	auth, err := registryAuth(source)
+	if err != nil {
+		return registrytypes.DistributionInspect{}, err
+	}
+	return cli.DistributionInspect(ctx, source, auth)
+}
+
+// isRateLimited tells whether a pull failed because the registry answered
+// 429 Too Many Requests, as Docker Hub does past its pull limit
+func isRateLimited(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "toomanyrequests") || strings.Contains(message, "too many requests") ||
+		strings.Contains(message, "rate limit")
+}
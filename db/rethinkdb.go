@@ -8,11 +8,32 @@ import (
 	"time"
 )
 
+var rethinkdbUIs = []WebUI{
+	{
+		Name:        "web",
+		Description: "RethinkDB administration console",
+		Container:   "dbin-rethinkdb",
+		Port:        "8080/tcp",
+	},
+}
+
+const rethinkdbImage = "rethinkdb:latest"
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "rethinkdb",
 		Description: "RethinkDB database",
+		Category:    CategoryMultiModel,
+		Tags:        []string{"document", "realtime", "reql"},
 		Manager:     NewRethinkDBManager,
+		UIs:         rethinkdbUIs,
+		Image:       rethinkdbImage,
+		Port:        "8080/tcp",
+		DataPath:    "/data",
+		Memory:      "256 MB",
+		Readiness:   readinessWebUI,
+		Docs:        "https://rethinkdb.com/docs/",
+		Tutorial:    "https://rethinkdb.com/docs/guide/javascript/",
 	})
 }
 
@@ -20,8 +41,8 @@ type RethinkDBManager struct {
 	*BaseManager
 }
 
-func NewRethinkDBManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewRethinkDBManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,11 +55,11 @@ func NewRethinkDBManager(dataDir string, debug bool) DatabaseManager {
 func (rm *RethinkDBManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := rm.PullImageIfNeeded(ctx, "rethinkdb:latest"); err != nil {
+	if err := rm.PullImageIfNeeded(ctx, rethinkdbImage); err != nil {
 		return err
 	}
 
-	containerId, port, err := rm.CreateContainer(ctx, "rethinkdb:latest", "dbin-rethinkdb", "8080/tcp", nil, "/data", nil)
+	containerId, port, err := rm.CreateContainer(ctx, rethinkdbImage, "dbin-rethinkdb", "8080/tcp", nil, "/data", nil)
 	if err != nil {
 		return err
 	}
@@ -50,7 +71,7 @@ func (rm *RethinkDBManager) StartDatabase() error {
 }
 
 func (rm *RethinkDBManager) StartClient() error {
-	return StartWebInterface(rm.dbPort)
+	return rm.OpenWebUI(rethinkdbUIs)
 }
 
 func (rm *RethinkDBManager) Cleanup() error {
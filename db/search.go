@@ -0,0 +1,138 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// searchAPI talks to the REST API of an Elasticsearch or OpenSearch node to
+// bootstrap users and check the cluster health
+type searchAPI struct {
+	url        string
+	creds      Credentials
+	httpClient *http.Client
+}
+
+// newSearchAPI returns a client for the node published on port. Without
+// --tls, insecure accepts the demo certificates some images serve.
+func (bm *BaseManager) newSearchAPI(port string, https bool, insecure bool) (*searchAPI, error) {
+	scheme := "http"
+	transport := &http.Transport{}
+	if https {
+		scheme = "https"
+		if bm.TLSEnabled() {
+			config, err := bm.clientTLSConfig()
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = config
+		} else if insecure {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+	}
+
+	return &searchAPI{
+		url:   fmt.Sprintf("%s://localhost:%s", scheme, port),
+		creds: bm.creds,
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+		},
+	}, nil
+}
+
+func (api *searchAPI) request(method, path string, body interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, api.url+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth(api.creds.User, api.creds.Password)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := api.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// do runs a request that must succeed
+func (api *searchAPI) do(method, path string, body interface{}) error {
+	status, err := api.request(method, path, body)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %v", method, path, err)
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("%s %s returned status %d", method, path, status)
+	}
+	return nil
+}
+
+// waitUntilReady polls path until it answers 200 with the credentials
+func (api *searchAPI) waitUntilReady(path string, attempts int) error {
+	for i := 0; i < attempts; i++ {
+		status, err := api.request(http.MethodGet, path, nil)
+		if err == nil && status == http.StatusOK {
+			return nil
+		}
+		if err != nil {
+			log.Printf("Waiting for %s (attempt %d/%d): %v", path, i+1, attempts, err)
+		} else {
+			log.Printf("Waiting for %s (attempt %d/%d): status %d", path, i+1, attempts, status)
+		}
+		time.Sleep(5 * time.Second)
+	}
+	return fmt.Errorf("%s not ready after %d attempts", path, attempts)
+}
+
+// waitForNodes waits until nodes have joined the cluster
+func (api *searchAPI) waitForNodes(nodes int) error {
+	log.Printf("Waiting for %d nodes to join the cluster...", nodes)
+	return api.waitUntilReady(fmt.Sprintf("/_cluster/health?wait_for_nodes=%d&timeout=5s", nodes), 36)
+}
+
+// startSearchCluster starts the nodes of an Elasticsearch or OpenSearch
+// cluster, replacing single-node discovery. bootstrapSetting lists the
+// initial master nodes, OpenSearch renamed it.
+func (bm *BaseManager) startSearchCluster(ctx context.Context, spec ContainerSpec, networkName string, bootstrapSetting string) error {
+	names := bm.nodeNames()
+	var env []string
+	for _, e := range spec.Env {
+		if e != "discovery.type=single-node" {
+			env = append(env, e)
+		}
+	}
+	env = append(env,
+		"cluster.name=dbin",
+		"discovery.seed_hosts="+strings.Join(names, ","),
+		bootstrapSetting+"="+strings.Join(names, ","),
+	)
+
+	for i, name := range names {
+		node := spec
+		node.Env = append(append([]string{}, env...), "node.name="+name)
+		if _, _, err := bm.StartNode(ctx, node, networkName, i+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,549 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+)
+
+// StackFile is the file dbin up, down and status read by default
+const StackFile = "dbin.yaml"
+
+// Stack is a set of databases described by a dbin.yaml file:
+//
+//	env_file: .env
+//	databases:
+//	  postgres:
+//	    version: "16"
+//	    port: 5432
+//	    password: secret
+//	    init: ./sql
+//	    data: ./data/postgres
+//	  redis: {}
+type Stack struct {
+	Path      string
+	EnvFile   string
+	Databases []StackDatabase
+}
+
+// StackDatabase configures one database of a stack. Names are the ones of
+// the registry, as used by dbin <database>.
+type StackDatabase struct {
+	Name              string            `yaml:"-"`
+	Version           string            `yaml:"version,omitempty"`
+	Port              string            `yaml:"port,omitempty"`
+	User              string            `yaml:"user,omitempty"`
+	Password          string            `yaml:"password,omitempty"`
+	RandomCredentials bool              `yaml:"random_credentials,omitempty"`
+	Init              string            `yaml:"init,omitempty"`
+	Data              string            `yaml:"data,omitempty"`
+	Nodes             int               `yaml:"nodes,omitempty"`
+	Auth              bool              `yaml:"auth,omitempty"`
+	TLS               bool              `yaml:"tls,omitempty"`
+	Platform          string            `yaml:"platform,omitempty"`
+	Env               map[string]string `yaml:"env,omitempty"`     // Extra variables of the main container
+	Volumes           []string          `yaml:"volumes,omitempty"` // Extra mounts, host paths or named volumes
+}
+
+// stackKeys lists the settings accepted for a database, to catch typos
+var stackKeys = []string{"version", "port", "user", "password", "random_credentials", "init", "data", "nodes", "auth", "tls", "platform", "env", "volumes"}
+
+// LoadStack reads a stack file
+func LoadStack(path string) (*Stack, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s not found, create it to list the databases of the project", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stack file: %v", err)
+	}
+	defer file.Close()
+
+	var raw struct {
+		EnvFile   string    `yaml:"env_file"`
+		Databases yaml.Node `yaml:"databases"`
+	}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&raw); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	stack := &Stack{Path: path, EnvFile: raw.EnvFile}
+	if stack.EnvFile == "" {
+		stack.EnvFile = ".env"
+	}
+	if raw.Databases.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: databases must map database names to their settings", path)
+	}
+
+	// Decode the mapping by hand to keep the order of the file
+	content := raw.Databases.Content
+	for i := 0; i+1 < len(content); i += 2 {
+		name, value := content[i].Value, content[i+1]
+		if _, err := GetDatabaseInfo(name); err != nil {
+			return nil, fmt.Errorf("%s:%d: unknown database %q, see 'dbin list'", path, content[i].Line, name)
+		}
+		if value.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				key := value.Content[j]
+				if !contains(stackKeys, key.Value) {
+					return nil, fmt.Errorf("%s:%d: unknown setting %q for %s (available: %s)", path, key.Line, key.Value, name, strings.Join(stackKeys, ", "))
+				}
+			}
+		}
+
+		d := StackDatabase{Name: name}
+		if err := value.Decode(&d); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid settings for %s: %v", path, value.Line, name, err)
+		}
+		d.Name = name
+		stack.Databases = append(stack.Databases, d)
+	}
+	if len(stack.Databases) == 0 {
+		return nil, fmt.Errorf("%s lists no databases", path)
+	}
+	return stack, nil
+}
+
+// Database returns the settings of a database of the stack
+func (s *Stack) Database(name string) (StackDatabase, bool) {
+	for _, d := range s.Databases {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return StackDatabase{}, false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns a path of the stack file relative to its directory
+func (s *Stack) resolve(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(s.Path), path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	return abs, nil
+}
+
+// Options validates the settings of a database like the flags of
+// dbin <database> are, and turns them into manager options
+func (s *Stack) Options(d StackDatabase) (Options, error) {
+	info, err := GetDatabaseInfo(d.Name)
+	if err != nil {
+		return Options{}, err
+	}
+
+	invalid := func(setting string) error {
+		return fmt.Errorf("%s: %s does not support %s", s.Path, d.Name, setting)
+	}
+	switch {
+	case d.Nodes > 1 && !info.Nodes:
+		return Options{}, invalid("nodes")
+	case d.Auth && !info.Auth:
+		return Options{}, invalid("auth")
+	case d.TLS && !info.TLS:
+		return Options{}, invalid("tls")
+	case d.Init != "" && info.InitDir == "":
+		return Options{}, invalid("init")
+	case (d.User != "" || d.Password != "" || d.RandomCredentials) && info.Credentials.IsZero():
+		return Options{}, invalid("credentials")
+	case info.Auth && !d.Auth && (d.User != "" || d.Password != "" || d.RandomCredentials):
+		return Options{}, fmt.Errorf("%s: user, password and random_credentials of %s require auth", s.Path, d.Name)
+	case d.Password != "" && d.RandomCredentials:
+		return Options{}, fmt.Errorf("%s: password and random_credentials of %s are mutually exclusive", s.Path, d.Name)
+	case d.Nodes > 1 && d.TLS:
+		return Options{}, fmt.Errorf("%s: tls is not supported with nodes for %s", s.Path, d.Name)
+	case d.Nodes > 1 && d.Auth && !info.AuthNodes:
+		return Options{}, fmt.Errorf("%s: auth is not supported with nodes for %s", s.Path, d.Name)
+	}
+	if d.Platform != "" {
+		if _, err := ParsePlatform(d.Platform); err != nil {
+			return Options{}, fmt.Errorf("%s: %v", s.Path, err)
+		}
+	}
+
+	opts := Options{
+		Name:              d.Name,
+		NoBrowser:         true,
+		ClientMode:        ClientModeContainer,
+		User:              d.User,
+		Password:          d.Password,
+		RandomCredentials: d.RandomCredentials,
+		TLS:               d.TLS,
+		Auth:              d.Auth,
+		Nodes:             d.Nodes,
+		Version:           d.Version,
+		Port:              d.Port,
+		LockFile:          LockFor(s.Path),
+		Platform:          d.Platform,
+	}
+	if d.Data != "" {
+		if opts.DataDir, err = s.resolve(d.Data); err != nil {
+			return Options{}, err
+		}
+	}
+	if d.Init != "" {
+		if opts.Init, err = s.resolve(d.Init); err != nil {
+			return Options{}, err
+		}
+	}
+	for key, value := range d.Env {
+		opts.Env = append(opts.Env, key+"="+value)
+	}
+	sort.Strings(opts.Env)
+	for _, volume := range d.Volumes {
+		source, target, ok := strings.Cut(volume, ":")
+		if !ok || source == "" || target == "" {
+			return Options{}, fmt.Errorf("%s: invalid volume %q of %s, expected source:target[:mode]", s.Path, volume, d.Name)
+		}
+		// Sources with a slash are host paths, others are named volumes
+		if strings.ContainsRune(source, '/') || strings.HasPrefix(source, ".") {
+			if source, err = s.resolve(source); err != nil {
+				return Options{}, err
+			}
+		}
+		opts.Volumes = append(opts.Volumes, source+":"+target)
+	}
+	return opts, nil
+}
+
+// Up starts the databases of the stack that are not running yet, in the
+// order of the file, and writes the env file
+func (s *Stack) Up() error {
+	// Validate everything before starting anything
+	var allOpts []Options
+	for _, d := range s.Databases {
+		opts, err := s.Options(d)
+		if err != nil {
+			return err
+		}
+		allOpts = append(allOpts, opts)
+	}
+	if _, err := s.envPath(); err != nil {
+		return err
+	}
+
+	cli, err := NewDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	for i, d := range s.Databases {
+		containers, err := instanceContainers(ctx, cli, d.Name)
+		if err != nil {
+			return err
+		}
+		if len(containers) > 0 && allRunning(containers) {
+			log.Printf("%s is already up", d.Name)
+			continue
+		}
+		if len(containers) > 0 {
+			// Leftovers of a stopped instance would clash with the new one
+			if err := RemoveInstance(ctx, cli, d.Name); err != nil {
+				return err
+			}
+		}
+
+		opts := allOpts[i]
+		if opts.DataDir != "" {
+			if err := os.MkdirAll(opts.DataDir, 0755); err != nil {
+				return fmt.Errorf("failed to create data directory: %v", err)
+			}
+		}
+
+		log.Printf("Starting %s...", d.Name)
+		info, _ := GetDatabaseInfo(d.Name)
+		manager := info.Manager(opts)
+		if err := manager.StartDatabase(); err != nil {
+			manager.Cleanup()
+			return fmt.Errorf("failed to start %s: %v", d.Name, err)
+		}
+	}
+
+	return s.WriteEnv()
+}
+
+// Down removes the containers and networks of the databases of the stack
+func (s *Stack) Down() error {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	for i := len(s.Databases) - 1; i >= 0; i-- {
+		if err := RemoveInstance(context.Background(), cli, s.Databases[i].Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveInstance removes the containers and networks of an instance
+func RemoveInstance(ctx context.Context, cli *client.Client, name string) error {
+	containers, err := instanceContainers(ctx, cli, name)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		log.Printf("Removing container %s...", strings.TrimPrefix(c.Names[0], "/"))
+		if err := cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true}); err != nil {
+			return fmt.Errorf("failed to remove container: %v", err)
+		}
+	}
+
+	networks, err := cli.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %v", err)
+	}
+	for _, n := range networks {
+		if n.Labels[labelInstance] == name || n.Name == StackNetwork(name) {
+			log.Printf("Removing network %s...", n.Name)
+			if err := cli.NetworkRemove(ctx, n.ID); err != nil {
+				return fmt.Errorf("failed to remove network: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// instanceContainers lists the containers of an instance, running or not
+func instanceContainers(ctx context.Context, cli *client.Client, name string) ([]types.Container, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", labelInstance+"="+name)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Names[0] < containers[j].Names[0]
+	})
+	return containers, nil
+}
+
+func allRunning(containers []types.Container) bool {
+	for _, c := range containers {
+		if c.State != "running" {
+			return false
+		}
+	}
+	return true
+}
+
+// mainContainer returns the main container of an instance among its
+// containers, if it has one
+func mainContainer(name string, containers []types.Container) (types.Container, bool) {
+	for _, c := range containers {
+		for _, n := range c.Names {
+			if n == "/"+InstanceContainer(name) {
+				return c, true
+			}
+		}
+	}
+	return types.Container{}, false
+}
+
+// hostPort returns the host port clients connect to for the main port of a
+// container, which is the proxy's when the instance runs behind one
+func hostPort(c types.Container) string {
+	if c.Labels[labelPort] == "" {
+		return ""
+	}
+	if port, ok := proxyPort(c.Labels[labelInstance]); ok {
+		return port
+	}
+	for _, p := range c.Ports {
+		if p.PublicPort != 0 && fmt.Sprintf("%d/%s", p.PrivatePort, p.Type) == c.Labels[labelPort] {
+			return fmt.Sprint(p.PublicPort)
+		}
+	}
+	return ""
+}
+
+// Status prints the state of the databases of the stack
+func (s *Stack) Status(w io.Writer) error {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "DATABASE\tSTATUS\tIMAGE\tADDRESS\tDATA")
+	for _, d := range s.Databases {
+		containers, err := instanceContainers(context.Background(), cli, d.Name)
+		if err != nil {
+			return err
+		}
+
+		status := "down"
+		if len(containers) > 0 {
+			running := 0
+			for _, c := range containers {
+				if c.State == "running" {
+					running++
+				}
+			}
+			status = fmt.Sprintf("%d/%d running", running, len(containers))
+		}
+
+		image, address := "-", "-"
+		if c, ok := mainContainer(d.Name, containers); ok {
+			image = c.Image
+			if port := hostPort(c); port != "" {
+				address = "127.0.0.1:" + port
+			}
+		}
+
+		data := "ephemeral"
+		if d.Data != "" {
+			data = d.Data
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Name, status, image, address, data)
+	}
+	return tw.Flush()
+}
+
+// WriteEnv writes the addresses and credentials of the running databases of
+// the stack to its env file, e.g. POSTGRES_HOST, POSTGRES_PORT,
+// POSTGRES_USER, POSTGRES_PASSWORD and POSTGRES_URL
+func (s *Stack) WriteEnv() error {
+	cli, err := NewDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	path, err := s.envPath()
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s from %s\n", envHeader, filepath.Base(s.Path))
+	for _, d := range s.Databases {
+		info, err := GetDatabaseInfo(d.Name)
+		if err != nil {
+			return err
+		}
+		containers, err := instanceContainers(context.Background(), cli, d.Name)
+		if err != nil {
+			return err
+		}
+
+		prefix := strings.ToUpper(strings.ReplaceAll(d.Name, "-", "_"))
+		c, _ := mainContainer(d.Name, containers)
+		vars := instanceEnv(info, c)
+
+		b.WriteString("\n")
+		for _, v := range vars {
+			fmt.Fprintf(&b, "%s_%s=%s\n", prefix, v[0], envValue(v[1]))
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", s.EnvFile, err)
+	}
+	log.Printf("Wrote connection settings to %s", path)
+	return nil
+}
+
+// envHeader starts the env files written by dbin up, which only replaces
+// files that start with it
+const envHeader = "# Written by dbin up"
+
+// envPath returns the path of the env file, refusing a file dbin didn't write
+func (s *Stack) envPath() (string, error) {
+	path, err := s.resolve(s.EnvFile)
+	if err != nil {
+		return "", err
+	}
+	existing, err := os.ReadFile(path)
+	if err == nil && len(existing) > 0 && !strings.HasPrefix(string(existing), envHeader) {
+		return "", fmt.Errorf("%s was not written by dbin, set env_file in %s to write another file", path, filepath.Base(s.Path))
+	}
+	return path, nil
+}
+
+// instanceEnv returns the variables written for an instance, without their
+// prefix. c is its main container, zero when the instance isn't running.
+func instanceEnv(info DatabaseInfo, c types.Container) [][2]string {
+	var vars [][2]string
+	creds := connectionCredentials(info, c.Labels)
+	port := hostPort(c)
+	if port != "" {
+		vars = append(vars, [2]string{"HOST", "127.0.0.1"}, [2]string{"PORT", port})
+	}
+	if creds.User != "" {
+		vars = append(vars, [2]string{"USER", creds.User})
+	}
+	if creds.Password != "" {
+		vars = append(vars, [2]string{"PASSWORD", creds.Password})
+	}
+	if creds.Token != "" {
+		vars = append(vars, [2]string{"TOKEN", creds.Token})
+	}
+	if port != "" && info.Scheme != "" {
+		vars = append(vars, [2]string{"URL", instanceURL(info.Scheme, c, creds, port)})
+	}
+	return vars
+}
+
+// instanceURL returns the URL applications connect to the main container with
+func instanceURL(scheme string, c types.Container, creds Credentials, port string) string {
+	tls := false
+	for _, m := range c.Mounts {
+		tls = tls || m.Destination == tlsMountPath
+	}
+	switch {
+	case scheme == "http" && c.Labels[labelHTTPS] == "true":
+		scheme = "https"
+	case scheme == "redis" && tls:
+		scheme = "rediss"
+	}
+
+	u := url.URL{Scheme: scheme, Host: "127.0.0.1:" + port}
+	if creds.User != "" {
+		u.User = url.UserPassword(creds.User, creds.Password)
+	} else if creds.Password != "" {
+		u.User = url.UserPassword("", creds.Password)
+	}
+	return u.String()
+}
+
+// envValue quotes a value for an env file when it needs to
+func envValue(value string) string {
+	if strings.ContainsAny(value, " \t\"'#$\\`") {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(value) + `"`
+	}
+	return value
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// ContainerStats is a sample of the resources a container uses
+type ContainerStats struct {
+	Name        string  `json:"name"`
+	CPUPercent  float64 `json:"cpu_percent"` // Of one core, so up to 100 times the cores
+	MemoryUsage uint64  `json:"memory_usage"`
+	MemoryLimit uint64  `json:"memory_limit"`
+	NetRx       uint64  `json:"net_rx_bytes"` // Totals since the container started
+	NetTx       uint64  `json:"net_tx_bytes"`
+	BlockRead   uint64  `json:"block_read_bytes"`
+	BlockWrite  uint64  `json:"block_write_bytes"`
+}
+
+// sampleStats reads the resources a container uses. Docker samples the CPU
+// twice, about a second apart, to compute its usage.
+func sampleStats(ctx context.Context, cli *client.Client, id, name string) (ContainerStats, error) {
+	resp, err := cli.ContainerStats(ctx, id, false)
+	if err != nil {
+		return ContainerStats{}, fmt.Errorf("failed to get stats of %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return ContainerStats{}, fmt.Errorf("failed to decode stats of %s: %v", name, err)
+	}
+	return containerStats(name, stats), nil
+}
+
+// containerStats computes usage the way docker stats does
+func containerStats(name string, stats container.StatsResponse) ContainerStats {
+	sample := ContainerStats{Name: name, MemoryLimit: stats.MemoryStats.Limit}
+
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		sample.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	// Page cache can be reclaimed, so it isn't counted as used
+	sample.MemoryUsage = stats.MemoryStats.Usage
+	cache := stats.MemoryStats.Stats["inactive_file"] // cgroup v2
+	if v, ok := stats.MemoryStats.Stats["total_inactive_file"]; ok {
+		cache = v // cgroup v1
+	}
+	if cache < sample.MemoryUsage {
+		sample.MemoryUsage -= cache
+	}
+
+	for _, network := range stats.Networks {
+		sample.NetRx += network.RxBytes
+		sample.NetTx += network.TxBytes
+	}
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			sample.BlockRead += entry.Value
+		case "write":
+			sample.BlockWrite += entry.Value
+		}
+	}
+	return sample
+}
+
+// InstanceStats samples the running containers of an instance at once
+func InstanceStats(ctx context.Context, cli *client.Client, name string) ([]ContainerStats, error) {
+	containers, err := instanceContainers(ctx, cli, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var running []int
+	for i, c := range containers {
+		if c.State == "running" {
+			running = append(running, i)
+		}
+	}
+	samples := make([]ContainerStats, len(running))
+	errs := make([]error, len(running))
+	var wg sync.WaitGroup
+	for i, idx := range running {
+		wg.Add(1)
+		go func(i int, c string, id string) {
+			defer wg.Done()
+			samples[i], errs[i] = sampleStats(ctx, cli, id, c)
+		}(i, strings.TrimPrefix(containers[idx].Names[0], "/"), containers[idx].ID)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return samples, nil
+}
+
+// Metric is an engine-level figure, such as the open connections
+type Metric struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// EngineMetrics reads engine-level figures with a command run in the main
+// container of an instance. It is only set where that is cheap.
+type EngineMetrics struct {
+	Command func(conn Connection) []string
+	// Stdin is fed to Command when set, keeping secrets out of its arguments
+	Stdin func(conn Connection) string
+	Parse func(output string) ([]Metric, error)
+}
+
+// InstanceSample is what dbin stats shows of an instance at a point in time
+type InstanceSample struct {
+	Instance   string           `json:"instance"`
+	Time       time.Time        `json:"time"`
+	Containers []ContainerStats `json:"containers"`
+	Metrics    []Metric         `json:"metrics,omitempty"`
+	MetricsErr string           `json:"metrics_error,omitempty"`
+}
+
+// SampleInstance samples the containers of an instance and its engine
+// metrics at once
+func SampleInstance(ctx context.Context, cli *client.Client, name string) (InstanceSample, error) {
+	sample := InstanceSample{Instance: name, Time: time.Now()}
+
+	var metricsErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sample.Metrics, metricsErr = EngineStats(ctx, name)
+	}()
+	containers, err := InstanceStats(ctx, cli, name)
+	wg.Wait()
+	if err != nil {
+		return InstanceSample{}, err
+	}
+	if len(containers) == 0 {
+		return InstanceSample{}, fmt.Errorf("%s is not running", name)
+	}
+	sample.Containers = containers
+	if metricsErr != nil {
+		sample.MetricsErr = metricsErr.Error()
+	}
+	return sample, nil
+}
+
+// EngineStats reads the engine metrics of a running instance, or none when
+// its database has no cheap way to get them
+func EngineStats(ctx context.Context, name string) ([]Metric, error) {
+	info, err := GetDatabaseInfo(name)
+	if err != nil || info.Metrics == nil {
+		return nil, nil
+	}
+	containerName, conn, err := instanceConnection(info)
+	if err != nil {
+		return nil, err
+	}
+	// Read the server directly rather than through the proxy, if any
+	conn.Host, conn.Port = "", ""
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	args := []string{"exec", containerName}
+	if info.Metrics.Stdin != nil {
+		args = []string{"exec", "-i", containerName}
+	}
+	cmd := exec.CommandContext(ctx, "docker", append(args, info.Metrics.Command(conn)...)...)
+	if info.Metrics.Stdin != nil {
+		cmd.Stdin = strings.NewReader(info.Metrics.Stdin(conn))
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s metrics: %v", name, err)
+	}
+	return info.Metrics.Parse(string(output))
+}
@@ -8,11 +8,49 @@ import (
 	"time"
 )
 
+var surrealdbCredentials = Credentials{
+	User:     "root",
+	Password: "root",
+}
+
+var surrealdbClients = []Client{
+	{
+		Name:        "surreal",
+		Description: "SurrealDB SQL shell",
+		Command:     "/surreal",
+		HostCommand: "surreal",
+		Args: func(conn Connection) []string {
+			args := []string{"sql", "-u", conn.User, "-p", conn.Password}
+			if conn.Host != "" {
+				args = append(args, "-e", fmt.Sprintf("http://%s:%s", conn.Host, conn.Port))
+			}
+			return args
+		},
+		Formats: map[string][]string{
+			"json": {"--json"},
+		},
+	},
+}
+
+const surrealdbImage = "surrealdb/surrealdb:latest"
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "surrealdb",
 		Description: "SurrealDB database",
+		Category:    CategoryMultiModel,
+		Tags:        []string{"document", "graph", "surrealql"},
 		Manager:     NewSurrealDBManager,
+		Clients:     surrealdbClients,
+		Credentials: surrealdbCredentials,
+		Image:       surrealdbImage,
+		Port:        "8000/tcp",
+		DataPath:    "/data",
+		Memory:      "256 MB",
+		Readiness:   readinessClient,
+		Scheme:      "http",
+		Docs:        "https://surrealdb.com/docs",
+		Tutorial:    "https://surrealdb.com/docs/surrealdb/introduction/start",
 	})
 }
 
@@ -20,8 +58,8 @@ type SurrealDBManager struct {
 	*BaseManager
 }
 
-func NewSurrealDBManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewSurrealDBManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,16 +72,21 @@ func NewSurrealDBManager(dataDir string, debug bool) DatabaseManager {
 func (sm *SurrealDBManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := sm.PullImageIfNeeded(ctx, "surrealdb/surrealdb:latest"); err != nil {
+	if err := sm.PullImageIfNeeded(ctx, surrealdbImage); err != nil {
+		return err
+	}
+
+	creds, err := sm.ResolveCredentials(surrealdbCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
-		"SURREAL_USER=root",
-		"SURREAL_PASS=root",
+		"SURREAL_USER=" + creds.User,
+		"SURREAL_PASS=" + creds.Password,
 	}
 
-	containerId, port, err := sm.CreateContainer(ctx, "surrealdb/surrealdb:latest", "dbin-surrealdb", "8000/tcp", env, "/data", []string{"start", "--user", "root", "--pass", "root"})
+	containerId, port, err := sm.CreateContainer(ctx, surrealdbImage, "dbin-surrealdb", "8000/tcp", env, "/data", []string{"start", "--user", creds.User, "--pass", creds.Password})
 	if err != nil {
 		return err
 	}
@@ -55,7 +98,7 @@ func (sm *SurrealDBManager) StartDatabase() error {
 }
 
 func (sm *SurrealDBManager) StartClient() error {
-	return sm.StartContainerClient("/surreal", "sql", "-u", "root", "-p", "root")
+	return sm.StartClientFrom(surrealdbClients)
 }
 
 func (sm *SurrealDBManager) Cleanup() error {
@@ -4,16 +4,35 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"log"
 	"time"
 	"database/sql"
 	_ "github.com/lib/pq"
 )
 
+const timescaleImage = "timescale/timescaledb:latest-pg15"
+
 func init() {
 	Register(DatabaseInfo{
-		Name:        "timescale",
-		Description: "TimescaleDB time-series database",
-		Manager:     NewTimescaleManager,
+		Name:           "timescale",
+		Description:    "TimescaleDB time-series database",
+		Category:       CategoryTimeSeries,
+		Tags:           []string{"sql", "postgres", "metrics"},
+		Manager:        NewTimescaleManager,
+		Clients:        postgresClients,
+		Credentials:    postgresCredentials,
+		TLS:            true,
+		Protocol:       "postgres",
+		Image:          timescaleImage,
+		Port:           "5432/tcp",
+		DataPath:       "/var/lib/postgresql/data",
+		Memory:         "512 MB",
+		Readiness:      readinessSQL,
+		CredentialsEnv: []CredentialsEnv{{User: "POSTGRES_USER", Password: "POSTGRES_PASSWORD"}},
+		InitDir:        "/docker-entrypoint-initdb.d",
+		Scheme:         "postgres",
+		Docs:           "https://docs.timescale.com/",
+		Tutorial:       "https://docs.timescale.com/getting-started/latest/",
 	})
 }
 
@@ -21,8 +40,8 @@ type TimescaleManager struct {
 	*BaseManager
 }
 
-func NewTimescaleManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewTimescaleManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -35,37 +54,59 @@ func NewTimescaleManager(dataDir string, debug bool) DatabaseManager {
 func (tm *TimescaleManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := tm.PullImageIfNeeded(ctx, "timescale/timescaledb:latest-pg15"); err != nil {
+	if err := tm.PullImageIfNeeded(ctx, timescaleImage); err != nil {
+		return err
+	}
+
+	creds, err := tm.ResolveCredentials(postgresCredentials)
+	if err != nil {
 		return err
 	}
 
 	env := []string{
-		"POSTGRES_PASSWORD=postgres",
-		"POSTGRES_USER=postgres",
+		"POSTGRES_PASSWORD=" + creds.Password,
+		"POSTGRES_USER=" + creds.User,
 		"POSTGRES_DB=postgres",
 	}
 
-	containerId, port, err := tm.CreateContainer(ctx, "timescale/timescaledb:latest-pg15", "dbin-timescale", "5432/tcp", env, "/var/lib/postgresql/data", nil)
+	tlsBind, err := tm.SetupTLS("dbin-timescale")
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image:      timescaleImage,
+		Name:       "dbin-timescale",
+		Port:       "5432/tcp",
+		Env:        env,
+		VolumePath: "/var/lib/postgresql/data",
+	}
+	if tlsBind != "" {
+		spec.Binds = []string{tlsBind}
+		spec.Cmd = postgresTLSCommand(tm.opts.MTLS)
+	}
+
+	containerId, port, err := tm.CreateContainerWithSpec(ctx, spec)
 	if err != nil {
 		return err
 	}
 	tm.dbContainerId = containerId
 	tm.dbPort = port
 
-	fmt.Println("Waiting for database to be ready...")
+	log.Println("Waiting for database to be ready...")
 	if err := tm.waitForDatabase(); err != nil {
 		return fmt.Errorf("database failed to start: %v", err)
 	}
 
-	fmt.Printf("TimescaleDB is ready and listening on port %s\n", tm.dbPort)
+	log.Printf("TimescaleDB is ready and listening on port %s", tm.dbPort)
 	return nil
 }
 
 func (tm *TimescaleManager) waitForDatabase() error {
-	connStr := fmt.Sprintf("host=localhost port=%s user=postgres password=postgres dbname=postgres sslmode=disable", tm.dbPort)
+	connStr := fmt.Sprintf("host=localhost port=%s user=%s password=%s dbname=postgres %s", tm.dbPort, quoteConnParam(tm.creds.User), quoteConnParam(tm.creds.Password), postgresSSLParams(tm.hostTLSFiles()))
 
 	for i := 0; i < 30; i++ {
-		fmt.Printf("Attempting database connection (attempt %d/30)...\n", i+1)
+		log.Printf("Attempting database connection (attempt %d/30)...", i+1)
 		db, err := sql.Open("postgres", connStr)
 		if err == nil {
 			err = db.Ping()
@@ -82,7 +123,7 @@ func (tm *TimescaleManager) waitForDatabase() error {
 }
 
 func (tm *TimescaleManager) StartClient() error {
-	return tm.StartContainerClient("psql", "-U", "postgres")
+	return tm.StartClientFrom(postgresClients)
 }
 
 func (tm *TimescaleManager) Cleanup() error {
@@ -0,0 +1,369 @@
+package db
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// tlsMountPath is where the instance certificates are mounted in containers
+const tlsMountPath = "/dbin-tls"
+
+// tlsServerPath is where servers starting as root read their own copy of the
+// certificates
+const tlsServerPath = "/tmp/dbin-tls"
+
+// TLSFiles are the certificate paths a client uses, as seen by the client
+type TLSFiles struct {
+	CA      string
+	Cert    string
+	Key     string
+	CertKey string // Certificate and key concatenated in one PEM file
+}
+
+func tlsFilesIn(dir string, clientCert bool) *TLSFiles {
+	files := &TLSFiles{CA: filepath.Join(dir, "ca.pem")}
+	if clientCert {
+		files.Cert = filepath.Join(dir, "client.crt")
+		files.Key = filepath.Join(dir, "client.key")
+		files.CertKey = filepath.Join(dir, "client.pem")
+	}
+	return files
+}
+
+func caDir() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tls"), nil
+}
+
+// SetupTLS issues a server certificate for the instance, signed by the local
+// dbin CA, when --tls is set. It returns the bind mount exposing the
+// certificates at /dbin-tls, or an empty string when TLS is disabled.
+func (bm *BaseManager) SetupTLS(hosts ...string) (string, error) {
+	if !bm.opts.TLS {
+		return "", nil
+	}
+
+	root, err := caDir()
+	if err != nil {
+		return "", err
+	}
+	caCert, caKey, err := loadOrCreateCA(root)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(root, bm.opts.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create certificate directory: %v", err)
+	}
+
+	hosts = append([]string{"localhost", "127.0.0.1", "::1"}, hosts...)
+	if bm.proxyEnabled() {
+		hosts = append(hosts, proxyHost)
+	}
+	// Keys are only readable by the user running dbin. Servers get a copy
+	// owned by their own user, see tlsServerCommand and ContainerSpec.TLSDir.
+	if err := issueCertificate(dir, "server", hosts, x509.ExtKeyUsageServerAuth, caCert, caKey); err != nil {
+		return "", err
+	}
+	if bm.opts.MTLS {
+		if err := issueCertificate(dir, "client", []string{"dbin"}, x509.ExtKeyUsageClientAuth, caCert, caKey); err != nil {
+			return "", err
+		}
+	}
+	if err := copyFile(filepath.Join(root, "ca.pem"), filepath.Join(dir, "ca.pem")); err != nil {
+		return "", err
+	}
+
+	bm.tlsDir = dir
+	log.Printf("TLS enabled, CA certificate: %s", filepath.Join(dir, "ca.pem"))
+	if bm.opts.MTLS {
+		log.Printf("Client certificate: %s, key: %s", filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	}
+	return fmt.Sprintf("%s:%s:ro", dir, tlsMountPath), nil
+}
+
+// tlsServerCommand runs the image entrypoint with args once the mounted
+// certificates are copied to tlsServerPath and handed over to owner, for
+// images starting as root and running the server as owner
+func tlsServerCommand(owner string, args ...string) []string {
+	script := tlsCopyScript(owner) + ` && exec docker-entrypoint.sh "$@"`
+	return append([]string{"sh", "-c", script, "sh"}, args...)
+}
+
+// tlsCopyScript copies the server certificates to tlsServerPath, readable by
+// owner only
+func tlsCopyScript(owner string) string {
+	files := ""
+	for _, name := range []string{"ca.pem", "server.crt", "server.key", "server.pem"} {
+		files += " " + tlsMountPath + "/" + name
+	}
+	return "mkdir -p " + tlsServerPath +
+		" && cp" + files + " " + tlsServerPath + "/" +
+		" && chown -R " + owner + " " + tlsServerPath +
+		" && chmod 700 " + tlsServerPath + " && chmod 600 " + tlsServerPath + "/*"
+}
+
+// copyTLSFiles copies the instance certificates to dir in a created
+// container, owned by uid, for images running as a fixed non-root user that
+// cannot read the keys from the bind mount
+func (bm *BaseManager) copyTLSFiles(ctx context.Context, containerId, dir string, uid int) error {
+	entries, err := os.ReadDir(bm.tlsDir)
+	if err != nil {
+		return fmt.Errorf("failed to read certificates: %v", err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	base := filepath.Base(dir)
+	err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: base + "/", Mode: 0700, Uid: uid, ModTime: time.Now()})
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(bm.tlsDir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read certificates: %v", err)
+		}
+		header := &tar.Header{
+			Name:    base + "/" + entry.Name(),
+			Mode:    0600,
+			Size:    int64(len(data)),
+			Uid:     uid,
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if err := bm.dockerCli.CopyToContainer(ctx, containerId, filepath.Dir(dir), &buf, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy certificates to the container: %v", err)
+	}
+	return nil
+}
+
+// TLSEnabled reports whether the instance was started with --tls
+func (bm *BaseManager) TLSEnabled() bool {
+	return bm.tlsDir != ""
+}
+
+// hostTLSFiles returns the certificate paths for clients running on the host
+func (bm *BaseManager) hostTLSFiles() *TLSFiles {
+	if bm.tlsDir == "" {
+		return nil
+	}
+	return tlsFilesIn(bm.tlsDir, bm.opts.MTLS)
+}
+
+// containerTLSFiles returns the certificate paths for clients running inside
+// the database container
+func (bm *BaseManager) containerTLSFiles() *TLSFiles {
+	if bm.tlsDir == "" {
+		return nil
+	}
+	return tlsFilesIn(tlsMountPath, bm.opts.MTLS)
+}
+
+// clientTLSConfig returns the configuration used by dbin itself to connect to
+// a TLS-enabled instance
+func (bm *BaseManager) clientTLSConfig() (*tls.Config, error) {
+	files := bm.hostTLSFiles()
+	pemData, err := os.ReadFile(files.CA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(pemData)
+
+	config := &tls.Config{RootCAs: pool, ServerName: "localhost"}
+	if files.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath := filepath.Join(dir, "ca.pem")
+	keyPath := filepath.Join(dir, "ca-key.pem")
+
+	if certPEM, err := os.ReadFile(certPath); err == nil {
+		keyPEM, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CA key: %v", err)
+		}
+		certBlock, _ := pem.Decode(certPEM)
+		keyBlock, _ := pem.Decode(keyPEM)
+		if certBlock == nil || keyBlock == nil {
+			return nil, nil, fmt.Errorf("invalid CA files in %s", dir)
+		}
+		cert, err := x509.ParseCertificate(certBlock.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse CA certificate: %v", err)
+		}
+		key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse CA key: %v", err)
+		}
+		return cert, key, nil
+	}
+
+	log.Printf("Creating local certificate authority in %s", dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA directory: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          newSerial(),
+		Subject:               pkix.Name{CommonName: "dbin local CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := writePEM(keyPath, "EC PRIVATE KEY", mustMarshalKey(key), 0600); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", der, 0644); err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// issueCertificate writes <name>.crt, <name>.key and <name>.pem to dir, the
+// files holding the key being private
+func issueCertificate(dir, name string, hosts []string, usage x509.ExtKeyUsage, caCert *x509.Certificate, caKey *ecdsa.PrivateKey) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate %s key: %v", name, err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: newSerial(),
+		Subject:      pkix.Name{CommonName: "dbin " + name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("failed to create %s certificate: %v", name, err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	// PKCS #8 is the one key format every server accepts, Java-based ones
+	// like OpenSearch included
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s key: %v", name, err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	files := map[string][]byte{
+		name + ".crt": certPEM,
+		name + ".key": keyPEM,
+		name + ".pem": append(append([]byte{}, certPEM...), keyPEM...),
+	}
+	for file, data := range files {
+		mode := os.FileMode(0600)
+		if file == name+".crt" {
+			mode = 0644
+		}
+		path := filepath.Join(dir, file)
+		if err := os.WriteFile(path, data, mode); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(fmt.Sprintf("Failed to generate serial number: %v", err))
+	}
+	return serial
+}
+
+func mustMarshalKey(key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to marshal key: %v", err))
+	}
+	return der
+}
+
+func writePEM(path, blockType string, der []byte, mode os.FileMode) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", src, err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", dst, err)
+	}
+	return nil
+}
@@ -8,11 +8,55 @@ import (
 	"time"
 )
 
+var valkeyClients = []Client{
+	{
+		Name:        "valkey-cli",
+		Description: "ValKey command-line interface",
+		Command:     "valkey-cli",
+		Args: func(conn Connection) []string {
+			args := []string{"-n", "0", "-p", valkeyPort}
+			if conn.Host != "" {
+				args = []string{"-n", "0", "-h", conn.Host, "-p", conn.Port}
+			}
+			return append(args, redisClientTLSArgs(conn)...)
+		},
+		Formats: map[string][]string{
+			"csv":  {"--csv"},
+			"json": {"--json"},
+		},
+	},
+	valkeyIredisClient,
+}
+
+const (
+	valkeyImage = "valkey/valkey:latest"
+	valkeyPort  = "6380"
+)
+
+// valkeyIredisClient is iredis pointed at the port dbin runs ValKey on
+var valkeyIredisClient = func() Client {
+	c := iredisClient
+	c.Port = valkeyPort
+	return c
+}()
+
 func init() {
 	Register(DatabaseInfo{
 		Name:        "valkey",
 		Description: "ValKey key-value store",
+		Category:    CategoryKeyValue,
+		Tags:        []string{"cache", "in-memory", "redis-compatible"},
 		Manager:     NewValKeyManager,
+		Clients:     valkeyClients,
+		TLS:         true,
+		Protocol:    "redis",
+		Image:       valkeyImage,
+		Port:        valkeyPort + "/tcp",
+		DataPath:    "/data",
+		Memory:      "64 MB",
+		Readiness:   readinessClient,
+		Scheme:      "redis",
+		Docs:        "https://valkey.io/docs/",
 	})
 }
 
@@ -20,8 +64,8 @@ type ValKeyManager struct {
 	*BaseManager
 }
 
-func NewValKeyManager(dataDir string, debug bool) DatabaseManager {
-	base, err := NewBaseManager(dataDir, debug)
+func NewValKeyManager(opts Options) DatabaseManager {
+	base, err := NewBaseManager(opts)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create base manager: %v", err))
 	}
@@ -34,7 +78,7 @@ func NewValKeyManager(dataDir string, debug bool) DatabaseManager {
 func (vk *ValKeyManager) StartDatabase() error {
 	ctx := context.Background()
 
-	if err := vk.PullImageIfNeeded(ctx, "valkey/valkey:latest"); err != nil {
+	if err := vk.PullImageIfNeeded(ctx, valkeyImage); err != nil {
 		return err
 	}
 
@@ -43,7 +87,25 @@ func (vk *ValKeyManager) StartDatabase() error {
 		"VALKEY_PASSWORD=password",
 	}
 
-	containerId, port, err := vk.CreateContainer(ctx, "valkey/valkey:latest", "dbin-valkey", "6380/tcp", env, "/data", nil)
+	tlsBind, err := vk.SetupTLS("dbin-valkey")
+	if err != nil {
+		return err
+	}
+
+	spec := ContainerSpec{
+		Image:      valkeyImage,
+		Name:       "dbin-valkey",
+		Port:       valkeyPort + "/tcp",
+		Env:        env,
+		VolumePath: "/data",
+		Cmd:        []string{"valkey-server", "--port", valkeyPort},
+	}
+	if tlsBind != "" {
+		spec.Binds = []string{tlsBind}
+		spec.Cmd = redisTLSCommand("valkey-server", valkeyPort, vk.opts.MTLS)
+	}
+
+	containerId, port, err := vk.CreateContainerWithSpec(ctx, spec)
 	if err != nil {
 		return err
 	}
@@ -55,7 +117,7 @@ func (vk *ValKeyManager) StartDatabase() error {
 }
 
 func (vk *ValKeyManager) StartClient() error {
-	return vk.StartContainerClient("valkey-cli", "-n", "0")
+	return vk.StartClientFrom(valkeyClients)
 }
 
 func (vk *ValKeyManager) Cleanup() error {
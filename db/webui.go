@@ -1,54 +1,312 @@
 package db
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
 	"golang.org/x/term"
 )
 
-// StartWebInterface starts a web-based interface for the database
-func StartWebInterface(port string) error {
-	url := fmt.Sprintf("http://localhost:%s", port)
-	log.Printf("Checking web interface at %s", url)
+// WebUI describes a web interface served by one of the containers of a database
+type WebUI struct {
+	Name        string
+	Description string
+	Container   string
+	Port        string
+	// Path may reference other published ports of the same container as
+	// {port:7687/tcp}, which are replaced by their host ports
+	Path string
+	// Attempts is the number of readiness checks, 5 seconds apart
+	Attempts int
+}
 
-	// Check if server is responding
-	for i := 0; i < 5; i++ {
-		resp, err := http.Get(url)
-		if err == nil && resp.StatusCode == 200 {
-			resp.Body.Close()
-			break
+// labelHTTPS marks containers whose web endpoints are served over HTTPS
+const labelHTTPS = "dbin.https"
+
+var portPlaceholder = regexp.MustCompile(`\{port:([^}]+)\}`)
+
+// SelectWebUI returns the UI with the given name, or the first declared UI
+// when name is empty
+func SelectWebUI(uis []WebUI, name string) (WebUI, error) {
+	if len(uis) == 0 {
+		return WebUI{}, fmt.Errorf("no web interfaces available")
+	}
+	if name == "" {
+		return uis[0], nil
+	}
+
+	var names []string
+	for _, ui := range uis {
+		if ui.Name == name {
+			return ui, nil
 		}
+		names = append(names, ui.Name)
+	}
+	return WebUI{}, fmt.Errorf("unknown web interface %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// ResolveWebUI returns the URL of a running web interface
+func ResolveWebUI(ctx context.Context, cli *client.Client, ui WebUI) (string, error) {
+	inspect, err := cli.ContainerInspect(ctx, ui.Container)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %s: %v", ui.Container, err)
+	}
+
+	hostPort := func(port string) (string, error) {
+		bindings := inspect.NetworkSettings.Ports[nat.Port(port)]
+		if len(bindings) == 0 {
+			return "", fmt.Errorf("port %s of container %s is not published", port, ui.Container)
+		}
+		return bindings[0].HostPort, nil
+	}
+
+	port, err := hostPort(ui.Port)
+	if err != nil {
+		return "", err
+	}
+
+	path := ui.Path
+	for _, match := range portPlaceholder.FindAllStringSubmatch(path, -1) {
+		p, err := hostPort(match[1])
 		if err != nil {
-			log.Printf("Server not ready (attempt %d/5): %v", i+1, err)
-		} else {
+			return "", err
+		}
+		path = strings.ReplaceAll(path, match[0], p)
+	}
+
+	scheme := "http"
+	if inspect.Config.Labels[labelHTTPS] == "true" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://localhost:%s%s", scheme, port, path), nil
+}
+
+// WantsWebUI reports whether a web interface was requested with --ui
+func (bm *BaseManager) WantsWebUI() bool {
+	return bm.opts.UI != ""
+}
+
+// OpenWebUI waits for the selected web interface to be ready, opens it in a
+// browser and blocks until the user quits
+func (bm *BaseManager) OpenWebUI(uis []WebUI) error {
+	ui, err := SelectWebUI(uis, bm.opts.UI)
+	if err != nil {
+		return err
+	}
+
+	url, err := ResolveWebUI(context.Background(), bm.dockerCli, ui)
+	if err != nil {
+		return err
+	}
+
+	if err := WaitForWebInterface(url, ui.attempts()); err != nil {
+		return err
+	}
+
+	OpenBrowser(url, bm.opts.NoBrowser)
+	return waitForExit()
+}
+
+// OpenInstanceUI opens a web interface of an already running database
+func OpenInstanceUI(name string, uiName string, noBrowser bool) error {
+	info, err := GetDatabaseInfo(name)
+	if err != nil {
+		return err
+	}
+
+	ui, err := SelectWebUI(info.UIs, uiName)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	cli, err := NewDockerClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	url, err := ResolveWebUI(context.Background(), cli, ui)
+	if err != nil {
+		return err
+	}
+
+	if err := WaitForWebInterface(url, ui.attempts()); err != nil {
+		return err
+	}
+
+	OpenBrowser(url, noBrowser)
+	return nil
+}
+
+func (ui WebUI) attempts() int {
+	if ui.Attempts > 0 {
+		return ui.Attempts
+	}
+	return 5
+}
+
+// WaitForWebInterface polls the URL until the server answers. Redirects and
+// authentication challenges count as ready, since the server is clearly up.
+func WaitForWebInterface(url string, attempts int) error {
+	log.Printf("Checking web interface at %s", url)
+
+	httpClient := &http.Client{
+		Timeout: 5 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		// Only readiness is checked here, and HTTPS endpoints use dbin's own
+		// or demo certificates
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	for i := 0; i < attempts; i++ {
+		resp, err := httpClient.Get(url)
+		if err == nil {
 			resp.Body.Close()
-			log.Printf("Server returned status %d (attempt %d/5)", resp.StatusCode, i+1)
+			if isReadyStatus(resp.StatusCode) {
+				return nil
+			}
+			log.Printf("Server returned status %d (attempt %d/%d)", resp.StatusCode, i+1, attempts)
+		} else {
+			log.Printf("Server not ready (attempt %d/%d): %v", i+1, attempts, err)
 		}
-		if i < 4 {
+		if i < attempts-1 {
 			time.Sleep(5 * time.Second)
-		} else {
-			return fmt.Errorf("server failed to respond after 5 attempts")
 		}
 	}
+	return fmt.Errorf("server failed to respond after %d attempts", attempts)
+}
+
+func isReadyStatus(status int) bool {
+	switch {
+	case status >= 200 && status < 400:
+		return true
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return true
+	}
+	return false
+}
+
+// OpenBrowser opens the URL in the user's browser. When no browser is
+// available (headless servers, SSH sessions) or noBrowser is set, the URL is
+// printed instead.
+func OpenBrowser(url string, noBrowser bool) {
+	if noBrowser {
+		fmt.Printf("\nWeb interface available at %s\n", url)
+		return
+	}
+
+	cmd := browserCommand(url)
+	if cmd == nil {
+		fmt.Printf("\nNo browser available, open %s manually\n", url)
+		return
+	}
 
 	log.Printf("Opening web interface at %s", url)
-	cmd := exec.Command("xdg-open", url)
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to open browser: %v", err)
+		log.Printf("Warning: Failed to open browser: %v", err)
+		fmt.Printf("\nOpen %s manually\n", url)
 	}
-	
+}
+
+// browserCommand returns the command used to open a URL, honouring $BROWSER
+// first. It returns nil when no usable browser is found.
+func browserCommand(url string) *exec.Cmd {
+	if browsers := os.Getenv("BROWSER"); browsers != "" {
+		// $BROWSER is a colon-separated list, each entry may contain %s for the URL
+		for _, browser := range strings.Split(browsers, ":") {
+			fields := strings.Fields(browser)
+			if len(fields) == 0 {
+				continue
+			}
+			if _, err := exec.LookPath(fields[0]); err != nil {
+				continue
+			}
+			if strings.Contains(browser, "%s") {
+				for i := range fields {
+					fields[i] = strings.ReplaceAll(fields[i], "%s", url)
+				}
+				return exec.Command(fields[0], fields[1:]...)
+			}
+			return exec.Command(fields[0], append(fields[1:], url)...)
+		}
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		if os.Getenv("SSH_CONNECTION") != "" {
+			return nil
+		}
+		return exec.Command("open", url)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	}
+
+	if isWSL() {
+		if _, err := exec.LookPath("wslview"); err == nil {
+			return exec.Command("wslview", url)
+		}
+		if _, err := exec.LookPath("cmd.exe"); err == nil {
+			return exec.Command("cmd.exe", "/c", "start", url)
+		}
+		return nil
+	}
+
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return nil
+	}
+	if _, err := exec.LookPath("xdg-open"); err != nil {
+		return nil
+	}
+	return exec.Command("xdg-open", url)
+}
+
+func isWSL() bool {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(data)), "microsoft")
+}
+
+// waitForExit blocks until the user presses 'q' on a terminal, or until an
+// interrupt signal arrives when stdin is not a terminal
+func waitForExit() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		fmt.Println("\nPress Ctrl+C to exit...")
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigChan)
+		<-sigChan
+		log.Println("Shutting down...")
+		return nil
+	}
+
 	fmt.Println("\nPress 'q' to exit...")
-	
+
 	// Get the current state of the terminal
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	oldState, err := term.MakeRaw(fd)
 	if err != nil {
 		return fmt.Errorf("failed to set terminal to raw mode: %v", err)
 	}
-	defer term.Restore(int(os.Stdin.Fd()), oldState)
+	defer term.Restore(fd, oldState)
 
 	buffer := make([]byte, 1)
 	for {
@@ -56,9 +314,10 @@ func StartWebInterface(port string) error {
 		if err != nil {
 			return fmt.Errorf("error reading input: %v", err)
 		}
-		
-		if buffer[0] == 'q' {
-			fmt.Println() // Add newline after 'q'
+
+		// Raw mode swallows Ctrl+C, so treat it like 'q'
+		if buffer[0] == 'q' || buffer[0] == 3 {
+			fmt.Print("\r\n")
 			log.Println("Shutting down...")
 			return nil
 		}
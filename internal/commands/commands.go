@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -16,11 +17,33 @@ import (
 type DBCommand struct {
 	Name        string
 	Description string
-	Manager     func(string, bool) db.DatabaseManager
+	Manager     func(db.Options) db.DatabaseManager
+	UIs         []db.WebUI
+	Clients     []db.Client
+	Credentials db.Credentials
+	TLS         bool
+	Auth        bool
+	Nodes       bool
+	AuthNodes   bool
+	Protocol    string
+	Image       string
+	InitDir     string
 }
 
 func NewDatabaseCommand(config DBCommand) *cobra.Command {
 	var dataDir string
+	var noBrowser bool
+	var ui string
+	var clientFlag string
+	var user, password string
+	var randomCredentials bool
+	var tls, mtls bool
+	var auth bool
+	var nodes int
+	var useProxy bool
+	var traceQueries string
+	var version, port, initScripts string
+	var platform string
 
 	cmd := &cobra.Command{
 		Use:   config.Name,
@@ -28,15 +51,121 @@ func NewDatabaseCommand(config DBCommand) *cobra.Command {
 		Long:  fmt.Sprintf("Start a %s instance in a Docker container with an interactive client", config.Description),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			debug, _ := cmd.Flags().GetBool("debug")
-			return run(config.Manager, dataDir, config.Description, debug)
+			clientName, clientMode := db.ParseClientFlag(clientFlag)
+			if clientName != "" {
+				c, err := db.SelectClient(config.Clients, clientName)
+				if err != nil {
+					return err
+				}
+				if c.NoTLS && (tls || mtls) {
+					return fmt.Errorf("%s does not support --tls and --mtls", c.Name)
+				}
+			}
+			if config.Auth && !auth && (user != "" || password != "" || randomCredentials) {
+				return fmt.Errorf("--user, --password and --random-credentials require --auth")
+			}
+			if nodes < 1 {
+				return fmt.Errorf("--nodes must be at least 1")
+			}
+			if nodes > 1 && (tls || mtls) {
+				return fmt.Errorf("--tls and --mtls are not supported with --nodes")
+			}
+			if nodes > 1 && auth && !config.AuthNodes {
+				return fmt.Errorf("--auth is not supported with --nodes for %s", config.Name)
+			}
+			if traceQueries != "" && (tls || mtls) {
+				return fmt.Errorf("--trace-queries cannot decode --tls connections")
+			}
+			if password != "" && randomCredentials {
+				return fmt.Errorf("--password and --random-credentials are mutually exclusive")
+			}
+			if platform != "" {
+				if _, err := db.ParsePlatform(platform); err != nil {
+					return err
+				}
+			}
+			opts := db.Options{
+				Name:       config.Name,
+				Debug:      debug,
+				NoBrowser:  noBrowser,
+				UI:         ui,
+				ClientMode: clientMode,
+				Client:     clientName,
+
+				User:              user,
+				Password:          password,
+				RandomCredentials: randomCredentials,
+
+				TLS:  tls || mtls,
+				MTLS: mtls,
+				Auth: auth,
+
+				Nodes: nodes,
+				Proxy: useProxy,
+
+				TraceQueries: traceQueries,
+
+				Version: version,
+				Port:    port,
+				Init:    initScripts,
+
+				Platform: platform,
+			}
+			return run(config.Manager, dataDir, config.Description, opts)
 		},
 	}
 
 	cmd.Flags().StringVar(&dataDir, "data-dir", "./data", "Directory for database data")
+	_, defaultTag, _ := strings.Cut(config.Image, ":")
+	cmd.Flags().StringVar(&version, "version", "", fmt.Sprintf("Image tag to run (default %q)", defaultTag))
+	cmd.Flags().StringVar(&port, "port", "", "Host port to publish the database on, random by default")
+	cmd.Flags().StringVar(&platform, "platform", "", "Platform of the images to run, e.g. linux/amd64, instead of the Docker host's")
+	if config.InitDir != "" {
+		cmd.Flags().StringVar(&initScripts, "init", "", "Init script, or directory of scripts, run when the database is first created")
+	}
+	clientUsage := "Where to run the database client: host, container or auto"
+	if len(config.Clients) > 1 {
+		var names []string
+		for _, c := range config.Clients {
+			names = append(names, c.Name)
+		}
+		clientUsage += fmt.Sprintf(", or a client to use (%s)", strings.Join(names, ", "))
+	}
+	cmd.Flags().StringVar(&clientFlag, "client", db.ClientModeContainer, clientUsage)
+	if !config.Credentials.IsZero() {
+		cmd.Flags().StringVar(&user, "user", "", fmt.Sprintf("Database user (default %q)", config.Credentials.User))
+		cmd.Flags().StringVar(&password, "password", "", "Database password")
+		cmd.Flags().BoolVar(&randomCredentials, "random-credentials", false, "Generate a random password and store it in the local credentials file")
+	}
+	if config.Nodes {
+		cmd.Flags().IntVar(&nodes, "nodes", 1, "Number of nodes, started as a replica set or cluster on a dedicated network")
+	} else {
+		nodes = 1
+	}
+	if config.Auth {
+		cmd.Flags().BoolVar(&auth, "auth", false, "Enable authentication and print the admin credentials")
+	}
+	if config.TLS {
+		cmd.Flags().BoolVar(&tls, "tls", false, "Serve TLS with a certificate signed by the local dbin CA")
+		cmd.Flags().BoolVar(&mtls, "mtls", false, "Like --tls, and also require client certificates")
+	}
+	cmd.Flags().BoolVar(&useProxy, "proxy", false, "Put a fault-injecting proxy in front of the database port, controlled with 'dbin proxy'")
+	if config.Protocol != "" {
+		cmd.Flags().StringVar(&traceQueries, "trace-queries", "", "Log every query through the proxy with its timing, parameters and row count, to the terminal or a JSONL file given as --trace-queries=<file>")
+		cmd.Flags().Lookup("trace-queries").NoOptDefVal = "-"
+	}
+	cmd.Flags().BoolVar(&noBrowser, "no-browser", false, "Print web interface URLs instead of opening a browser")
+	if len(config.UIs) > 0 {
+		var names []string
+		for _, u := range config.UIs {
+			names = append(names, u.Name)
+		}
+		cmd.Flags().StringVar(&ui, "ui", "", fmt.Sprintf("Web interface to open (%s)", strings.Join(names, ", ")))
+	}
 	return cmd
 }
 
-func run(createManager func(string, bool) db.DatabaseManager, dataDir string, dbName string, debug bool) error {
+func run(createManager func(db.Options) db.DatabaseManager, dataDir string, dbName string, opts db.Options) error {
 	var absDataDir string
 	if dataDir != "./data" { // Only process if explicitly set
 		var err error
@@ -54,16 +183,22 @@ func run(createManager func(string, bool) db.DatabaseManager, dataDir string, db
 		log.Printf("Starting %s manager with ephemeral storage", dbName)
 	}
 
-	manager := createManager(absDataDir, debug)
+	opts.DataDir = absDataDir
+	manager := createManager(opts)
 
 	log.Println("Initializing database...")
 	if err := manager.StartDatabase(); err != nil {
 		return fmt.Errorf("failed to start database: %v", err)
 	}
 
-	// Set up signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	if opts.Proxy || opts.TraceQueries != "" {
+		proxied, ok := manager.(db.Proxied)
+		if !ok || !proxied.Proxying() {
+			manager.Cleanup()
+			return fmt.Errorf("%s does not support --proxy", dbName)
+		}
+		defer proxied.StopProxy()
+	}
 
 	log.Println("Starting database client...")
 	// Start client in goroutine
@@ -77,32 +212,43 @@ func run(createManager func(string, bool) db.DatabaseManager, dataDir string, db
 		clientDone <- nil
 	}()
 
+	return AwaitCleanup("Client", clientDone, manager.Cleanup)
+}
+
+// AwaitCleanup waits for done, or for an interrupt signal, then runs
+// cleanup. It returns the error of done, or an error when interrupted.
+func AwaitCleanup(what string, done <-chan error, cleanup func() error) error {
+	// Set up signal handling
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
 	// Create WaitGroup for cleanup coordination
 	var wg sync.WaitGroup
 	wg.Add(1)
 
-	// Wait for either client to finish or interrupt signal
+	// Wait for either done or interrupt signal
 	var result error
 	select {
-	case err := <-clientDone:
+	case err := <-done:
 		if err != nil {
-			result = fmt.Errorf("client error: %v", err)
+			result = fmt.Errorf("%s error: %v", strings.ToLower(what), err)
 		}
-		// Clean up after client exits normally
-		log.Println("Client exited, starting cleanup...")
+		// Clean up after exiting normally
+		log.Printf("%s exited, starting cleanup...", what)
 		go func() {
 			defer wg.Done()
-			if err := manager.Cleanup(); err != nil {
+			if err := cleanup(); err != nil {
 				log.Printf("Cleanup error: %v", err)
 			}
 			log.Println("Cleanup completed")
 		}()
 	case <-sigChan:
-		// Stop the database container immediately on interrupt
+		// Stop the database containers immediately on interrupt
 		log.Println("Received interrupt signal, starting cleanup...")
 		go func() {
 			defer wg.Done()
-			if err := manager.Cleanup(); err != nil {
+			if err := cleanup(); err != nil {
 				log.Printf("Cleanup error: %v", err)
 			}
 			log.Println("Cleanup completed")
@@ -122,6 +268,16 @@ func CreateCommands(databases []db.DatabaseInfo) []*cobra.Command {
 			Name:        info.Name,
 			Description: info.Description,
 			Manager:     info.Manager,
+			UIs:         info.UIs,
+			Clients:     info.Clients,
+			Credentials: info.Credentials,
+			TLS:         info.TLS,
+			Auth:        info.Auth,
+			Nodes:       info.Nodes,
+			AuthNodes:   info.AuthNodes,
+			Protocol:    info.Protocol,
+			Image:       info.Image,
+			InitDir:     info.InitDir,
 		})
 		commands = append(commands, cmd)
 	}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ServeControl starts the HTTP control API of the proxy on addr and returns
+// its base URL. The API serves /settings:
+//
+//	GET    returns the settings as JSON
+//	POST   changes the settings given as form values, e.g. latency=200ms
+//	DELETE restores the defaults
+func (p *Proxy) ServeControl(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/settings", p.handleSettings)
+	go http.Serve(listener, mux)
+
+	return "http://" + listener.Addr().String(), nil
+}
+
+func (p *Proxy) handleSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		settings := p.Settings()
+		for key, values := range r.Form {
+			if err := settings.Set(key, values[len(values)-1]); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		p.Update(settings)
+	case http.MethodDelete:
+		p.Update(Settings{})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.Settings().Values())
+}
+
+// Control is a client of the control API of a running proxy
+type Control struct {
+	URL string
+}
+
+var controlClient = &http.Client{Timeout: 5 * time.Second}
+
+// Get returns the current settings
+func (c Control) Get() (map[string]string, error) {
+	return c.do(http.MethodGet, nil)
+}
+
+// Set changes the given settings
+func (c Control) Set(values url.Values) (map[string]string, error) {
+	return c.do(http.MethodPost, values)
+}
+
+// Clear restores the default settings
+func (c Control) Clear() (map[string]string, error) {
+	return c.do(http.MethodDelete, nil)
+}
+
+func (c Control) do(method string, values url.Values) (map[string]string, error) {
+	var body io.Reader
+	if values != nil {
+		body = strings.NewReader(values.Encode())
+	}
+	req, err := http.NewRequest(method, c.URL+"/settings", body)
+	if err != nil {
+		return nil, err
+	}
+	if values != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := controlClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the proxy: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(msg)))
+	}
+	settings := make(map[string]string)
+	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+		return nil, fmt.Errorf("invalid response from the proxy: %v", err)
+	}
+	return settings, nil
+}
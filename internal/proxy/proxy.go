@@ -0,0 +1,351 @@
+// Package proxy implements the fault-injecting TCP proxy dbin can put in
+// front of a database port
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings are the faults applied to proxied connections. They can be
+// changed while the proxy runs.
+type Settings struct {
+	Latency   time.Duration // Added before forwarding each chunk, in both directions
+	Bandwidth int64         // Bytes per second in each direction, 0 for unlimited
+	Reset     bool          // Reset open connections and every new one
+	Drop      bool          // Accept new connections but never forward them
+}
+
+// Keys lists the settings accepted by Set
+var Keys = []string{"latency", "bandwidth", "reset", "drop"}
+
+// Set changes a setting from its text form, e.g. latency=200ms, bandwidth=64KB
+func (s *Settings) Set(key, value string) error {
+	switch key {
+	case "latency":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid latency %q, expected a duration like 200ms", value)
+		}
+		s.Latency = d
+	case "bandwidth":
+		bw, err := parseBandwidth(value)
+		if err != nil {
+			return err
+		}
+		s.Bandwidth = bw
+	case "reset", "drop":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q, expected true or false", key, value)
+		}
+		if key == "reset" {
+			s.Reset = b
+		} else {
+			s.Drop = b
+		}
+	default:
+		return fmt.Errorf("unknown setting %q (available: %s)", key, strings.Join(Keys, ", "))
+	}
+	return nil
+}
+
+// Values returns the settings in the text form accepted by Set
+func (s Settings) Values() map[string]string {
+	bandwidth := "0"
+	if s.Bandwidth > 0 {
+		bandwidth = strconv.FormatInt(s.Bandwidth, 10)
+	}
+	return map[string]string{
+		"latency":   s.Latency.String(),
+		"bandwidth": bandwidth,
+		"reset":     strconv.FormatBool(s.Reset),
+		"drop":      strconv.FormatBool(s.Drop),
+	}
+}
+
+// parseBandwidth parses a rate in bytes per second with an optional KB, MB
+// or GB suffix
+func parseBandwidth(value string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSuffix(upper, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q, expected bytes per second like 65536 or 64KB", value)
+	}
+	return n * multiplier, nil
+}
+
+// Observer sees the data flowing through a proxied connection. The slices
+// passed to it are reused once the call returns.
+type Observer interface {
+	ClientData(data []byte)
+	ServerData(data []byte)
+	Close()
+}
+
+// Proxy forwards TCP connections to a target address, applying Settings
+type Proxy struct {
+	target   string
+	listener net.Listener
+
+	mu          sync.Mutex
+	settings    Settings
+	conns       map[net.Conn]struct{}
+	closed      bool
+	newObserver func() Observer
+}
+
+// Listen starts a proxy on addr forwarding to target
+func Listen(addr, target string) (*Proxy, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	p := &Proxy{
+		target:   target,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go p.serve()
+	return p, nil
+}
+
+// Addr returns the address the proxy listens on
+func (p *Proxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Port returns the port the proxy listens on
+func (p *Proxy) Port() string {
+	_, port, _ := net.SplitHostPort(p.Addr())
+	return port
+}
+
+// Target returns the address connections are forwarded to
+func (p *Proxy) Target() string {
+	return p.target
+}
+
+// Settings returns the current settings
+func (p *Proxy) Settings() Settings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.settings
+}
+
+// Update replaces the settings. Enabling Reset resets the open connections.
+func (p *Proxy) Update(settings Settings) {
+	p.mu.Lock()
+	p.settings = settings
+	var open []net.Conn
+	if settings.Reset {
+		for c := range p.conns {
+			open = append(open, c)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, c := range open {
+		reset(c)
+	}
+}
+
+// SetObserver makes new connections report their traffic to an observer
+// created by newObserver
+func (p *Proxy) SetObserver(newObserver func() Observer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.newObserver = newObserver
+}
+
+// Close stops accepting connections and closes the open ones
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	var open []net.Conn
+	for c := range p.conns {
+		open = append(open, c)
+	}
+	p.mu.Unlock()
+
+	err := p.listener.Close()
+	for _, c := range open {
+		c.Close()
+	}
+	return err
+}
+
+func (p *Proxy) serve() {
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+			if !closed {
+				log.Printf("Proxy: failed to accept connection: %v", err)
+			}
+			return
+		}
+		go p.handle(client)
+	}
+}
+
+func (p *Proxy) track(c net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.conns[c] = struct{}{}
+	return true
+}
+
+func (p *Proxy) untrack(c net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.conns, c)
+}
+
+func (p *Proxy) handle(client net.Conn) {
+	settings := p.Settings()
+	if settings.Reset {
+		reset(client)
+		return
+	}
+
+	if !p.track(client) {
+		client.Close()
+		return
+	}
+	defer p.untrack(client)
+
+	if settings.Drop {
+		// Hold the connection open without forwarding, like a black hole,
+		// until the client gives up or the proxy closes
+		io.Copy(io.Discard, client)
+		client.Close()
+		return
+	}
+
+	server, err := net.DialTimeout("tcp", p.target, 10*time.Second)
+	if err != nil {
+		log.Printf("Proxy: failed to connect to %s: %v", p.target, err)
+		client.Close()
+		return
+	}
+	if !p.track(server) {
+		client.Close()
+		server.Close()
+		return
+	}
+	defer p.untrack(server)
+
+	var fromClient, fromServer func([]byte)
+	p.mu.Lock()
+	newObserver := p.newObserver
+	p.mu.Unlock()
+	if newObserver != nil {
+		observer := newObserver()
+		defer observer.Close()
+		fromClient, fromServer = observer.ClientData, observer.ServerData
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		p.pipe(server, client, fromClient)
+		done <- struct{}{}
+	}()
+	go func() {
+		p.pipe(client, server, fromServer)
+		done <- struct{}{}
+	}()
+
+	// Once one side is done, tear the other down too
+	<-done
+	client.Close()
+	server.Close()
+	<-done
+}
+
+// pipe copies src to dst applying latency and bandwidth limits per chunk,
+// showing every chunk to observe first when set
+func (p *Proxy) pipe(dst, src net.Conn, observe func([]byte)) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if observe != nil {
+				observe(buf[:n])
+			}
+			settings := p.Settings()
+			if settings.Latency > 0 {
+				time.Sleep(settings.Latency)
+			}
+			if _, err := p.write(dst, buf[:n], settings.Bandwidth); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write sends data to dst no faster than bandwidth bytes per second
+func (p *Proxy) write(dst net.Conn, data []byte, bandwidth int64) (int, error) {
+	if bandwidth <= 0 {
+		return dst.Write(data)
+	}
+
+	// Send in slices of at most a tenth of a second worth of data
+	slice := int(bandwidth / 10)
+	if slice < 1 {
+		slice = 1
+	}
+	written := 0
+	for written < len(data) {
+		end := written + slice
+		if end > len(data) {
+			end = len(data)
+		}
+		start := time.Now()
+		n, err := dst.Write(data[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		expected := time.Duration(float64(n) / float64(bandwidth) * float64(time.Second))
+		if elapsed := time.Since(start); elapsed < expected {
+			time.Sleep(expected - elapsed)
+		}
+	}
+	return written, nil
+}
+
+// reset closes a connection with a TCP RST instead of a FIN
+func reset(c net.Conn) {
+	if tcp, ok := c.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	c.Close()
+}
@@ -0,0 +1,556 @@
+package trace
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Capability flags and status bits the decoder depends on
+const (
+	mysqlClientSSL             = 1 << 11
+	mysqlClientDeprecateEOF    = 1 << 24
+	mysqlClientQueryAttributes = 1 << 27
+	mysqlMoreResultsExist      = 0x0008
+	mysqlParameterCountGiven   = 0x08
+)
+
+// Commands that are traced or that change what follows
+const (
+	mysqlComInitDB      = 0x02
+	mysqlComQuery       = 0x03
+	mysqlComStmtPrepare = 0x16
+	mysqlComStmtExecute = 0x17
+	mysqlComSendLong    = 0x18
+	mysqlComStmtClose   = 0x19
+	mysqlComQuit        = 0x01
+)
+
+// States of the response to the command at the head of the queue
+const (
+	mysqlAwaitingResponse = iota
+	mysqlColumns
+	mysqlRows
+	mysqlPrepareMetadata
+)
+
+type mysqlStatement struct {
+	query  string
+	params int
+	types  []uint16
+}
+
+type mysqlCommand struct {
+	query
+	traced  bool
+	prepare *mysqlStatement
+}
+
+// mysqlDecoder follows the MySQL client/server protocol
+type mysqlDecoder struct {
+	conn
+
+	mu           sync.Mutex
+	client       []byte
+	server       []byte
+	handshaken   bool
+	disabled     bool
+	capabilities uint32
+	statements   map[uint32]*mysqlStatement
+	pending      []*mysqlCommand
+	state        int
+	remaining    int
+}
+
+func newMySQLDecoder(c conn) *mysqlDecoder {
+	return &mysqlDecoder{conn: c, statements: make(map[uint32]*mysqlStatement)}
+}
+
+func (d *mysqlDecoder) Close() {}
+
+func (d *mysqlDecoder) ClientData(data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.disabled {
+		return
+	}
+	d.client = append(d.client, data...)
+	if len(d.client) > maxBuffered {
+		d.fail()
+		return
+	}
+
+	for {
+		seq, payload, n := mysqlPacket(d.client)
+		if n == 0 {
+			return
+		}
+		d.clientPacket(seq, payload)
+		d.client = d.client[n:]
+		if d.disabled {
+			d.fail()
+			return
+		}
+	}
+}
+
+func (d *mysqlDecoder) clientPacket(seq byte, payload []byte) {
+	if !d.handshaken {
+		// The handshake response follows the server greeting
+		if seq == 1 && len(payload) >= 4 {
+			d.capabilities = binary.LittleEndian.Uint32(payload)
+			d.handshaken = true
+			if d.capabilities&mysqlClientSSL != 0 && len(payload) == 32 {
+				// An SSL request, the rest of the connection is encrypted
+				d.disabled = true
+			}
+		}
+		return
+	}
+	// Every command starts a new sequence, later packets belong to the
+	// authentication exchange
+	if seq != 0 || len(payload) == 0 {
+		return
+	}
+
+	cmd := &mysqlCommand{query: query{start: time.Now()}}
+	switch payload[0] {
+	case mysqlComQuit, mysqlComSendLong:
+		return
+	case mysqlComStmtClose:
+		if len(payload) >= 5 {
+			delete(d.statements, binary.LittleEndian.Uint32(payload[1:]))
+		}
+		return
+	case mysqlComQuery:
+		cmd.text, cmd.params = d.queryText(payload[1:])
+		cmd.traced = true
+	case mysqlComInitDB:
+		cmd.text = "USE " + string(payload[1:])
+		cmd.traced = true
+	case mysqlComStmtPrepare:
+		cmd.prepare = &mysqlStatement{query: string(payload[1:])}
+	case mysqlComStmtExecute:
+		if len(payload) < 10 {
+			return
+		}
+		cmd.traced = true
+		stmt, ok := d.statements[binary.LittleEndian.Uint32(payload[1:])]
+		if !ok {
+			cmd.text = "<unknown statement>"
+			break
+		}
+		cmd.text = stmt.query
+		cmd.params = d.executeParams(stmt, payload[5], payload[10:])
+	}
+	d.pending = append(d.pending, cmd)
+}
+
+// queryText returns the text of a COM_QUERY, along with its query
+// attributes when the client sends them
+func (d *mysqlDecoder) queryText(data []byte) (string, []string) {
+	if d.capabilities&mysqlClientQueryAttributes == 0 {
+		return string(data), nil
+	}
+	r := &mysqlReader{data: data}
+	count := int(r.lenenc())
+	r.lenenc() // Parameter sets, always 1
+	if r.failed {
+		return string(data), nil
+	}
+	if count == 0 {
+		return string(r.data), nil
+	}
+	params := mysqlBinaryParams(r, count, nil, true)
+	if r.failed {
+		return "<query with attributes>", nil
+	}
+	return string(r.data), params
+}
+
+// executeParams decodes the parameters of a COM_STMT_EXECUTE following its
+// iteration count
+func (d *mysqlDecoder) executeParams(stmt *mysqlStatement, flags byte, data []byte) []string {
+	r := &mysqlReader{data: data}
+	count := stmt.params
+	if d.capabilities&mysqlClientQueryAttributes != 0 && flags&mysqlParameterCountGiven != 0 {
+		count = int(r.lenenc())
+	}
+	if count == 0 || r.failed {
+		return nil
+	}
+	named := d.capabilities&mysqlClientQueryAttributes != 0
+	return mysqlBinaryParams(r, count, stmt, named)
+}
+
+// mysqlBinaryParams decodes the null bitmap, types and values of count
+// parameters. Types are remembered on stmt, since clients only send them
+// when they change.
+func mysqlBinaryParams(r *mysqlReader, count int, stmt *mysqlStatement, named bool) []string {
+	nulls := r.bytes((count + 7) / 8)
+	bound := r.bytes(1)
+	if r.failed {
+		return nil
+	}
+
+	var types []uint16
+	if stmt != nil {
+		types = stmt.types
+	}
+	if bound[0] == 1 {
+		types = make([]uint16, count)
+		for i := range types {
+			b := r.bytes(2)
+			if r.failed {
+				return nil
+			}
+			types[i] = binary.LittleEndian.Uint16(b)
+			if named {
+				r.lenencBytes()
+			}
+		}
+		if stmt != nil {
+			stmt.types = types
+		}
+	}
+	if len(types) < count {
+		return nil
+	}
+
+	params := make([]string, count)
+	for i := range params {
+		if nulls[i/8]&(1<<(i%8)) != 0 {
+			params[i] = "NULL"
+			continue
+		}
+		params[i] = r.binaryValue(types[i])
+		if r.failed {
+			return params[:i]
+		}
+	}
+	return params
+}
+
+func (d *mysqlDecoder) ServerData(data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.disabled {
+		return
+	}
+	d.server = append(d.server, data...)
+	if len(d.server) > maxBuffered {
+		d.fail()
+		return
+	}
+
+	for {
+		_, payload, n := mysqlPacket(d.server)
+		if n == 0 {
+			return
+		}
+		if len(d.pending) > 0 && len(payload) > 0 {
+			d.serverPacket(payload)
+		}
+		d.server = d.server[n:]
+	}
+}
+
+func (d *mysqlDecoder) serverPacket(payload []byte) {
+	cmd := d.pending[0]
+	deprecateEOF := d.capabilities&mysqlClientDeprecateEOF != 0
+
+	switch d.state {
+	case mysqlAwaitingResponse:
+		switch payload[0] {
+		case 0x00:
+			if cmd.prepare != nil {
+				d.prepared(cmd.prepare, payload, deprecateEOF)
+				return
+			}
+			r := &mysqlReader{data: payload[1:]}
+			affected := r.lenenc()
+			r.lenenc() // Last insert id
+			status := r.uint16()
+			cmd.rows += int64(affected)
+			cmd.hasRows = true
+			if status&mysqlMoreResultsExist == 0 {
+				d.finish()
+			}
+		case 0xff:
+			cmd.err = mysqlError(payload)
+			d.finish()
+		case 0xfb:
+			// LOAD DATA LOCAL: the client sends the file, then the server
+			// answers the query with OK or ERR
+			return
+		default:
+			r := &mysqlReader{data: payload}
+			d.remaining = int(r.lenenc())
+			if !deprecateEOF {
+				d.remaining++
+			}
+			d.state = mysqlColumns
+		}
+	case mysqlColumns:
+		d.remaining--
+		if d.remaining <= 0 {
+			d.state = mysqlRows
+		}
+	case mysqlRows:
+		switch {
+		case payload[0] == 0xfe && (len(payload) < 9 || deprecateEOF && len(payload) < 0xffffff):
+			var status uint16
+			r := &mysqlReader{data: payload[1:]}
+			if deprecateEOF {
+				r.lenenc()
+				r.lenenc()
+				status = r.uint16()
+			} else {
+				r.uint16() // Warnings
+				status = r.uint16()
+			}
+			cmd.hasRows = true
+			if status&mysqlMoreResultsExist != 0 {
+				d.state = mysqlAwaitingResponse
+			} else {
+				d.finish()
+			}
+		case payload[0] == 0xff:
+			cmd.err = mysqlError(payload)
+			d.finish()
+		default:
+			cmd.rows++
+		}
+	case mysqlPrepareMetadata:
+		d.remaining--
+		if d.remaining <= 0 {
+			d.finish()
+		}
+	}
+}
+
+// prepared records a statement from its COM_STMT_PREPARE_OK and skips the
+// parameter and column definitions that follow it
+func (d *mysqlDecoder) prepared(stmt *mysqlStatement, payload []byte, deprecateEOF bool) {
+	if len(payload) < 9 {
+		d.finish()
+		return
+	}
+	id := binary.LittleEndian.Uint32(payload[1:])
+	columns := int(binary.LittleEndian.Uint16(payload[5:]))
+	stmt.params = int(binary.LittleEndian.Uint16(payload[7:]))
+	d.statements[id] = stmt
+
+	d.remaining = stmt.params + columns
+	if !deprecateEOF {
+		if stmt.params > 0 {
+			d.remaining++
+		}
+		if columns > 0 {
+			d.remaining++
+		}
+	}
+	if d.remaining == 0 {
+		d.finish()
+		return
+	}
+	d.state = mysqlPrepareMetadata
+}
+
+// fail stops decoding a connection that is encrypted or does not speak the
+// protocol as expected
+func (d *mysqlDecoder) fail() {
+	d.disabled = true
+	d.client, d.server, d.pending = nil, nil, nil
+}
+
+// finish completes the command at the head of the queue
+func (d *mysqlDecoder) finish() {
+	cmd := d.pending[0]
+	d.pending = d.pending[1:]
+	d.state = mysqlAwaitingResponse
+	d.remaining = 0
+	if cmd.traced {
+		d.emit(&cmd.query)
+	}
+}
+
+// mysqlPacket splits the packet at the start of data, returning a zero
+// length while it is incomplete
+func mysqlPacket(data []byte) (byte, []byte, int) {
+	if len(data) < 4 {
+		return 0, nil, 0
+	}
+	length := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+	if len(data) < 4+length {
+		return 0, nil, 0
+	}
+	return data[3], data[4 : 4+length], 4 + length
+}
+
+// mysqlError returns the message of an ERR packet
+func mysqlError(payload []byte) string {
+	if len(payload) < 3 {
+		return "error"
+	}
+	code := binary.LittleEndian.Uint16(payload[1:])
+	message := payload[3:]
+	if len(message) >= 6 && message[0] == '#' {
+		return fmt.Sprintf("ERROR %d (%s): %s", code, message[1:6], message[6:])
+	}
+	return fmt.Sprintf("ERROR %d: %s", code, message)
+}
+
+// mysqlReader reads the encodings of the protocol, recording when data runs
+// out instead of returning errors
+type mysqlReader struct {
+	data   []byte
+	failed bool
+}
+
+func (r *mysqlReader) bytes(n int) []byte {
+	if r.failed || n < 0 || len(r.data) < n {
+		r.failed = true
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *mysqlReader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+// lenenc reads a length-encoded integer
+func (r *mysqlReader) lenenc() uint64 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	var size int
+	switch b[0] {
+	case 0xfc:
+		size = 2
+	case 0xfd:
+		size = 3
+	case 0xfe:
+		size = 8
+	default:
+		return uint64(b[0])
+	}
+	v := r.bytes(size)
+	var n uint64
+	for i, c := range v {
+		n |= uint64(c) << (8 * i)
+	}
+	return n
+}
+
+func (r *mysqlReader) lenencBytes() []byte {
+	return r.bytes(int(r.lenenc()))
+}
+
+// binaryValue formats a value of the binary protocol. The high byte of the
+// type flags unsigned integers.
+func (r *mysqlReader) binaryValue(typ uint16) string {
+	unsigned := typ&0x8000 != 0
+	integer := func(b []byte, bits int) string {
+		if b == nil {
+			return ""
+		}
+		var v uint64
+		for i, c := range b {
+			v |= uint64(c) << (8 * i)
+		}
+		if unsigned {
+			return strconv.FormatUint(v, 10)
+		}
+		shift := 64 - bits
+		return strconv.FormatInt(int64(v<<shift)>>shift, 10)
+	}
+
+	switch typ & 0xff {
+	case 0x01: // TINY
+		return integer(r.bytes(1), 8)
+	case 0x02, 0x0d: // SHORT, YEAR
+		return integer(r.bytes(2), 16)
+	case 0x03, 0x09: // LONG, INT24
+		return integer(r.bytes(4), 32)
+	case 0x08: // LONGLONG
+		return integer(r.bytes(8), 64)
+	case 0x04: // FLOAT
+		b := r.bytes(4)
+		if b == nil {
+			return ""
+		}
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), 'g', -1, 32)
+	case 0x05: // DOUBLE
+		b := r.bytes(8)
+		if b == nil {
+			return ""
+		}
+		return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)), 'g', -1, 64)
+	case 0x06: // NULL
+		return "NULL"
+	case 0x07, 0x0a, 0x0c: // TIMESTAMP, DATE, DATETIME
+		return mysqlDateTime(r.bytes(int(r.lenenc())))
+	case 0x0b: // TIME
+		return mysqlTime(r.bytes(int(r.lenenc())))
+	case 0xfc, 0xf9, 0xfa, 0xfb: // BLOB types
+		b := r.lenencBytes()
+		if !isPrintable(b) {
+			return "0x" + hex.EncodeToString(b)
+		}
+		return string(b)
+	}
+	// Strings, decimals, JSON and the rest are sent as text
+	return string(r.lenencBytes())
+}
+
+func mysqlDateTime(b []byte) string {
+	if len(b) < 4 {
+		return "0000-00-00"
+	}
+	s := fmt.Sprintf("%04d-%02d-%02d", binary.LittleEndian.Uint16(b), b[2], b[3])
+	if len(b) >= 7 {
+		s += fmt.Sprintf(" %02d:%02d:%02d", b[4], b[5], b[6])
+	}
+	if len(b) >= 11 {
+		s += fmt.Sprintf(".%06d", binary.LittleEndian.Uint32(b[7:]))
+	}
+	return s
+}
+
+func mysqlTime(b []byte) string {
+	if len(b) < 8 {
+		return "00:00:00"
+	}
+	sign := ""
+	if b[0] == 1 {
+		sign = "-"
+	}
+	hours := binary.LittleEndian.Uint32(b[1:])*24 + uint32(b[5])
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, b[6], b[7])
+	if len(b) >= 12 {
+		s += fmt.Sprintf(".%06d", binary.LittleEndian.Uint32(b[8:]))
+	}
+	return s
+}
+
+func isPrintable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package trace
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func mysqlPkt(seq byte, payload ...[]byte) []byte {
+	body := concat(payload...)
+	return append([]byte{byte(len(body)), byte(len(body) >> 8), byte(len(body) >> 16), seq}, body...)
+}
+
+// mysqlHandshake is a server greeting and a handshake response with the
+// given capabilities, followed by the OK of the authentication
+func mysqlHandshake(capabilities uint32) []chunk {
+	response := make([]byte, 40)
+	binary.LittleEndian.PutUint32(response, capabilities)
+	return []chunk{
+		server(mysqlPkt(0, []byte("\x0a8.0.36\x00"))),
+		client(mysqlPkt(1, response)),
+		server(mysqlPkt(2, mysqlOK(0, 0))),
+	}
+}
+
+func mysqlOK(affected byte, status uint16) []byte {
+	return []byte{0x00, affected, 0, byte(status), byte(status >> 8), 0, 0}
+}
+
+func mysqlQuery(text string) []byte {
+	return mysqlPkt(0, []byte{mysqlComQuery}, []byte(text))
+}
+
+// mysqlResultSet is a result set of one column and rows rows, ended the way
+// the capabilities ask for
+func mysqlResultSet(deprecateEOF bool, rows int) []byte {
+	seq := byte(1)
+	next := func(payload ...[]byte) []byte {
+		p := mysqlPkt(seq, payload...)
+		seq++
+		return p
+	}
+	eof := []byte{0xfe, 0, 0, 0x02, 0}
+	out := concat(next([]byte{0x01}), next([]byte("\x03def\x00\x00\x00\x011\x00\x0c\x3f\x00\x01\x00\x00\x00\x08\x81\x00\x00\x00\x00")))
+	if !deprecateEOF {
+		out = append(out, next(eof)...)
+	}
+	for i := 0; i < rows; i++ {
+		out = append(out, next([]byte("\x011"))...)
+	}
+	if deprecateEOF {
+		return append(out, next([]byte{0xfe, 0, 0, 0x02, 0, 0, 0})...)
+	}
+	return append(out, next(eof)...)
+}
+
+func TestMySQLDecoder(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities uint32
+		chunks       []chunk
+		want         []wantEvent
+	}{
+		{
+			name: "result set",
+			chunks: []chunk{
+				client(mysqlQuery("SELECT 1")),
+				server(mysqlResultSet(false, 2)),
+			},
+			want: []wantEvent{{query: "SELECT 1", rows: 2}},
+		},
+		{
+			name:         "result set without EOF",
+			capabilities: mysqlClientDeprecateEOF,
+			chunks: []chunk{
+				client(mysqlQuery("SELECT 1")),
+				server(mysqlResultSet(true, 3)),
+			},
+			want: []wantEvent{{query: "SELECT 1", rows: 3}},
+		},
+		{
+			name: "affected rows and error",
+			chunks: []chunk{
+				client(mysqlQuery("DELETE FROM t")),
+				server(mysqlPkt(1, mysqlOK(4, 0x02))),
+				client(mysqlQuery("SELECT nope")),
+				server(mysqlPkt(1, []byte{0xff, 0x16, 0x04}, []byte("#42S22Unknown column 'nope'"))),
+			},
+			want: []wantEvent{
+				{query: "DELETE FROM t", rows: 4},
+				{query: "SELECT nope", rows: -1, err: true},
+			},
+		},
+		{
+			name: "load data local",
+			chunks: []chunk{
+				client(mysqlQuery("LOAD DATA LOCAL INFILE 'rows.csv' INTO TABLE t")),
+				server(mysqlPkt(1, []byte{0xfb}, []byte("rows.csv"))),
+				client(mysqlPkt(2, []byte("1,a\n2,b\n")), mysqlPkt(3)),
+				server(mysqlPkt(4, mysqlOK(2, 0x02))),
+				client(mysqlQuery("SELECT 1")),
+				server(mysqlResultSet(false, 1)),
+			},
+			want: []wantEvent{
+				{query: "LOAD DATA LOCAL INFILE 'rows.csv' INTO TABLE t", rows: 2},
+				{query: "SELECT 1", rows: 1},
+			},
+		},
+		{
+			name: "load data local refused",
+			chunks: []chunk{
+				client(mysqlQuery("LOAD DATA LOCAL INFILE 'missing.csv' INTO TABLE t")),
+				server(mysqlPkt(1, []byte{0xfb}, []byte("missing.csv"))),
+				client(mysqlPkt(2)),
+				server(mysqlPkt(3, []byte{0xff, 0x1d, 0x00}, []byte("#HY000File not found"))),
+			},
+			want: []wantEvent{{query: "LOAD DATA LOCAL INFILE 'missing.csv' INTO TABLE t", rows: -1, err: true}},
+		},
+		{
+			name: "prepared statement",
+			chunks: []chunk{
+				client(mysqlPkt(0, []byte{mysqlComStmtPrepare}, []byte("SELECT ?"))),
+				server(mysqlPkt(1, []byte{0x00, 7, 0, 0, 0, 1, 0, 1, 0, 0, 0, 0}), mysqlPkt(2, []byte("param")), mysqlPkt(3, []byte{0xfe, 0, 0, 2, 0}), mysqlPkt(4, []byte("col")), mysqlPkt(5, []byte{0xfe, 0, 0, 2, 0})),
+				// Execute statement 7 with one LONGLONG parameter bound to 42
+				client(mysqlPkt(0, []byte{mysqlComStmtExecute, 7, 0, 0, 0, 0, 1, 0, 0, 0, 0x00, 0x01, 0x08, 0x00}, []byte{42, 0, 0, 0, 0, 0, 0, 0})),
+				server(mysqlResultSet(false, 1)),
+			},
+			want: []wantEvent{{query: "SELECT ?", params: []string{"42"}, rows: 1}},
+		},
+		{
+			name: "truncated packets",
+			chunks: []chunk{
+				client(mysqlQuery("UPDATE t SET a = 1")),
+				server(mysqlPkt(1, []byte{0x00})),
+				client(mysqlPkt(0, []byte{mysqlComStmtExecute, 1})),
+			},
+			want: []wantEvent{{query: "UPDATE t SET a = 1", rows: 0}},
+		},
+		{
+			name:         "SSL request",
+			capabilities: mysqlClientSSL,
+			chunks: []chunk{
+				client(mysqlQuery("SELECT 1")),
+				server(mysqlResultSet(false, 1)),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := mysqlHandshake(tt.capabilities)
+			if tt.capabilities&mysqlClientSSL != 0 {
+				// SSL requests are the 32 bytes before the handshake response
+				request := make([]byte, 32)
+				binary.LittleEndian.PutUint32(request, tt.capabilities)
+				chunks = []chunk{server(mysqlPkt(0, []byte("\x0a8.0.36\x00"))), client(mysqlPkt(1, request))}
+			}
+			checkEvents(t, "mysql", append(chunks, tt.chunks...), tt.want)
+		})
+	}
+}
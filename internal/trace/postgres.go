@@ -0,0 +1,338 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Codes of the untyped messages a client can open a connection with
+const (
+	pgSSLRequest    = 80877103
+	pgGSSENCRequest = 80877104
+	pgCancelRequest = 80877102
+)
+
+// pgQuery is a simple query, an execution of a bound portal or a Sync in
+// the queue of what the server answers in order
+type pgQuery struct {
+	query
+	simple bool
+	sync   bool
+}
+
+// postgresDecoder follows the PostgreSQL frontend/backend protocol
+type postgresDecoder struct {
+	conn
+
+	mu          sync.Mutex
+	client      []byte
+	server      []byte
+	started     bool
+	awaitingSSL int
+	disabled    bool
+	statements  map[string]string
+	portals     map[string]query
+	pending     []*pgQuery
+}
+
+func newPostgresDecoder(c conn) *postgresDecoder {
+	return &postgresDecoder{
+		conn:       c,
+		statements: make(map[string]string),
+		portals:    make(map[string]query),
+	}
+}
+
+func (d *postgresDecoder) Close() {}
+
+func (d *postgresDecoder) ClientData(data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.disabled {
+		return
+	}
+	d.client = append(d.client, data...)
+	if len(d.client) > maxBuffered {
+		d.fail()
+		return
+	}
+
+	for {
+		if !d.started {
+			// Startup messages have no type byte
+			if len(d.client) < 8 {
+				return
+			}
+			length := int(binary.BigEndian.Uint32(d.client))
+			if length < 8 || length > maxBuffered {
+				// Not PostgreSQL, or a length that would never be consumed
+				d.fail()
+				return
+			}
+			if len(d.client) < length {
+				return
+			}
+			switch binary.BigEndian.Uint32(d.client[4:]) {
+			case pgSSLRequest, pgGSSENCRequest:
+				d.awaitingSSL++
+			case pgCancelRequest:
+			default:
+				d.started = true
+			}
+			d.client = d.client[length:]
+			continue
+		}
+
+		typ, payload, n, ok := pgMessage(d.client)
+		if !ok {
+			d.fail()
+			return
+		}
+		if n == 0 {
+			return
+		}
+		d.clientMessage(typ, payload)
+		d.client = d.client[n:]
+	}
+}
+
+func (d *postgresDecoder) clientMessage(typ byte, payload []byte) {
+	switch typ {
+	case 'Q':
+		text, _ := pgString(payload)
+		d.pending = append(d.pending, &pgQuery{query: query{text: text, start: time.Now()}, simple: true})
+	case 'P':
+		name, rest := pgString(payload)
+		text, _ := pgString(rest)
+		d.statements[name] = text
+	case 'B':
+		portal, rest := pgString(payload)
+		statement, rest := pgString(rest)
+		d.portals[portal] = query{text: d.statements[statement], params: pgParams(rest)}
+	case 'E':
+		portal, _ := pgString(payload)
+		q, ok := d.portals[portal]
+		if !ok {
+			q.text = "<unknown portal>"
+		}
+		q.start = time.Now()
+		d.pending = append(d.pending, &pgQuery{query: q})
+	case 'S':
+		d.pending = append(d.pending, &pgQuery{sync: true})
+	}
+}
+
+func (d *postgresDecoder) ServerData(data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.disabled {
+		return
+	}
+	d.server = append(d.server, data...)
+	if len(d.server) > maxBuffered {
+		d.fail()
+		return
+	}
+
+	for {
+		if d.awaitingSSL > 0 {
+			// Encryption requests are answered with a single byte
+			if len(d.server) == 0 {
+				return
+			}
+			if d.server[0] != 'N' {
+				// The rest of the connection is encrypted
+				d.fail()
+				return
+			}
+			d.awaitingSSL--
+			d.server = d.server[1:]
+			continue
+		}
+
+		typ, payload, n, ok := pgMessage(d.server)
+		if !ok {
+			d.fail()
+			return
+		}
+		if n == 0 {
+			return
+		}
+		d.serverMessage(typ, payload)
+		d.server = d.server[n:]
+	}
+}
+
+func (d *postgresDecoder) serverMessage(typ byte, payload []byte) {
+	if len(d.pending) == 0 {
+		return
+	}
+	head := d.pending[0]
+
+	switch typ {
+	case 'C', 'I':
+		var rows int64
+		var hasRows bool
+		if typ == 'C' {
+			tag, _ := pgString(payload)
+			rows, hasRows = pgRows(tag)
+		}
+		if head.simple {
+			// A simple query may hold several statements, completed by
+			// ReadyForQuery
+			head.rows += rows
+			head.hasRows = head.hasRows || hasRows
+		} else if !head.sync {
+			head.rows, head.hasRows = rows, hasRows
+			d.pending = d.pending[1:]
+			d.emit(&head.query)
+		}
+	case 'E':
+		if !head.sync {
+			head.err = pgError(payload)
+		}
+		if !head.simple && !head.sync {
+			// The server skips everything else up to the next Sync
+			d.pending = d.pending[1:]
+			d.emit(&head.query)
+		}
+	case 'Z':
+		for len(d.pending) > 0 {
+			q := d.pending[0]
+			d.pending = d.pending[1:]
+			if q.simple {
+				d.emit(&q.query)
+				return
+			}
+			if q.sync {
+				return
+			}
+		}
+	}
+}
+
+// fail stops decoding a connection that is encrypted or does not speak the
+// protocol as expected
+func (d *postgresDecoder) fail() {
+	d.disabled = true
+	d.client, d.server, d.pending = nil, nil, nil
+}
+
+// pgMessage splits the typed message at the start of data. It returns a zero
+// length while the message is incomplete and false when its length is
+// invalid.
+func pgMessage(data []byte) (byte, []byte, int, bool) {
+	if len(data) < 5 {
+		return 0, nil, 0, true
+	}
+	length := int(binary.BigEndian.Uint32(data[1:]))
+	if length < 4 || length > maxBuffered {
+		return 0, nil, 0, false
+	}
+	if len(data) < 1+length {
+		return 0, nil, 0, true
+	}
+	return data[0], data[5 : 1+length], 1 + length, true
+}
+
+// pgString reads a null-terminated string
+func pgString(data []byte) (string, []byte) {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return string(data), nil
+	}
+	return string(data[:i]), data[i+1:]
+}
+
+// pgParams decodes the parameters of a Bind message following its portal
+// and statement names
+func pgParams(data []byte) []string {
+	if len(data) < 2 {
+		return nil
+	}
+	formats := make([]uint16, binary.BigEndian.Uint16(data))
+	data = data[2:]
+	for i := range formats {
+		if len(data) < 2 {
+			return nil
+		}
+		formats[i] = binary.BigEndian.Uint16(data)
+		data = data[2:]
+	}
+	if len(data) < 2 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+
+	params := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < 4 {
+			return params
+		}
+		length := int32(binary.BigEndian.Uint32(data))
+		data = data[4:]
+		if length < 0 {
+			params = append(params, "NULL")
+			continue
+		}
+		if len(data) < int(length) {
+			return params
+		}
+		value := data[:length]
+		data = data[length:]
+
+		var format uint16
+		switch len(formats) {
+		case 0:
+		case 1:
+			format = formats[0]
+		default:
+			if i < len(formats) {
+				format = formats[i]
+			}
+		}
+		if format == 0 {
+			params = append(params, string(value))
+		} else {
+			params = append(params, "0x"+hex.EncodeToString(value))
+		}
+	}
+	return params
+}
+
+// pgRows extracts the row count of a command tag such as "SELECT 5" or
+// "INSERT 0 1"
+func pgRows(tag string) (int64, bool) {
+	fields := strings.Fields(tag)
+	if len(fields) < 2 {
+		return 0, false
+	}
+	rows, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	return rows, err == nil
+}
+
+// pgError returns the message of an ErrorResponse
+func pgError(payload []byte) string {
+	var severity, message string
+	for len(payload) > 0 && payload[0] != 0 {
+		code := payload[0]
+		var value string
+		value, payload = pgString(payload[1:])
+		switch code {
+		case 'S':
+			severity = value
+		case 'M':
+			message = value
+		}
+	}
+	if severity != "" {
+		return severity + ": " + message
+	}
+	return message
+}
@@ -0,0 +1,161 @@
+package trace
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func pgMsg(typ byte, payload ...[]byte) []byte {
+	body := concat(payload...)
+	msg := []byte{typ, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(msg[1:], uint32(4+len(body)))
+	return append(msg, body...)
+}
+
+func pgStartupMsg(code uint32, length int) []byte {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint32(msg, uint32(length))
+	binary.BigEndian.PutUint32(msg[4:], code)
+	return msg
+}
+
+func pgStartup() []byte {
+	params := []byte("user\x00postgres\x00\x00")
+	msg := pgStartupMsg(196608, 8+len(params))
+	return append(msg, params...)
+}
+
+func cstr(s string) []byte { return append([]byte(s), 0) }
+
+func pgBind(statement string, params ...string) []byte {
+	b := concat(cstr(""), cstr(statement), []byte{0, 0}, []byte{0, byte(len(params))})
+	for _, p := range params {
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(p)))
+		b = append(append(b, length...), p...)
+	}
+	return pgMsg('B', b, []byte{0, 0})
+}
+
+var pgReady = pgMsg('Z', []byte("I"))
+
+func TestPostgresDecoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []chunk
+		want   []wantEvent
+	}{
+		{
+			name: "simple query",
+			chunks: []chunk{
+				client(pgStartup()),
+				server(pgMsg('R', []byte{0, 0, 0, 0}), pgReady),
+				client(pgMsg('Q', cstr("SELECT 1"))),
+				server(pgMsg('T', []byte{0, 0}), pgMsg('D', []byte{0, 0}), pgMsg('C', cstr("SELECT 1")), pgReady),
+			},
+			want: []wantEvent{{query: "SELECT 1", rows: 1}},
+		},
+		{
+			name: "several statements in a simple query",
+			chunks: []chunk{
+				client(pgStartup()),
+				server(pgReady),
+				client(pgMsg('Q', cstr("INSERT INTO t VALUES (1); INSERT INTO t VALUES (2)"))),
+				server(pgMsg('C', cstr("INSERT 0 1")), pgMsg('C', cstr("INSERT 0 1")), pgReady),
+			},
+			want: []wantEvent{{query: "INSERT INTO t VALUES (1); INSERT INTO t VALUES (2)", rows: 2}},
+		},
+		{
+			name: "extended query with parameters",
+			chunks: []chunk{
+				client(pgStartup()),
+				server(pgReady),
+				client(pgMsg('P', cstr("s1"), cstr("SELECT $1::int"), []byte{0, 0}), pgBind("s1", "42"), pgMsg('E', cstr(""), []byte{0, 0, 0, 0}), pgMsg('S')),
+				server(pgMsg('1'), pgMsg('2'), pgMsg('D', []byte{0, 0}), pgMsg('C', cstr("SELECT 1")), pgReady),
+			},
+			want: []wantEvent{{query: "SELECT $1::int", params: []string{"42"}, rows: 1}},
+		},
+		{
+			name: "error",
+			chunks: []chunk{
+				client(pgStartup()),
+				server(pgReady),
+				client(pgMsg('Q', cstr("SELECT nope"))),
+				server(pgMsg('E', []byte("SERROR\x00Mcolumn \"nope\" does not exist\x00\x00")), pgReady),
+			},
+			want: []wantEvent{{query: "SELECT nope", rows: -1, err: true}},
+		},
+		{
+			name: "SSL refused",
+			chunks: []chunk{
+				client(pgStartupMsg(pgSSLRequest, 8)),
+				server([]byte("N")),
+				client(pgStartup()),
+				server(pgReady),
+				client(pgMsg('Q', cstr("SELECT 1"))),
+				server(pgMsg('C', cstr("SELECT 1")), pgReady),
+			},
+			want: []wantEvent{{query: "SELECT 1", rows: 1}},
+		},
+		{
+			name: "SSL accepted",
+			chunks: []chunk{
+				client(pgStartupMsg(pgSSLRequest, 8)),
+				server([]byte("S")),
+				client(pgMsg('Q', cstr("SELECT 1"))),
+			},
+		},
+		{
+			name: "zero startup length",
+			chunks: []chunk{
+				client(pgStartupMsg(pgSSLRequest, 0)),
+				client(pgMsg('Q', cstr("SELECT 1"))),
+			},
+		},
+		{
+			name: "short startup length",
+			chunks: []chunk{
+				client(pgStartupMsg(pgCancelRequest, 4), pgStartup()),
+			},
+		},
+		{
+			name: "huge startup length",
+			chunks: []chunk{
+				client(pgStartupMsg(196608, maxBuffered+1)),
+			},
+		},
+		{
+			name: "message length below 4",
+			chunks: []chunk{
+				client(pgStartup()),
+				client([]byte{'Q', 0, 0, 0, 2}, pgMsg('Q', cstr("SELECT 1"))),
+				server(pgMsg('C', cstr("SELECT 1")), pgReady),
+			},
+		},
+		{
+			name: "server message length below 4",
+			chunks: []chunk{
+				client(pgStartup()),
+				client(pgMsg('Q', cstr("SELECT 1"))),
+				server([]byte{'C', 0, 0, 0, 0}, pgReady),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkEvents(t, "postgres", tt.chunks, tt.want)
+		})
+	}
+}
+
+func TestPostgresDecoderBoundsBuffer(t *testing.T) {
+	d := newPostgresDecoder(conn{})
+	d.ClientData(pgStartup())
+	header := []byte{'Q', 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[1:], maxBuffered)
+	d.ClientData(header)
+	d.ClientData(make([]byte, maxBuffered))
+	if !d.disabled || d.client != nil {
+		t.Fatalf("decoder kept %d bytes of an oversized message", len(d.client))
+	}
+}
@@ -0,0 +1,282 @@
+package trace
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxArgLength is how much of each argument of a Redis command is logged
+const maxArgLength = 100
+
+// respValue is a decoded RESP2 or RESP3 value
+type respValue struct {
+	kind  byte
+	text  string
+	items []respValue
+	null  bool
+}
+
+// maxRESPDepth is how deeply aggregates may nest before a connection is not
+// considered RESP anymore
+const maxRESPDepth = 32
+
+// respDecoder follows the Redis serialization protocol
+type respDecoder struct {
+	conn
+
+	mu      sync.Mutex
+	client  respParser
+	server  respParser
+	broken  bool
+	pending []*query
+}
+
+func newRESPDecoder(c conn) *respDecoder {
+	return &respDecoder{conn: c}
+}
+
+func (d *respDecoder) Close() {}
+
+func (d *respDecoder) ClientData(data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.broken {
+		return
+	}
+	d.client.buf = append(d.client.buf, data...)
+	if len(d.client.buf) > maxBuffered {
+		d.fail()
+		return
+	}
+
+	for len(d.client.buf) > 0 {
+		var args []string
+		if d.client.idle() && d.client.buf[0] != '*' {
+			// Inline command
+			i := bytes.IndexByte(d.client.buf, '\n')
+			if i < 0 {
+				return
+			}
+			args = strings.Fields(string(d.client.buf[:i]))
+			d.client.buf = d.client.buf[i+1:]
+		} else {
+			v, done, ok := d.client.next()
+			if !ok {
+				d.fail()
+				return
+			}
+			if !done {
+				return
+			}
+			for _, item := range v.items {
+				args = append(args, item.text)
+			}
+		}
+		if len(args) > 0 {
+			d.pending = append(d.pending, &query{text: respCommand(args), start: time.Now()})
+		}
+	}
+}
+
+func (d *respDecoder) ServerData(data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.broken {
+		return
+	}
+	d.server.buf = append(d.server.buf, data...)
+	if len(d.server.buf) > maxBuffered {
+		d.fail()
+		return
+	}
+
+	for len(d.server.buf) > 0 {
+		v, done, ok := d.server.next()
+		if !ok {
+			d.fail()
+			return
+		}
+		if !done {
+			return
+		}
+
+		// Pushes such as pub/sub messages answer no command
+		if v.kind == '>' || len(d.pending) == 0 {
+			continue
+		}
+		q := d.pending[0]
+		d.pending = d.pending[1:]
+		switch v.kind {
+		case '-', '!':
+			q.err = v.text
+		case '*', '~', '%':
+			q.rows, q.hasRows = int64(len(v.items)), !v.null
+			if v.kind == '%' {
+				q.rows /= 2
+			}
+		case '$', '=':
+			q.hasRows = true
+			if !v.null {
+				q.rows = 1
+			}
+		}
+		d.emit(q)
+	}
+}
+
+// fail stops decoding a connection that does not speak RESP as expected
+func (d *respDecoder) fail() {
+	d.broken = true
+	d.client, d.server, d.pending = respParser{}, respParser{}, nil
+}
+
+// respParser decodes the values of one direction of a connection as data
+// arrives. It keeps the aggregates being read, so that each chunk only
+// parses what is new.
+type respParser struct {
+	buf   []byte      // Data not parsed yet
+	stack []respFrame // Aggregates being read, the innermost last
+	size  int         // Bytes parsed of the value being read
+}
+
+// respFrame is an aggregate missing some of its values
+type respFrame struct {
+	value     respValue
+	remaining int
+}
+
+// idle reports whether no value is partly read
+func (p *respParser) idle() bool {
+	return len(p.stack) == 0
+}
+
+// next returns the next complete value. done is false while the value is
+// incomplete, and ok is false when the data is not RESP.
+func (p *respParser) next() (v respValue, done bool, ok bool) {
+	for {
+		v, count, n, ok := readRESP(p.buf)
+		if !ok {
+			return v, false, false
+		}
+		if n == 0 {
+			return v, false, true
+		}
+		p.buf = p.buf[n:]
+		p.size += n
+		if p.size > maxBuffered {
+			return v, false, false
+		}
+
+		if count > 0 {
+			if len(p.stack) >= maxRESPDepth {
+				return v, false, false
+			}
+			p.stack = append(p.stack, respFrame{value: v, remaining: count})
+			continue
+		}
+
+		// Add the value to the aggregates it completes
+		for len(p.stack) > 0 {
+			top := &p.stack[len(p.stack)-1]
+			if top.value.kind == '|' && top.remaining == 1 {
+				// Attributes precede the value they describe
+				p.stack = p.stack[:len(p.stack)-1]
+				continue
+			}
+			top.value.items = append(top.value.items, v)
+			top.remaining--
+			if top.remaining > 0 {
+				break
+			}
+			v = top.value
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+		if p.idle() {
+			p.size = 0
+			return v, true, true
+		}
+	}
+}
+
+// readRESP reads a simple value, a bulk value or the header of an aggregate
+// at the start of data. count is the number of values that follow an
+// aggregate. It returns a zero length while the value is incomplete and
+// false when data is not RESP.
+func readRESP(data []byte) (v respValue, count int, n int, ok bool) {
+	if len(data) == 0 {
+		return v, 0, 0, true
+	}
+	end := bytes.Index(data, []byte("\r\n"))
+	if end < 0 {
+		return v, 0, 0, true
+	}
+	if end == 0 {
+		return v, 0, 0, false
+	}
+	v = respValue{kind: data[0], text: string(data[1:end])}
+	n = end + 2
+
+	switch v.kind {
+	case '+', '-', ':', ',', '(', '#', '_':
+		v.null = v.kind == '_'
+		return v, 0, n, true
+	case '$', '!', '=':
+		length, err := strconv.Atoi(v.text)
+		if err != nil || length > maxBuffered {
+			return v, 0, 0, false
+		}
+		if length < 0 {
+			v.null, v.text = true, ""
+			return v, 0, n, true
+		}
+		if len(data) < n+length+2 {
+			return v, 0, 0, true
+		}
+		v.text = string(data[n : n+length])
+		return v, 0, n + length + 2, true
+	case '*', '~', '%', '>', '|':
+		count, err := strconv.Atoi(v.text)
+		if err != nil || count > maxBuffered {
+			return v, 0, 0, false
+		}
+		if count < 0 {
+			v.null = true
+			return v, 0, n, true
+		}
+		if v.kind == '%' || v.kind == '|' {
+			count *= 2
+		}
+		if v.kind == '|' {
+			count++ // The value the attributes describe
+		}
+		return v, count, n, true
+	}
+	return v, 0, 0, false
+}
+
+// respCommand formats a command for the log, hiding passwords and
+// shortening long arguments
+func respCommand(args []string) string {
+	name := strings.ToUpper(args[0])
+	parts := []string{name}
+	for i, arg := range args[1:] {
+		switch {
+		case name == "AUTH":
+			arg = "***"
+		case name == "HELLO" && i > 1 && strings.EqualFold(args[i-1], "AUTH"):
+			// HELLO <version> AUTH <username> <password>
+			arg = "***"
+		}
+		if len(arg) > maxArgLength {
+			arg = arg[:maxArgLength] + "..."
+		}
+		if arg == "" || strings.ContainsAny(arg, " \t\r\n\"") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,172 @@
+package trace
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRESPDecoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []chunk
+		want   []wantEvent
+	}{
+		{
+			name: "bulk reply",
+			chunks: []chunk{
+				client([]byte("*2\r\n$3\r\nGET\r\n$1\r\nk\r\n")),
+				server([]byte("$5\r\nvalue\r\n")),
+			},
+			want: []wantEvent{{query: "GET k", rows: 1}},
+		},
+		{
+			name: "null bulk reply",
+			chunks: []chunk{
+				client([]byte("*2\r\n$3\r\nGET\r\n$7\r\nmissing\r\n")),
+				server([]byte("$-1\r\n")),
+			},
+			want: []wantEvent{{query: "GET missing", rows: 0}},
+		},
+		{
+			name: "pipelined commands",
+			chunks: []chunk{
+				client([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\nhello\r\n*2\r\n$6\r\nLRANGE\r\n$1\r\nl\r\n")),
+				server([]byte("+OK\r\n*2\r\n$1\r\na\r\n$1\r\nb\r\n")),
+			},
+			want: []wantEvent{
+				{query: "SET k hello", rows: -1},
+				{query: "LRANGE l", rows: 2},
+			},
+		},
+		{
+			name: "inline command and error",
+			chunks: []chunk{
+				client([]byte("PING\r\nNOPE\r\n")),
+				server([]byte("+PONG\r\n-ERR unknown command 'NOPE'\r\n")),
+			},
+			want: []wantEvent{
+				{query: "PING", rows: -1},
+				{query: "NOPE", rows: -1, err: true},
+			},
+		},
+		{
+			name: "password hidden",
+			chunks: []chunk{
+				client([]byte("*2\r\n$4\r\nAUTH\r\n$6\r\nsecret\r\n")),
+				server([]byte("+OK\r\n")),
+			},
+			want: []wantEvent{{query: "AUTH ***", rows: -1}},
+		},
+		{
+			name: "RESP3 map and push",
+			chunks: []chunk{
+				client([]byte("*1\r\n$5\r\nHELLO\r\n")),
+				server([]byte(">2\r\n$7\r\nmessage\r\n$1\r\nx\r\n%1\r\n+server\r\n+redis\r\n")),
+			},
+			want: []wantEvent{{query: "HELLO", rows: 1}},
+		},
+		{
+			name: "nested arrays and attributes",
+			chunks: []chunk{
+				client([]byte("*2\r\n$4\r\nEXEC\r\n$1\r\nx\r\n")),
+				server([]byte("|1\r\n+ttl\r\n:3\r\n*2\r\n*2\r\n:1\r\n:2\r\n*0\r\n")),
+			},
+			want: []wantEvent{{query: "EXEC x", rows: 2}},
+		},
+		{
+			name: "too deeply nested",
+			chunks: []chunk{
+				client([]byte("*1\r\n$4\r\nPING\r\n")),
+				server([]byte(strings.Repeat("*1\r\n", maxRESPDepth+1) + "+PONG\r\n")),
+			},
+		},
+		{
+			name: "invalid bulk length",
+			chunks: []chunk{
+				client([]byte("*1\r\n$x\r\nPING\r\n")),
+				server([]byte("+PONG\r\n")),
+			},
+		},
+		{
+			name: "huge bulk length",
+			chunks: []chunk{
+				client([]byte("*1\r\n$99999999999\r\nPING\r\n")),
+				server([]byte("+PONG\r\n")),
+			},
+		},
+		{
+			name: "huge array count",
+			chunks: []chunk{
+				client([]byte("*2\r\n$3\r\nGET\r\n$1\r\nk\r\n")),
+				server([]byte("*99999999999\r\n")),
+			},
+		},
+		{
+			name: "unknown reply type",
+			chunks: []chunk{
+				client([]byte("*1\r\n$4\r\nPING\r\n")),
+				server([]byte("?\r\n")),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkEvents(t, "redis", tt.chunks, tt.want)
+		})
+	}
+}
+
+func TestRESPDecoderBoundsBuffer(t *testing.T) {
+	d := newRESPDecoder(conn{})
+	// An inline command that never ends
+	line := []byte(strings.Repeat("x", 1<<20))
+	for i := 0; i <= maxBuffered>>20; i++ {
+		d.ClientData(line)
+	}
+	if !d.broken || d.client.buf != nil {
+		t.Fatalf("decoder kept %d bytes of an endless command", len(d.client.buf))
+	}
+}
+
+func TestRESPParserResumes(t *testing.T) {
+	var p respParser
+	items := 1000
+	data := []byte("*" + strconv.Itoa(items) + "\r\n" + strings.Repeat("$1\r\nx\r\n", items))
+	// Feed the array a byte at a time, as a slow connection would
+	for i, b := range data {
+		p.buf = append(p.buf, b)
+		v, done, ok := p.next()
+		if !ok {
+			t.Fatalf("byte %d: not RESP", i)
+		}
+		if done != (i == len(data)-1) {
+			t.Fatalf("byte %d: done = %v", i, done)
+		}
+		if done && len(v.items) != items {
+			t.Fatalf("got %d items, want %d", len(v.items), items)
+		}
+		// Only the incomplete element stays buffered
+		if len(p.buf) > len("$1\r\nx\r\n") {
+			t.Fatalf("byte %d: %d bytes buffered", i, len(p.buf))
+		}
+	}
+}
+
+func TestRESPCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"get", "k"}, "GET k"},
+		{[]string{"SET", "k", "two words"}, `SET k "two words"`},
+		{[]string{"SET", "k", ""}, `SET k ""`},
+		{[]string{"HELLO", "3", "AUTH", "user", "pass"}, "HELLO 3 AUTH user ***"},
+		{[]string{"SET", "k", strings.Repeat("a", maxArgLength+1)}, "SET k " + strings.Repeat("a", maxArgLength) + "..."},
+	}
+	for _, tt := range tests {
+		if got := respCommand(tt.args); got != tt.want {
+			t.Errorf("respCommand(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package trace decodes database wire protocols seen by the proxy and logs
+// every query with its timing, parameters and row count
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBuffered bounds the incomplete message a decoder holds. Connections
+// going past it, with bogus lengths or messages too large to bother with,
+// stop being traced.
+const maxBuffered = 32 << 20
+
+// Protocols lists the wire protocols that can be traced
+var Protocols = []string{"postgres", "mysql", "redis"}
+
+// Event is a query seen on a traced connection
+type Event struct {
+	Time       time.Time `json:"time"`
+	Connection int       `json:"connection"`
+	Protocol   string    `json:"protocol"`
+	Query      string    `json:"query"`
+	Params     []string  `json:"params,omitempty"`
+	DurationMs float64   `json:"duration_ms"`
+	Rows       *int64    `json:"rows,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Logger decodes connections speaking one protocol and writes their queries
+// to the terminal, or as JSON lines to a file
+type Logger struct {
+	protocol string
+
+	mu       sync.Mutex
+	w        io.Writer
+	file     *os.File
+	lastConn int
+}
+
+// NewLogger returns a logger for protocol writing to dest, which is "-" for
+// the terminal or the path of a JSONL file to append to
+func NewLogger(protocol, dest string) (*Logger, error) {
+	supported := false
+	for _, p := range Protocols {
+		supported = supported || p == protocol
+	}
+	if !supported {
+		return nil, fmt.Errorf("cannot trace %s queries (supported: %s)", protocol, strings.Join(Protocols, ", "))
+	}
+
+	if dest == "" || dest == "-" {
+		return &Logger{protocol: protocol, w: os.Stderr}, nil
+	}
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open query log: %v", err)
+	}
+	return &Logger{protocol: protocol, w: file, file: file}, nil
+}
+
+// Close closes the log file, if any
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Decoder follows one connection and logs the queries it carries
+type Decoder interface {
+	ClientData(data []byte)
+	ServerData(data []byte)
+	Close()
+}
+
+// NewDecoder returns a decoder for a new connection
+func (l *Logger) NewDecoder() Decoder {
+	l.mu.Lock()
+	l.lastConn++
+	c := conn{logger: l, id: l.lastConn, protocol: l.protocol}
+	l.mu.Unlock()
+
+	switch l.protocol {
+	case "postgres":
+		return newPostgresDecoder(c)
+	case "mysql":
+		return newMySQLDecoder(c)
+	}
+	return newRESPDecoder(c)
+}
+
+// Log writes an event
+func (l *Logger) Log(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		data, _ := json.Marshal(e)
+		l.w.Write(append(data, '\n'))
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s [%s #%d] %8.2fms", e.Time.Format("15:04:05.000"), e.Protocol, e.Connection, e.DurationMs)
+	switch {
+	case e.Error != "":
+		b.WriteString("  error")
+	case e.Rows != nil:
+		fmt.Fprintf(&b, "  %d rows", *e.Rows)
+	}
+	b.WriteString("  " + e.Query)
+	if len(e.Params) > 0 {
+		fmt.Fprintf(&b, "  [%s]", strings.Join(e.Params, ", "))
+	}
+	if e.Error != "" {
+		b.WriteString("\n    " + e.Error)
+	}
+	fmt.Fprintln(l.w, b.String())
+}
+
+// conn holds what decoders of every protocol share
+type conn struct {
+	logger   *Logger
+	id       int
+	protocol string
+}
+
+// query is a query waiting for its response
+type query struct {
+	text    string
+	params  []string
+	start   time.Time
+	rows    int64
+	hasRows bool
+	err     string
+}
+
+func (c conn) emit(q *query) {
+	e := Event{
+		Time:       q.start,
+		Connection: c.id,
+		Protocol:   c.protocol,
+		Query:      strings.TrimSpace(q.text),
+		Params:     q.params,
+		DurationMs: float64(time.Since(q.start).Microseconds()) / 1000,
+		Error:      q.err,
+	}
+	if q.hasRows {
+		rows := q.rows
+		e.Rows = &rows
+	}
+	c.logger.Log(e)
+}
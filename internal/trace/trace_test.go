@@ -0,0 +1,129 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// chunk is data sent by the client or the server of a traced connection
+type chunk struct {
+	server bool
+	data   []byte
+}
+
+func client(data ...[]byte) chunk { return chunk{data: concat(data...)} }
+func server(data ...[]byte) chunk { return chunk{server: true, data: concat(data...)} }
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// wantEvent is what a test expects of a logged query
+type wantEvent struct {
+	query  string
+	params []string
+	rows   int64 // -1 when no row count is expected
+	err    bool
+}
+
+// replay feeds chunks to a new decoder, in pieces of split bytes when split
+// is positive, and returns the events it logged
+func replay(t *testing.T, protocol string, chunks []chunk, split int) []Event {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	logger, err := NewLogger(protocol, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := logger.NewDecoder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, c := range chunks {
+			data := c.data
+			for len(data) > 0 {
+				n := len(data)
+				if split > 0 && split < n {
+					n = split
+				}
+				if c.server {
+					d.ServerData(data[:n])
+				} else {
+					d.ClientData(data[:n])
+				}
+				data = data[n:]
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("decoder did not return")
+	}
+	d.Close()
+	logger.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// checkEvents compares logged events with the expected ones, with the data
+// fed whole and split in small pieces
+func checkEvents(t *testing.T, protocol string, chunks []chunk, want []wantEvent) {
+	t.Helper()
+	for _, split := range []int{0, 1, 3} {
+		events := replay(t, protocol, chunks, split)
+		if len(events) != len(want) {
+			t.Fatalf("split %d: got %d events %+v, want %d", split, len(events), events, len(want))
+		}
+		for i, w := range want {
+			e := events[i]
+			if e.Query != w.query {
+				t.Errorf("split %d: event %d query = %q, want %q", split, i, e.Query, w.query)
+			}
+			if len(w.params) > 0 || len(e.Params) > 0 {
+				if len(e.Params) != len(w.params) {
+					t.Errorf("split %d: event %d params = %q, want %q", split, i, e.Params, w.params)
+				} else {
+					for j := range w.params {
+						if e.Params[j] != w.params[j] {
+							t.Errorf("split %d: event %d params = %q, want %q", split, i, e.Params, w.params)
+							break
+						}
+					}
+				}
+			}
+			switch {
+			case w.rows < 0 && e.Rows != nil:
+				t.Errorf("split %d: event %d rows = %d, want none", split, i, *e.Rows)
+			case w.rows >= 0 && (e.Rows == nil || *e.Rows != w.rows):
+				t.Errorf("split %d: event %d rows = %v, want %d", split, i, e.Rows, w.rows)
+			}
+			if (e.Error != "") != w.err {
+				t.Errorf("split %d: event %d error = %q, want error %v", split, i, e.Error, w.err)
+			}
+		}
+	}
+}
@@ -1,10 +1,24 @@
 package main
 
 import (
+	"dbin/cmd/bundle"
+	"dbin/cmd/chaos"
 	"dbin/cmd/cleanup"
+	"dbin/cmd/exec"
+	"dbin/cmd/export"
+	"dbin/cmd/images"
+	"dbin/cmd/importer"
+	"dbin/cmd/info"
 	"dbin/cmd/list"
+	"dbin/cmd/lock"
+	"dbin/cmd/open"
+	"dbin/cmd/proxy"
+	"dbin/cmd/stack"
+	"dbin/cmd/stats"
+	"dbin/cmd/ui"
 	"dbin/db"
 	"dbin/internal/commands"
+	"errors"
 	"log"
 	"os"
 
@@ -24,9 +38,29 @@ func main() {
 
 	cmd.AddCommand(list.NewCommand())
 	cmd.AddCommand(cleanup.NewCommand())
+	cmd.AddCommand(open.NewCommand())
+	cmd.AddCommand(exec.NewCommand())
+	cmd.AddCommand(chaos.NewCommand())
+	cmd.AddCommand(proxy.NewCommand())
+	cmd.AddCommand(stack.NewUpCommand())
+	cmd.AddCommand(stack.NewDownCommand())
+	cmd.AddCommand(stack.NewStatusCommand())
+	cmd.AddCommand(export.NewCommand())
+	cmd.AddCommand(importer.NewCommand())
+	cmd.AddCommand(info.NewCommand())
+	cmd.AddCommand(images.NewCommand())
+	cmd.AddCommand(stats.NewCommand())
+	cmd.AddCommand(bundle.NewCommand())
+	cmd.AddCommand(lock.NewCommand())
+	cmd.AddCommand(ui.NewCommand())
 	cmd.AddCommand(commands.CreateCommands(db.GetAllDatabases())...)
 
 	if err := cmd.Execute(); err != nil {
+		// Pass through the exit code of database clients run by dbin exec
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
 		log.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}